@@ -0,0 +1,132 @@
+// irvalidate checks the integrity of CLP IR streams using [ir.Validate],
+// printing a report for each file. Files compressed with zstd or gzip are
+// detected from their magic number and decompressed first, as by
+// [ir.OpenReader]. The exit status is 1 if any file is invalid (see
+// [ir.Report.Valid]), and 2 if any file could not be read.
+//
+// Usage:
+//
+//	irvalidate [-json] file...
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+	"github.com/y-scope/clp-ffi-go/ir"
+)
+
+const (
+	exitInvalid   = 1
+	exitReadError = 2
+)
+
+// fileReport is the output for a single file. Encoding and Corruption shadow
+// the Report's fields so they are printed by name in JSON.
+type fileReport struct {
+	File       string
+	Valid      bool
+	Error      string `json:",omitempty"`
+	Encoding   string
+	Corruption string
+	ir.Report
+}
+
+func main() {
+	jsonOutput := flag.Bool("json", false, "print each report as a line of JSON")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-json] file...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if 0 == flag.NArg() {
+		flag.Usage()
+		os.Exit(exitReadError)
+	}
+
+	exitCode := 0
+	for _, path := range flag.Args() {
+		report, err := validateFile(path)
+		result := fileReport{
+			File:       path,
+			Valid:      nil == err && report.Valid(),
+			Encoding:   report.Encoding.String(),
+			Corruption: report.Corruption.String(),
+			Report:     report,
+		}
+		if nil != err {
+			result.Error = err.Error()
+			exitCode = exitReadError
+		} else if false == result.Valid && 0 == exitCode {
+			exitCode = exitInvalid
+		}
+		if *jsonOutput {
+			printJson(result)
+		} else {
+			printText(result)
+		}
+	}
+	os.Exit(exitCode)
+}
+
+// validateFile opens the file at path and validates its (possibly compressed)
+// IR stream. Returns:
+//   - success: the file's Report, nil
+//   - error: the Report of the IR read before the error, error propagated from
+//     [os.Open] or [ir.Validate]
+func validateFile(path string) (ir.Report, error) {
+	file, err := os.Open(path)
+	if nil != err {
+		return ir.Report{CorruptionOffset: -1}, err
+	}
+	defer file.Close()
+	return ir.Validate(file)
+}
+
+func printJson(result fileReport) {
+	out, err := json.Marshal(result)
+	if nil != err {
+		fmt.Fprintf(os.Stderr, "%v: %v\n", result.File, err)
+		return
+	}
+	fmt.Println(string(out))
+}
+
+func printText(result fileReport) {
+	report := result.Report
+	status := "valid"
+	if false == result.Valid {
+		status = "INVALID"
+	}
+	fmt.Printf("%v: %v\n", result.File, status)
+	if "" != result.Error {
+		fmt.Printf("  error:           %v\n", result.Error)
+	}
+	fmt.Printf("  encoding:        %v\n", result.Encoding)
+	fmt.Printf("  version:         %v\n", report.Version)
+	fmt.Printf("  timestamp info:  %+v\n", report.TimestampInfo)
+	if nil != report.Metadata {
+		fmt.Printf("  metadata:        %v\n", report.Metadata)
+	}
+	fmt.Printf("  events:          %v\n", report.NumEvents)
+	if 0 < report.NumEvents {
+		fmt.Printf("  min timestamp:   %v\n", formatTimestamp(report.MinTimestamp))
+		fmt.Printf("  max timestamp:   %v\n", formatTimestamp(report.MaxTimestamp))
+	}
+	fmt.Printf("  non-monotonic:   %v\n", report.NonMonotonicTimestamps)
+	fmt.Printf("  EOF tag:         %v\n", report.HasEof)
+	if 0 < report.TrailingBytes {
+		fmt.Printf("  trailing bytes:  %v\n", report.TrailingBytes)
+	}
+	if ir.Success != report.Corruption {
+		fmt.Printf("  corruption:      %v at byte %v\n", report.Corruption, report.CorruptionOffset)
+	}
+}
+
+func formatTimestamp(timestamp ffi.EpochTimeMs) string {
+	return time.UnixMilli(int64(timestamp)).UTC().Format(time.RFC3339Nano)
+}
@@ -43,6 +43,11 @@ auto encode_log_message(
     }
     Encoder<encoded_var_t>* encoder{static_cast<Encoder<encoded_var_t>*>(ir_encoder)};
     auto& ir_log_msg{encoder->m_log_message};
+    // encode_message only clears the logtype, so the remaining storage must be
+    // cleared to avoid returning the variables of previous log messages
+    ir_log_msg.m_vars.clear();
+    ir_log_msg.m_dict_vars.clear();
+    ir_log_msg.m_dict_var_end_offsets.clear();
     ir_log_msg.reserve(log_message.m_size);
 
     std::string_view const log_msg_view{log_message.m_data, log_message.m_size};
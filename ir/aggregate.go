@@ -0,0 +1,185 @@
+package ir
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+	"github.com/y-scope/clp-ffi-go/search"
+)
+
+// A Histogram contains the number of log events matched in consecutive time
+// buckets of equal size. Counts[i] is the number of matches with a timestamp in
+// [Start + i*Bucket, Start + (i+1)*Bucket). Start is always aligned to a
+// multiple of Bucket from the lower bound of the searched time interval.
+type Histogram struct {
+	Start  ffi.EpochTimeMs
+	Bucket time.Duration
+	Counts []int
+}
+
+// Count reads the remainder of the IR stream, counting the log events that
+// match any query in queries, within timeInterval. If queries is empty every
+// log event within timeInterval is counted. Matching is done by the underlying
+// [Deserializer] so log events are never copied into Go. Counting stops
+// successfully once the IR stream EOF tag is found or a log event with a
+// timestamp past timeInterval is found, after which the Reader should not be
+// used for further reads. On error returns:
+//   - number of log events counted before the error
+//   - [IrError] error: CLP failed to successfully deserialize
+//   - error propagated from [io.Reader.Read]
+func (reader *Reader) Count(
+	queries []search.WildcardQuery,
+	timeInterval search.TimestampInterval,
+) (int, error) {
+	count := 0
+	err := reader.forEachWildcardMatch(
+		queries,
+		timeInterval,
+		func(ffi.EpochTimeMs) { count++ },
+	)
+	return count, err
+}
+
+// Histogram reads the remainder of the IR stream in the same way as
+// [Reader.Count], but counts the matching log events in time buckets of size
+// bucket (a positive whole number of milliseconds, the precision of
+// timestamps), starting from timeInterval.Lower. Only the range of buckets
+// containing matches is returned. Returns:
+//   - success: valid [*Histogram], nil
+//   - error: nil [*Histogram], invalid bucket size error or an error
+//     propagated from [Reader.Count]
+func (reader *Reader) Histogram(
+	queries []search.WildcardQuery,
+	timeInterval search.TimestampInterval,
+	bucket time.Duration,
+) (*Histogram, error) {
+	if 0 >= bucket || 0 != bucket%time.Millisecond {
+		return nil, fmt.Errorf("invalid bucket size: %v", bucket)
+	}
+	bucketMs := ffi.EpochTimeMs(bucket.Milliseconds())
+	hist := &Histogram{Bucket: bucket}
+	err := reader.forEachWildcardMatch(
+		queries,
+		timeInterval,
+		func(timestamp ffi.EpochTimeMs) {
+			bucketStart := timestamp - (timestamp-timeInterval.Lower)%bucketMs
+			if 0 == len(hist.Counts) {
+				hist.Start = bucketStart
+			} else if bucketStart < hist.Start {
+				// Timestamps are not guaranteed to be monotonic
+				counts := make([]int, int((hist.Start-bucketStart)/bucketMs)+len(hist.Counts))
+				copy(counts[len(counts)-len(hist.Counts):], hist.Counts)
+				hist.Counts = counts
+				hist.Start = bucketStart
+			}
+			idx := int((bucketStart - hist.Start) / bucketMs)
+			for len(hist.Counts) <= idx {
+				hist.Counts = append(hist.Counts, 0)
+			}
+			hist.Counts[idx]++
+		},
+	)
+	if nil != err {
+		return nil, err
+	}
+	return hist, nil
+}
+
+// maxMatchesPerBatch is the maximum number of matches found by a single call
+// to a wildcardMatchTimestamper.
+const maxMatchesPerBatch = 4096
+
+// wildcardMatchTimestamper is implemented by the deserializers able to find
+// many wildcard matches at once (i.e. the native deserializers, which find
+// them in a single call to the native library rather than one per match).
+// deserializeWildcardMatchTimestamps returns the timestamps of up to max log
+// events in irBuf matching mergedQuery within timeInterval, the position read
+// to in irBuf (the end of the last match), and the error that stopped the
+// search (nil if max matches were found). The returned timestamps are only
+// valid until the next call.
+type wildcardMatchTimestamper interface {
+	deserializeWildcardMatchTimestamps(
+		irBuf []byte,
+		mergedQuery search.MergedWildcardQuery,
+		timeInterval search.TimestampInterval,
+		max int,
+	) ([]ffi.EpochTimeMs, int, error)
+}
+
+// forEachWildcardMatch calls f with the timestamp of each log event matching
+// any query in queries, within timeInterval, until the end of the IR stream or
+// the upper bound of timeInterval is reached.
+func (reader *Reader) forEachWildcardMatch(
+	queries []search.WildcardQuery,
+	timeInterval search.TimestampInterval,
+	f func(ffi.EpochTimeMs),
+) error {
+	mergedQuery := search.MergeWildcardQueries(queries)
+	if reader.matchesByEvent() {
+		for {
+			event, _, err := reader.readToWildcardMatchByEvent(mergedQuery, timeInterval)
+			if EndOfIr == err || QueryNotFound == err {
+				return nil
+			}
+			if nil != err {
+				return err
+			}
+			f(event.Timestamp)
+		}
+	}
+	timestamper, batched := reader.Deserializer.(wildcardMatchTimestamper)
+	for {
+		end, err := reader.checkedEnd()
+		if nil != err {
+			return err
+		}
+		if batched {
+			var timestamps []ffi.EpochTimeMs
+			var pos int
+			timestamps, pos, err = timestamper.deserializeWildcardMatchTimestamps(
+				reader.buf[reader.start:end],
+				mergedQuery,
+				timeInterval,
+				maxMatchesPerBatch,
+			)
+			if 0 != len(timestamps) {
+				reader.eventOffset = reader.matchOffset(reader.start + pos)
+				reader.start += pos
+				for _, timestamp := range timestamps {
+					f(timestamp)
+				}
+			}
+			if nil == err {
+				continue
+			}
+		}
+		// Errors the batch cannot handle itself (e.g. a UTC offset change) are
+		// handled by finding the next match on its own.
+		if false == batched || CorruptedIr == err {
+			var event *ffi.LogEventView
+			var pos int
+			event, pos, _, err = reader.DeserializeWildcardMatchWithTimeInterval(
+				reader.buf[reader.start:end],
+				mergedQuery,
+				timeInterval,
+			)
+			if nil == err {
+				reader.eventOffset = reader.matchOffset(reader.start + pos)
+				reader.start += pos
+				f(event.Timestamp)
+				continue
+			}
+		}
+		switch err {
+		case IncompleteIr:
+			if _, err = reader.fillBuf(); nil != err {
+				return err
+			}
+		case EndOfIr, QueryNotFound:
+			return nil
+		default:
+			return err
+		}
+	}
+}
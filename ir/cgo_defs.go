@@ -1,6 +1,9 @@
+//go:build cgo && !purego
+
 package ir
 
 /*
+#cgo CXXFLAGS: -std=c++20
 #include <ffi_go/defs.h>
 #include <ffi_go/search/wildcard_query.h>
 */
@@ -86,7 +89,7 @@ func newLogMessageView[Tgo EightByteEncoding | FourByteEncoding, Tc C.Int64tSpan
 		return nil
 	}
 	if 0 < dictVars.m_size && nil != dictVars.m_data {
-		msgView.Logtype = unsafe.String((*byte)(unsafe.Pointer(dictVars.m_data)), dictVars.m_size)
+		msgView.DictVars = unsafe.String((*byte)(unsafe.Pointer(dictVars.m_data)), dictVars.m_size)
 	}
 	if 0 < dictVarEndOffsets.m_size && nil != dictVarEndOffsets.m_data {
 		msgView.DictVarEndOffsets = unsafe.Slice(
@@ -0,0 +1,159 @@
+package ir
+
+import (
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+)
+
+// A Checkpoint is the state of a [Reader] between two log events, from which
+// reading can be resumed by [ResumeReader] without reading the IR stream from
+// its start. All fields are exported, so a Checkpoint can be persisted with
+// [encoding/json] (or any other encoding).
+//   - Offset: the byte offset in the io.Reader of the next log event (see
+//     [Reader.Offset])
+//   - Encoding, Version, TimestampInfo, Metadata: the IR stream's preamble
+//   - PrevTimestamp: the timestamp of the previous log event (or the reference
+//     timestamp if there is none), which the timestamp deltas of four byte
+//     encoded IR are relative to (0 for eight byte encoded IR)
+//   - UtcOffset: the UTC offset of the previous log event
+//   - StreamIndex: the index of the IR stream read (see [Reader.StreamIndex])
+type Checkpoint struct {
+	Offset        int64
+	Encoding      Encoding
+	Version       string
+	TimestampInfo TimestampInfo
+	Metadata      map[string]any
+	PrevTimestamp ffi.EpochTimeMs
+	UtcOffset     time.Duration
+	StreamIndex   int
+}
+
+// Checkpoint returns the Reader's current state, so that after the Reader (or
+// the process) is closed, a new Reader created by [ResumeReader] will read the
+// log event following the one last read. Key-value pair IR streams cannot be
+// checkpointed, as each log event depends on the schema tree built by all the
+// log events before it. Returns:
+//   - success: the Reader's Checkpoint, nil
+//   - error: 0 value Checkpoint, stream type error (key-value pair IR stream)
+func (reader *Reader) Checkpoint() (Checkpoint, error) {
+	if _, ok := reader.Deserializer.(KVDeserializer); ok {
+		return Checkpoint{}, errKVStream
+	}
+	prevTimestamp, _ := fourByteTimestamp(reader.Deserializer)
+	return Checkpoint{
+		Offset:        reader.Offset(),
+		Encoding:      reader.Encoding(),
+		Version:       reader.Version(),
+		TimestampInfo: reader.TimestampInfo(),
+		Metadata:      reader.Metadata(),
+		PrevTimestamp: prevTimestamp,
+		UtcOffset:     reader.UtcOffset(),
+		StreamIndex:   reader.streamIdx,
+	}, nil
+}
+
+// ResumeReader is [ResumeReaderWithOptions] with the default [ReaderOptions].
+func ResumeReader(r io.ReadSeeker, cp Checkpoint) (*Reader, error) {
+	return ResumeReaderWithOptions(r, cp, ReaderOptions{})
+}
+
+// ResumeReaderWithOptions creates a new [Reader] configured by opts, that
+// resumes reading the IR stream in r from cp (created by [Reader.Checkpoint]).
+// r is seeked to cp.Offset and its Deserializer is restored from cp, so
+// neither the preamble nor any previous log event is read again. Returns:
+//   - success: valid [*Reader], nil
+//   - error: nil [*Reader], [CorruptedIr] (cp has an invalid encoding),
+//     [*UnsupportedVersionError] (under [VersionPolicyReject]), or error
+//     propagated from [DeserializePreamble] or [io.Seeker.Seek]
+func ResumeReaderWithOptions(
+	r io.ReadSeeker,
+	cp Checkpoint,
+	opts ReaderOptions,
+) (*Reader, error) {
+	return resumeReader(r, cp, opts, DeserializePreamble)
+}
+
+// resumeReader implements [ResumeReaderWithOptions], using deserializePreamble
+// to create the Reader's Deserializer.
+func resumeReader(
+	r io.ReadSeeker,
+	cp Checkpoint,
+	opts ReaderOptions,
+	deserializePreamble func([]byte) (Deserializer, int, error),
+) (*Reader, error) {
+	if _, err := r.Seek(cp.Offset, io.SeekStart); nil != err {
+		return nil, err
+	}
+	return newCheckpointReader(r, cp, opts, deserializePreamble)
+}
+
+// newCheckpointReader creates a Reader configured by opts that reads the IR
+// stream in r from cp, where r is positioned at cp.Offset. deserializePreamble
+// creates the Reader's Deserializer. Returns the same values as
+// [ResumeReaderWithOptions].
+func newCheckpointReader(
+	r io.Reader,
+	cp Checkpoint,
+	opts ReaderOptions,
+	deserializePreamble func([]byte) (Deserializer, int, error),
+) (*Reader, error) {
+	preamble, err := appendCheckpointPreamble(nil, cp)
+	if nil != err {
+		return nil, err
+	}
+	deserializer, _, err := deserializePreamble(preamble)
+	if nil != err {
+		return nil, err
+	}
+	if err = opts.checkVersion(deserializer.Version()); nil != err {
+		deserializer.Close()
+		return nil, err
+	}
+	setUtcOffset(deserializer, cp.UtcOffset)
+	irr := &Reader{
+		Deserializer:        deserializer,
+		ioReader:            r,
+		buf:                 opts.newBuf(),
+		opts:                opts,
+		deserializePreamble: deserializePreamble,
+		streamIdx:           cp.StreamIndex,
+		bufOffset:           cp.Offset,
+		eventOffset:         -1,
+	}
+	irr.startPrefetch()
+	irr.track()
+	return irr, nil
+}
+
+// appendCheckpointPreamble appends an IR stream preamble to irBuf that creates
+// a Deserializer in the state stored by cp (except its UTC offset), using
+// cp.PrevTimestamp as the reference timestamp. On error returns:
+//   - irBuf unchanged
+//   - [CorruptedIr] error: cp has an invalid encoding
+//   - error propagated from [appendPreambleMetadata]
+func appendCheckpointPreamble(irBuf []byte, cp Checkpoint) ([]byte, error) {
+	if EncodingEightByte != cp.Encoding && EncodingFourByte != cp.Encoding {
+		return irBuf, CorruptedIr
+	}
+	isFourByte := EncodingFourByte == cp.Encoding
+	metadata := map[string]any{
+		metadataVariablesSchemaIdKey:         metadataVariablesSchemaIdValue,
+		metadataVariableEncodingMethodsIdKey: metadataVariableEncodingMethodsIdValue,
+		metadataTimestampPatternKey:          cp.TimestampInfo.Pattern,
+		metadataTimestampPatternSyntaxKey:    cp.TimestampInfo.PatternSyntax,
+		metadataTzIdKey:                      cp.TimestampInfo.TimeZoneId,
+	}
+	if "" != cp.Version {
+		metadata[metadataVersionKey] = cp.Version
+	}
+	if isFourByte {
+		metadata[metadataReferenceTimestampKey] = strconv.FormatInt(int64(cp.PrevTimestamp), 10)
+	}
+	if nil != cp.Metadata {
+		metadata[metadataUserDefinedMetadataKey] = cp.Metadata
+	}
+	return appendPreambleMetadata(irBuf, isFourByte, metadata)
+}
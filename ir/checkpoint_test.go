@@ -0,0 +1,174 @@
+package ir
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+	"github.com/y-scope/clp-ffi-go/search"
+)
+
+func TestCheckpoint(t *testing.T) {
+	location, err := time.LoadLocation("America/New_York")
+	if nil != err {
+		t.Fatalf("time.LoadLocation failed: %v", err)
+	}
+	events := utcOffsetTestEvents()
+	for _, encoding := range []Encoding{EncodingEightByte, EncodingFourByte} {
+		var writer *Writer
+		if EncodingFourByte == encoding {
+			writer, err = NewWriterSize[FourByteEncoding](1024, location.String())
+		} else {
+			writer, err = NewWriterSize[EightByteEncoding](1024, location.String())
+		}
+		if nil != err {
+			t.Fatalf("NewWriterSize failed: %v", err)
+		}
+		writer.TrackUtcOffset(location)
+		for _, e := range events {
+			if _, err = writer.Write(e.event); nil != err {
+				t.Fatalf("Writer.Write failed: %v", err)
+			}
+		}
+		writer.Close()
+		irStream := append([]byte{}, writer.Bytes()...)
+
+		for _, deserializePreamble := range []func([]byte) (Deserializer, int, error){
+			DeserializePreamble,
+			goDeserializePreamble,
+		} {
+			for numRead := range len(events) + 1 {
+				cp := readCheckpoint(t, irStream, deserializePreamble, events, numRead)
+				irreader, err := resumeReader(
+					bytes.NewReader(irStream),
+					cp,
+					ReaderOptions{BufferSize: 8},
+					deserializePreamble,
+				)
+				if nil != err {
+					t.Fatalf("resumeReader failed: %v", err)
+				}
+				if tsInfo := irreader.TimestampInfo(); location.String() != tsInfo.TimeZoneId {
+					t.Fatalf("Wrong TimestampInfo: %v", tsInfo)
+				}
+				for _, e := range events[numRead:] {
+					assertIrLogEvent(t, nil, irreader, e.event)
+					if e.utcOffset != irreader.UtcOffset() {
+						t.Fatalf("Wrong UTC offset: %v != %v", irreader.UtcOffset(), e.utcOffset)
+					}
+				}
+				assertEndOfIr(t, nil, irreader)
+				irreader.Close()
+			}
+		}
+	}
+}
+
+// readCheckpoint reads numRead of events from irStream and returns the
+// Reader's Checkpoint after it is persisted as JSON. The last log event read
+// is found by wildcard matching, so the Checkpoint must not depend on how the
+// log events were read.
+func readCheckpoint(
+	t *testing.T,
+	irStream []byte,
+	deserializePreamble func([]byte) (Deserializer, int, error),
+	events []utcOffsetTestEvent,
+	numRead int,
+) Checkpoint {
+	irreader, err := newReader(bytes.NewReader(irStream), ReaderOptions{}, deserializePreamble)
+	if nil != err {
+		t.Fatalf("newReader failed: %v", err)
+	}
+	defer irreader.Close()
+	if 0 < numRead {
+		for _, e := range events[:numRead-1] {
+			assertIrLogEvent(t, nil, irreader, e.event)
+		}
+		last := events[numRead-1].event
+		query := search.NewWildcardQuery(last.LogMessage, true)
+		event, _, err := irreader.ReadToWildcardMatch([]search.WildcardQuery{query})
+		if nil != err {
+			t.Fatalf("ReadToWildcardMatch failed: %v", err)
+		}
+		if last.Timestamp != event.Timestamp {
+			t.Fatalf("ReadToWildcardMatch wrong event: %v != %v", event, last)
+		}
+	}
+
+	cp, err := irreader.Checkpoint()
+	if nil != err {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	if irreader.Offset() != cp.Offset {
+		t.Fatalf("Wrong checkpoint offset: %v != %v", cp.Offset, irreader.Offset())
+	}
+	data, err := json.Marshal(cp)
+	if nil != err {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	var persisted Checkpoint
+	if err = json.Unmarshal(data, &persisted); nil != err {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	if false == reflect.DeepEqual(cp, persisted) {
+		t.Fatalf("Checkpoint changed when persisted: %+v != %+v", persisted, cp)
+	}
+	return persisted
+}
+
+func TestCheckpointConcatenated(t *testing.T) {
+	irStream := concatenateTestStreams(t)
+	opts := ReaderOptions{Concatenated: true}
+	irreader, err := NewReaderWithOptions(bytes.NewReader(irStream), opts)
+	if nil != err {
+		t.Fatalf("NewReaderWithOptions failed: %v", err)
+	}
+	var remaining []ffi.LogEvent
+	for _, stream := range concatenatedTestStreams[1:] {
+		remaining = append(remaining, stream.events...)
+	}
+	for _, event := range concatenatedTestStreams[0].events {
+		assertIrLogEvent(t, nil, irreader, event)
+	}
+	assertIrLogEvent(t, nil, irreader, remaining[0])
+	cp, err := irreader.Checkpoint()
+	irreader.Close()
+	if nil != err {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	if 1 != cp.StreamIndex || concatenatedTestStreams[1].tsInfo != cp.TimestampInfo {
+		t.Fatalf("Wrong checkpoint: %+v", cp)
+	}
+
+	irreader, err = ResumeReaderWithOptions(bytes.NewReader(irStream), cp, opts)
+	if nil != err {
+		t.Fatalf("ResumeReaderWithOptions failed: %v", err)
+	}
+	defer irreader.Close()
+	for _, event := range remaining[1:] {
+		assertIrLogEvent(t, nil, irreader, event)
+	}
+	assertEndOfIr(t, nil, irreader)
+	if 3 != irreader.StreamIndex() {
+		t.Fatalf("Wrong stream index: %v", irreader.StreamIndex())
+	}
+}
+
+func TestCheckpointKV(t *testing.T) {
+	serializer, irStream := newTestKVSerializer(t, EncodingEightByte)
+	serializer.Close()
+	irreader, err := NewReader(bytes.NewReader(append(irStream, tagEof)))
+	if nil != err {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer irreader.Close()
+	if _, err = irreader.Checkpoint(); errKVStream != err {
+		t.Fatalf("Checkpoint of a key-value pair IR stream returned: %v", err)
+	}
+	if _, err = ResumeReader(bytes.NewReader(nil), Checkpoint{Encoding: -1}); CorruptedIr != err {
+		t.Fatalf("ResumeReader with an invalid encoding returned: %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build go1.24
+
+package ir
+
+import (
+	"runtime"
+)
+
+// cleanupSupported is whether addCleanup releases garbage collected objects.
+const cleanupSupported = true
+
+// addCleanup calls release once owner is garbage collected, unless the
+// returned function is called first.
+func addCleanup[T any](owner *T, release func()) func() {
+	cleanup := runtime.AddCleanup(owner, func(release func()) { release() }, release)
+	return cleanup.Stop
+}
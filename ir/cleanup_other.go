@@ -0,0 +1,12 @@
+//go:build !go1.24
+
+package ir
+
+// cleanupSupported is whether addCleanup releases garbage collected objects.
+const cleanupSupported = false
+
+// addCleanup does nothing, as runtime.AddCleanup requires Go 1.24. The
+// returned function does nothing either.
+func addCleanup[T any](owner *T, release func()) func() {
+	return func() {}
+}
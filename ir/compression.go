@@ -0,0 +1,98 @@
+package ir
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	gzipMagicNumber = []byte{0x1F, 0x8B}
+	zstdMagicNumber = []byte{0x28, 0xB5, 0x2F, 0xFD}
+)
+
+// zstdSkippableMagicNumber masked by zstdSkippableMagicNumberMask matches the
+// (little-endian) magic numbers of zstd skippable frames, 0x184D2A50 to
+// 0x184D2A5F, which a zstd stream may start with (e.g. to store metadata).
+const (
+	zstdSkippableMagicNumber     uint32 = 0x184D2A50
+	zstdSkippableMagicNumberMask uint32 = 0xFFFFFFF0
+)
+
+// OpenReader is [OpenReaderWithOptions] with the default [ReaderOptions].
+func OpenReader(r io.Reader) (*Reader, error) {
+	return OpenReaderWithOptions(r, ReaderOptions{})
+}
+
+// OpenReaderWithOptions is [NewReaderWithOptions] for an IR stream that may be
+// compressed. The compression (zstd or gzip) is detected from the magic
+// number r starts with, and the IR stream is transparently decompressed while
+// it is read. If r starts with neither it is read as an uncompressed IR stream.
+// The Reader's offsets (see [Reader.Offset]) are offsets in the decompressed
+// IR stream. Closing the Reader also closes the decompressor (but not r).
+// Returns:
+//   - success: valid [*Reader], nil
+//   - error: nil [*Reader], error propagated from [zstd.NewReader],
+//     [gzip.NewReader], or [NewReaderWithOptions]
+func OpenReaderWithOptions(r io.Reader, opts ReaderOptions) (*Reader, error) {
+	return openReader(r, opts, DeserializePreamble)
+}
+
+// openReader implements [OpenReaderWithOptions], using deserializePreamble to
+// create the Reader's Deserializer.
+func openReader(
+	r io.Reader,
+	opts ReaderOptions,
+	deserializePreamble func([]byte) (Deserializer, int, error),
+) (*Reader, error) {
+	decompressed, decompressor, err := decompress(r)
+	if nil != err {
+		return nil, err
+	}
+	reader, err := newReader(decompressed, opts, deserializePreamble)
+	if nil != err {
+		if nil != decompressor {
+			decompressor.Close()
+		}
+		return nil, err
+	}
+	reader.decompressor = decompressor
+	reader.track()
+	return reader, nil
+}
+
+// decompress detects the compression of r from the magic number it starts
+// with. Returns:
+//   - success: an io.Reader of r's decompressed content, its decompressor
+//     (nil if r is not compressed), nil
+//   - error: nil io.Reader, nil decompressor, error propagated from
+//     [zstd.NewReader] or [gzip.NewReader]
+func decompress(r io.Reader) (io.Reader, io.Closer, error) {
+	br := bufio.NewReader(r)
+	// A short stream is read as uncompressed, so that its error is reported
+	// while reading the preamble.
+	magic, _ := br.Peek(len(zstdMagicNumber))
+	switch {
+	case bytes.Equal(zstdMagicNumber, magic),
+		len(magic) == len(zstdMagicNumber) &&
+			zstdSkippableMagicNumber ==
+				binary.LittleEndian.Uint32(magic)&zstdSkippableMagicNumberMask:
+		zstdReader, err := zstd.NewReader(br)
+		if nil != err {
+			return nil, nil, err
+		}
+		decompressor := zstdReader.IOReadCloser()
+		return decompressor, decompressor, nil
+	case bytes.HasPrefix(magic, gzipMagicNumber):
+		gzipReader, err := gzip.NewReader(br)
+		if nil != err {
+			return nil, nil, err
+		}
+		return gzipReader, gzipReader, nil
+	}
+	return br, nil, nil
+}
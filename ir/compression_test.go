@@ -0,0 +1,70 @@
+package ir
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestOpenReader(t *testing.T) {
+	events := seekableTestEvents()[:20]
+	var buf bytes.Buffer
+	writer, err := NewWriterSize[FourByteEncoding](1024, "America/New_York")
+	if nil != err {
+		t.Fatalf("NewWriterSize failed: %v", err)
+	}
+	for _, e := range events {
+		if _, err = writer.Write(e.event); nil != err {
+			t.Fatalf("Writer.Write failed: %v", err)
+		}
+	}
+	if _, err = writer.CloseTo(&buf); nil != err {
+		t.Fatalf("Writer.CloseTo failed: %v", err)
+	}
+	irStream := buf.Bytes()
+
+	var zstdBuf bytes.Buffer
+	zstdWriter, err := zstd.NewWriter(&zstdBuf)
+	if nil != err {
+		t.Fatalf("zstd.NewWriter failed: %v", err)
+	}
+	zstdWriter.Write(irStream)
+	zstdWriter.Close()
+
+	var gzipBuf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&gzipBuf)
+	gzipWriter.Write(irStream)
+	gzipWriter.Close()
+
+	for name, file := range map[string][]byte{
+		"raw":      irStream,
+		"zstd":     zstdBuf.Bytes(),
+		"gzip":     gzipBuf.Bytes(),
+		"seekable": writeSeekableTestFile(t, EncodingEightByte, events),
+	} {
+		irreader, err := OpenReader(bytes.NewReader(file))
+		if nil != err {
+			t.Fatalf("OpenReader of %v IR failed: %v", name, err)
+		}
+		for _, e := range events {
+			assertIrLogEvent(t, nil, irreader, e.event)
+		}
+		assertEndOfIr(t, nil, irreader)
+		if err = irreader.Close(); nil != err {
+			t.Fatalf("Reader.Close of %v IR failed: %v", name, err)
+		}
+	}
+
+	for _, file := range [][]byte{
+		nil,
+		irStream[:2],
+		zstdBuf.Bytes()[:8],
+		gzipBuf.Bytes()[:2],
+	} {
+		if _, err = OpenReader(bytes.NewReader(file)); nil == err {
+			t.Fatalf("OpenReader of truncated IR %x succeeded", file)
+		}
+	}
+}
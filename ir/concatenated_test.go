@@ -0,0 +1,158 @@
+package ir
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+	"github.com/y-scope/clp-ffi-go/search"
+)
+
+// concatenatedTestStream describes an IR stream by its TimestampInfo, log
+// events, and encoding.
+type concatenatedTestStream struct {
+	tsInfo   TimestampInfo
+	events   []ffi.LogEvent
+	encoding Encoding
+}
+
+var concatenatedTestStreams = []concatenatedTestStream{
+	{
+		TimestampInfo{"%Y-%m-%d %H:%M:%S", "java", "UTC"},
+		[]ffi.LogEvent{
+			{LogMessage: "stream 0 event 0", Timestamp: 10},
+			{LogMessage: "stream 0 event 1", Timestamp: 20},
+		},
+		EncodingEightByte,
+	},
+	{
+		TimestampInfo{TimeZoneId: "America/New_York"},
+		[]ffi.LogEvent{{LogMessage: "stream 1 event 0 id=4938", Timestamp: 5}},
+		EncodingFourByte,
+	},
+	{TimestampInfo{TimeZoneId: "Asia/Tokyo"}, nil, EncodingEightByte},
+	{
+		TimestampInfo{TimeZoneId: "Europe/Paris"},
+		[]ffi.LogEvent{
+			{LogMessage: "stream 3 event 0", Timestamp: 1},
+			{LogMessage: "stream 3 event 1 value=0.5", Timestamp: 2},
+		},
+		EncodingFourByte,
+	},
+}
+
+// concatenateTestStreams serializes and concatenates concatenatedTestStreams.
+func concatenateTestStreams(t *testing.T) []byte {
+	var irStream []byte
+	for _, stream := range concatenatedTestStreams {
+		irStream = append(
+			irStream,
+			serializeEncodedTestStream(t, stream.encoding, stream.tsInfo, stream.events)...,
+		)
+	}
+	return irStream
+}
+
+func TestReaderConcatenated(t *testing.T) {
+	irStream := concatenateTestStreams(t)
+
+	irreader, err := NewReader(bytes.NewReader(irStream))
+	if nil != err {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	for _, event := range concatenatedTestStreams[0].events {
+		assertIrLogEvent(t, nil, irreader, event)
+	}
+	assertEndOfIr(t, nil, irreader)
+	irreader.Close()
+
+	for _, deserializePreamble := range []func([]byte) (Deserializer, int, error){
+		DeserializePreamble,
+		goDeserializePreamble,
+	} {
+		var boundaries []int
+		onStreamBoundary := func(streamIdx int) { boundaries = append(boundaries, streamIdx) }
+		irreader, err := newReader(
+			iotest.OneByteReader(bytes.NewReader(irStream)),
+			ReaderOptions{BufferSize: 8, Concatenated: true, OnStreamBoundary: onStreamBoundary},
+			deserializePreamble,
+		)
+		if nil != err {
+			t.Fatalf("newReader failed: %v", err)
+		}
+		for streamIdx, stream := range concatenatedTestStreams {
+			for _, event := range stream.events {
+				assertIrLogEvent(t, nil, irreader, event)
+				if streamIdx != irreader.StreamIndex() {
+					t.Fatalf("Wrong stream index: %v != %v", irreader.StreamIndex(), streamIdx)
+				}
+				if tsInfo := irreader.TimestampInfo(); stream.tsInfo != tsInfo {
+					t.Fatalf("Wrong TimestampInfo: %v != %v", tsInfo, stream.tsInfo)
+				}
+				if stream.encoding != irreader.Encoding() {
+					t.Fatalf("Wrong encoding: %v != %v", irreader.Encoding(), stream.encoding)
+				}
+			}
+		}
+		assertEndOfIr(t, nil, irreader)
+		assertEndOfIr(t, nil, irreader)
+		irreader.Close()
+		if expected := []int{1, 2, 3}; false == reflect.DeepEqual(expected, boundaries) {
+			t.Fatalf("Wrong stream boundaries: %v != %v", boundaries, expected)
+		}
+	}
+}
+
+func TestReaderConcatenatedWildcardMatch(t *testing.T) {
+	irreader, err := NewReaderWithOptions(
+		bytes.NewReader(concatenateTestStreams(t)),
+		ReaderOptions{Concatenated: true},
+	)
+	if nil != err {
+		t.Fatalf("NewReaderWithOptions failed: %v", err)
+	}
+	defer irreader.Close()
+	queries := []search.WildcardQuery{search.NewWildcardQuery("*=*", true)}
+	for _, expected := range []ffi.LogEvent{
+		concatenatedTestStreams[1].events[0],
+		concatenatedTestStreams[3].events[1],
+	} {
+		event, _, err := irreader.ReadToWildcardMatch(queries)
+		if nil != err {
+			t.Fatalf("ReadToWildcardMatch failed: %v", err)
+		}
+		if expected.LogMessage != event.LogMessageView || expected.Timestamp != event.Timestamp {
+			t.Fatalf("ReadToWildcardMatch wrong event: %v != %v", event, expected)
+		}
+	}
+	if _, _, err = irreader.ReadToWildcardMatch(queries); EndOfIr != err {
+		t.Fatalf("ReadToWildcardMatch did not reach the end of the IR: %v", err)
+	}
+}
+
+func TestReaderConcatenatedTruncated(t *testing.T) {
+	irStream := concatenateTestStreams(t)
+	first := serializeEncodedTestStream(
+		t,
+		concatenatedTestStreams[0].encoding,
+		concatenatedTestStreams[0].tsInfo,
+		concatenatedTestStreams[0].events,
+	)
+	irreader, err := NewReaderWithOptions(
+		bytes.NewReader(irStream[:len(first)+8]),
+		ReaderOptions{Concatenated: true},
+	)
+	if nil != err {
+		t.Fatalf("NewReaderWithOptions failed: %v", err)
+	}
+	defer irreader.Close()
+	for _, event := range concatenatedTestStreams[0].events {
+		assertIrLogEvent(t, nil, irreader, event)
+	}
+	if _, err = irreader.Read(); io.ErrUnexpectedEOF != err {
+		t.Fatalf("Read of a truncated preamble returned: %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+//go:build cgo && !purego
+
+package ir
+
+/*
+#include <ffi_go/ir/decoder.h>
+*/
+import "C"
+
+import (
+	"runtime"
+	"unsafe"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+)
+
+// Return a new Decoder for IR using [EightByteEncoding].
+func EightByteDecoder() (Decoder[EightByteEncoding], error) {
+	decoder := &eightByteDecoder{commonDecoder{cptr: C.ir_decoder_new()}}
+	decoder.track()
+	return decoder, nil
+}
+
+// Return a new Decoder for IR using [FourByteEncoding].
+func FourByteDecoder() (Decoder[FourByteEncoding], error) {
+	decoder := &fourByteDecoder{commonDecoder{cptr: C.ir_decoder_new()}}
+	decoder.track()
+	return decoder, nil
+}
+
+// commonDecoder holds a reference to the underlying C++ decoder in cptr and to
+// its leak tracking (see [SetLeakTracking]) in ref.
+type commonDecoder struct {
+	cptr unsafe.Pointer
+	ref  leakRef
+}
+
+// track tracks the decoder for leaks. Its C++ decoder is not deleted once it is
+// garbage collected, as returned views may still reference it.
+func (decoder *commonDecoder) track() {
+	decoder.ref = trackLeaks(decoder, "Decoder", nil)
+}
+
+// Close will delete the underlying C++ allocated memory used by the
+// deserializer. Failure to call Close will result in a memory leak.
+func (decoder *commonDecoder) Close() error {
+	if nil != decoder.cptr {
+		decoder.ref.untrack()
+		C.ir_decoder_close(decoder.cptr)
+		decoder.cptr = nil
+	}
+	return nil
+}
+
+type eightByteDecoder struct {
+	commonDecoder
+}
+
+// Decode an IR encoded log message, returning a view of the original
+// (non-encoded) log message.
+func (decoder *eightByteDecoder) DecodeLogMessage(
+	irMessage LogMessage[EightByteEncoding],
+) (*ffi.LogMessageView, error) {
+	var msg C.StringView
+	err := IrError(C.ir_decoder_decode_eight_byte_log_message(
+		newCStringView(irMessage.Logtype),
+		newCInt64tSpan(irMessage.Vars),
+		newCStringView(irMessage.DictVars),
+		newCInt32tSpan(irMessage.DictVarEndOffsets),
+		decoder.cptr,
+		&msg,
+	))
+	runtime.KeepAlive(decoder)
+	if Success != err {
+		return nil, DecodeError
+	}
+	view := unsafe.String((*byte)(unsafe.Pointer(msg.m_data)), msg.m_size)
+	return &view, nil
+}
+
+type fourByteDecoder struct {
+	commonDecoder
+}
+
+// Decode an IR encoded log message, returning a view of the original
+// (non-encoded) log message.
+func (decoder *fourByteDecoder) DecodeLogMessage(
+	irMessage LogMessage[FourByteEncoding],
+) (*ffi.LogMessageView, error) {
+	var msg C.StringView
+	err := IrError(C.ir_decoder_decode_four_byte_log_message(
+		newCStringView(irMessage.Logtype),
+		newCInt32tSpan(irMessage.Vars),
+		newCStringView(irMessage.DictVars),
+		newCInt32tSpan(irMessage.DictVarEndOffsets),
+		decoder.cptr,
+		&msg,
+	))
+	runtime.KeepAlive(decoder)
+	if Success != err {
+		return nil, DecodeError
+	}
+	view := unsafe.String((*byte)(unsafe.Pointer(msg.m_data)), msg.m_size)
+	return &view, nil
+}
@@ -0,0 +1,13 @@
+//go:build !cgo || purego
+
+package ir
+
+// Return a new Decoder for IR using [EightByteEncoding].
+func EightByteDecoder() (Decoder[EightByteEncoding], error) {
+	return &goDecoder[EightByteEncoding]{}, nil
+}
+
+// Return a new Decoder for IR using [FourByteEncoding].
+func FourByteDecoder() (Decoder[FourByteEncoding], error) {
+	return &goDecoder[FourByteEncoding]{}, nil
+}
@@ -1,16 +1,10 @@
 package ir
 
-/*
-#include <ffi_go/defs.h>
-#include <ffi_go/ir/deserializer.h>
-#include <ffi_go/search/wildcard_query.h>
-*/
-import "C"
-
 import (
+	"bytes"
 	"encoding/json"
 	"strconv"
-	"unsafe"
+	"time"
 
 	"github.com/y-scope/clp-ffi-go/ffi"
 	"github.com/y-scope/clp-ffi-go/search"
@@ -30,7 +24,14 @@ const (
 // own unique underlying memory for the views it produces/returns. This memory
 // is reused for each view, so to persist the contents the memory must be copied
 // into another object. Close must be called to free the underlying memory and
-// failure to do so will result in a memory leak.
+// failure to do so will result in a memory leak. Metadata returns the
+// user-defined metadata stored in the IR stream's preamble (nil if there is
+// none), which is owned by the Deserializer and must not be modified. Version
+// returns the IR protocol version stored in the preamble ("" if there is
+// none), and Encoding returns the encoding of the IR stream. UtcOffset returns
+// the UTC offset in effect for the most recently deserialized log event, as
+// recorded by the UTC offset changes in the IR stream (0 until the first
+// change), which can be used to render the log event's local time.
 type Deserializer interface {
 	DeserializeLogEvent(irBuf []byte) (*ffi.LogEventView, int, error)
 	DeserializeWildcardMatchWithTimeInterval(
@@ -39,276 +40,120 @@ type Deserializer interface {
 		timeInterval search.TimestampInterval,
 	) (*ffi.LogEventView, int, int, error)
 	TimestampInfo() TimestampInfo
+	Metadata() map[string]any
+	Version() string
+	Encoding() Encoding
+	UtcOffset() time.Duration
 	Close() error
 }
 
-// DeserializePreamble attempts to read an IR stream preamble from irBuf,
-// returning an Deserializer (of the correct stream encoding size), the position
-// read to in irBuf (the end of the preamble), and an error. Note the metadata
-// stored in the preamble is sparse and certain fields in TimestampInfo may be 0
-// value. On error returns:
-//   - nil Deserializer
-//   - 0 position
-//   - [IrError] error: CLP failed to successfully deserialize
-//   - [encoding/json] error: unmarshalling the metadata failed
-func DeserializePreamble(irBuf []byte) (Deserializer, int, error) {
-	if 0 >= len(irBuf) {
-		return nil, 0, IncompleteIr
-	}
-
-	// TODO: Add version validation in this method or ir_deserializer_new_deserializer_with_preamble
-	// after updating the clp version.
+// A BatchDeserializer is a [Deserializer] that can also deserialize many log
+// events in one call, which every Deserializer of this package implements. It
+// is separate from Deserializer so that implementations outside this package
+// remain Deserializers. DeserializeLogEvents deserializes up to max log events
+// (every complete log event in irBuf if max is not positive) from the IR
+// stream in irBuf, returning their views and the position read to in irBuf
+// (the end of the last log event). Unlike the views returned by
+// DeserializeLogEvent, the returned views are owned by Go, so they remain
+// valid after later calls and after the Deserializer is closed.
+// Deserialization stops early at the first error, which is only returned if
+// no log event was deserialized before it, so that the next call returns it
+// instead (e.g. [IncompleteIr] once irBuf ends).
+type BatchDeserializer interface {
+	Deserializer
+	DeserializeLogEvents(irBuf []byte, max int) ([]ffi.LogEventView, int, error)
+}
 
-	var pos C.size_t
-	var irEncoding C.int8_t
-	var metadataType C.int8_t
-	var metadataPos C.size_t
-	var metadataSize C.uint16_t
-	var deserializerCptr unsafe.Pointer
-	var timestampCptr unsafe.Pointer
-	if err := IrError(C.ir_deserializer_new_deserializer_with_preamble(
-		newCByteSpan(irBuf),
-		&pos,
-		&irEncoding,
-		&metadataType,
-		&metadataPos,
-		&metadataSize,
-		&deserializerCptr,
-		&timestampCptr,
-	)); Success != err {
-		return nil, int(pos), err
+// deserializeLogEvents implements DeserializeLogEvents for the Go
+// deserializers, deserializing up to max log events (or every log event if max
+// is not positive) from the IR stream in irBuf with deserializer and copying
+// their log messages into one string owned by Go, of which the returned views
+// are slices. Deserialization stops early at the first error,
+// which is only returned if no log event was deserialized before it, so that
+// the next call returns it instead (e.g. [IncompleteIr] once irBuf ends).
+// Returns:
+//   - success: the deserialized log events, the position read to in irBuf
+//     (the end of the last log event in irBuf), nil
+//   - error: nil, 0, error propagated from
+//     [Deserializer.DeserializeLogEvent]
+func deserializeLogEvents(
+	deserializer Deserializer,
+	irBuf []byte,
+	max int,
+) ([]ffi.LogEventView, int, error) {
+	var events []ffi.LogEventView
+	var ends []int
+	var text []byte
+	pos := 0
+	for 0 >= max || len(events) < max {
+		event, n, err := deserializer.DeserializeLogEvent(irBuf[pos:])
+		if nil != err {
+			if 0 == len(events) {
+				return nil, 0, err
+			}
+			break
+		}
+		pos += n
+		text = append(text, event.LogMessageView...)
+		ends = append(ends, len(text))
+		events = append(events, ffi.LogEventView{Timestamp: event.Timestamp})
 	}
 
-	if metadataType != 1 {
-		return nil, 0, UnsupportedVersion
+	textStorage := string(text)
+	start := 0
+	for i, end := range ends {
+		events[i].LogMessageView = textStorage[start:end]
+		start = end
 	}
+	return events, pos, nil
+}
 
+// preambleMetadata is the information stored in the JSON metadata of an IR
+// stream preamble. Missing fields are left as 0 value.
+//   - tsInfo: the TimestampInfo of the stream's log events
+//   - refTs: the reference timestamp (only present in four byte encoded IR)
+//   - version: the version of the IR protocol used by the stream
+//   - userMetadata: the user-defined metadata, using int64 for integers and
+//     float64 for all other numbers
+type preambleMetadata struct {
+	tsInfo       TimestampInfo
+	refTs        ffi.EpochTimeMs
+	version      string
+	userMetadata map[string]any
+}
+
+// unmarshalPreambleMetadata unmarshals the JSON metadata of an IR stream
+// preamble. On error returns:
+//   - 0 value preambleMetadata
+//   - [encoding/json] error: unmarshalling the metadata failed
+func unmarshalPreambleMetadata(metadataBuf []byte) (preambleMetadata, error) {
+	decoder := json.NewDecoder(bytes.NewReader(metadataBuf))
+	decoder.UseNumber()
 	var metadata map[string]interface{}
-	if err := json.Unmarshal(
-		irBuf[metadataPos:metadataPos+C.size_t(metadataSize)],
-		&metadata,
-	); nil != err {
-		return nil, 0, err
+	if err := decoder.Decode(&metadata); nil != err {
+		return preambleMetadata{}, err
 	}
 
-	var tsInfo TimestampInfo
+	var preamble preambleMetadata
 	if tsPat, ok := metadata[metadataTimestampPatternKey].(string); ok {
-		tsInfo.Pattern = tsPat
+		preamble.tsInfo.Pattern = tsPat
 	}
 	if tsSyn, ok := metadata[metadataTimestampPatternSyntaxKey].(string); ok {
-		tsInfo.PatternSyntax = tsSyn
+		preamble.tsInfo.PatternSyntax = tsSyn
 	}
 	if tzid, ok := metadata[metadataTzIdKey].(string); ok {
-		tsInfo.TimeZoneId = tzid
+		preamble.tsInfo.TimeZoneId = tzid
 	}
-
-	var deserializer Deserializer
-	if irEncoding == 1 {
-		var refTs ffi.EpochTimeMs = 0
-		if tsStr, ok := metadata[metadataReferenceTimestampKey].(string); ok {
-			if tsInt, err := strconv.ParseInt(tsStr, 10, 64); nil == err {
-				refTs = ffi.EpochTimeMs(tsInt)
-				*(*ffi.EpochTimeMs)(timestampCptr) = refTs
-			}
+	if tsStr, ok := metadata[metadataReferenceTimestampKey].(string); ok {
+		if tsInt, err := strconv.ParseInt(tsStr, 10, 64); nil == err {
+			preamble.refTs = ffi.EpochTimeMs(tsInt)
 		}
-		deserializer = &fourByteDeserializer{commonDeserializer{tsInfo, deserializerCptr}, refTs}
-	} else {
-		deserializer = &eightByteDeserializer{commonDeserializer{tsInfo, deserializerCptr}}
 	}
-
-	return deserializer, int(pos), nil
-}
-
-// commonDeserializer contains fields common to all types of CLP IR encoding.
-// TimestampInfo stores information common to all timestamps found in the IR.
-// cptr holds a reference to the underlying C++ objected used as backing storage
-// for the Views returned by the deserializer. Close must be called to free this
-// underlying memory and failure to do so will result in a memory leak.
-type commonDeserializer struct {
-	tsInfo TimestampInfo
-	cptr   unsafe.Pointer
-}
-
-// Close will delete the underlying C++ allocated memory used by the
-// deserializer. Failure to call Close will result in a memory leak.
-func (deserializer *commonDeserializer) Close() error {
-	if nil != deserializer.cptr {
-		C.ir_deserializer_close(deserializer.cptr)
-		deserializer.cptr = nil
+	if version, ok := metadata[metadataVersionKey].(string); ok {
+		preamble.version = version
 	}
-	return nil
-}
-
-// Returns the TimestampInfo used by the Deserializer.
-func (deserializer commonDeserializer) TimestampInfo() TimestampInfo {
-	return deserializer.tsInfo
-}
-
-type eightByteDeserializer struct {
-	commonDeserializer
-}
-
-// DeserializeLogEvent attempts to read the next log event from the IR stream in
-// irBuf, returning the deserialized [ffi.LogEventView], the position read to in
-// irBuf (the end of the log event in irBuf), and an error. On error returns:
-//   - nil *ffi.LogEventView
-//   - 0 position
-//   - [IrError] error: CLP failed to successfully deserialize
-//   - [EndOfIr] error: CLP found the IR stream EOF tag
-func (deserializer *eightByteDeserializer) DeserializeLogEvent(
-	irBuf []byte,
-) (*ffi.LogEventView, int, error) {
-	return deserializeLogEvent(deserializer, irBuf)
-}
-
-// DeserializeWildcardMatchWithTimeInterval attempts to read the next log event
-// from the IR stream in irBuf that matches mergedQuery within timeInterval. It
-// returns the deserialized [ffi.LogEventView], the position read to in irBuf
-// (the end of the log event in irBuf), the index of the matched query in
-// mergedQuery, and an error. On error returns:
-//   - nil *ffi.LogEventView
-//   - 0 position
-//   - -1 index
-//   - [IrError] error: CLP failed to successfully deserialize
-//   - [EndOfIr] error: CLP found the IR stream EOF tag
-func (deserializer *eightByteDeserializer) DeserializeWildcardMatchWithTimeInterval(
-	irBuf []byte,
-	mergedQuery search.MergedWildcardQuery,
-	timeInterval search.TimestampInterval,
-) (*ffi.LogEventView, int, int, error) {
-	return deserializeWildcardMatch(deserializer, irBuf, mergedQuery, timeInterval)
-}
-
-// fourByteDeserializer contains both a common CLP IR deserializer and stores
-// the previously seen log event's timestamp. The previous timestamp is
-// necessary to calculate the current timestamp as four byte encoding only
-// encodes the timestamp delta between the current log event and the previous.
-type fourByteDeserializer struct {
-	commonDeserializer
-	prevTimestamp ffi.EpochTimeMs
-}
-
-// DeserializeLogEvent attempts to read the next log event from the IR stream in
-// irBuf, returning the deserialized [ffi.LogEventView], the position read to in
-// irBuf (the end of the log event in irBuf), and an error. On error returns:
-//   - nil *ffi.LogEventView
-//   - 0 position
-//   - [IrError] error: CLP failed to successfully deserialize
-//   - [EndOfIr] error: CLP found the IR stream EOF tag
-func (deserializer *fourByteDeserializer) DeserializeLogEvent(
-	irBuf []byte,
-) (*ffi.LogEventView, int, error) {
-	return deserializeLogEvent(deserializer, irBuf)
-}
-
-// DeserializeWildcardMatchWithTimeInterval attempts to read the next log event
-// from the IR stream in irBuf that matches mergedQuery within timeInterval. It
-// returns the deserialized [ffi.LogEventView], the position read to in irBuf
-// (the end of the log event in irBuf), the index of the matched query in
-// mergedQuery, and an error. On error returns:
-//   - nil *ffi.LogEventView
-//   - 0 position
-//   - -1 index
-//   - [IrError] error: CLP failed to successfully deserialize
-//   - [EndOfIr] error: CLP found the IR stream EOF tag
-func (deserializer *fourByteDeserializer) DeserializeWildcardMatchWithTimeInterval(
-	irBuf []byte,
-	mergedQuery search.MergedWildcardQuery,
-	timeInterval search.TimestampInterval,
-) (*ffi.LogEventView, int, int, error) {
-	return deserializeWildcardMatch(deserializer, irBuf, mergedQuery, timeInterval)
-}
-
-func deserializeLogEvent(
-	deserializer Deserializer,
-	irBuf []byte,
-) (*ffi.LogEventView, int, error) {
-	if 0 >= len(irBuf) {
-		return nil, 0, IncompleteIr
+	if userMetadata, ok := metadata[metadataUserDefinedMetadataKey].(map[string]any); ok {
+		preamble.userMetadata = normalizeJsonNumbers(userMetadata).(map[string]any)
 	}
-
-	var pos C.size_t
-	var event C.LogEventView
-	var err error
-	switch irs := deserializer.(type) {
-	case *eightByteDeserializer:
-		err = IrError(C.ir_deserializer_deserialize_eight_byte_log_event(
-			newCByteSpan(irBuf),
-			irs.cptr,
-			&pos,
-			&event,
-		))
-	case *fourByteDeserializer:
-		err = IrError(C.ir_deserializer_deserialize_four_byte_log_event(
-			newCByteSpan(irBuf),
-			irs.cptr,
-			&pos,
-			&event,
-		))
-	}
-	if Success != err {
-		return nil, 0, err
-	}
-
-	return &ffi.LogEventView{
-			LogMessageView: unsafe.String(
-				(*byte)((unsafe.Pointer)(event.m_log_message.m_data)),
-				event.m_log_message.m_size,
-			),
-			Timestamp: ffi.EpochTimeMs(event.m_timestamp),
-		},
-		int(pos),
-		nil
-}
-
-func deserializeWildcardMatch(
-	deserializer Deserializer,
-	irBuf []byte,
-	mergedQuery search.MergedWildcardQuery,
-	time search.TimestampInterval,
-) (*ffi.LogEventView, int, int, error) {
-	if 0 >= len(irBuf) {
-		return nil, 0, -1, IncompleteIr
-	}
-
-	var pos C.size_t
-	var event C.LogEventView
-	var match C.size_t
-	var err error
-	switch irs := deserializer.(type) {
-	case *eightByteDeserializer:
-		err = IrError(C.ir_deserializer_deserialize_eight_byte_wildcard_match(
-			newCByteSpan(irBuf),
-			irs.cptr,
-			C.TimestampInterval{C.int64_t(time.Lower), C.int64_t(time.Upper)},
-			newMergedWildcardQueryView(mergedQuery),
-			&pos,
-			&event,
-			&match,
-		))
-	case *fourByteDeserializer:
-		err = IrError(C.ir_deserializer_deserialize_four_byte_wildcard_match(
-			newCByteSpan(irBuf),
-			irs.cptr,
-			C.TimestampInterval{C.int64_t(time.Lower), C.int64_t(time.Upper)},
-			newMergedWildcardQueryView(mergedQuery),
-			&pos,
-			&event,
-			&match,
-		))
-	}
-	if Success != err {
-		return nil, 0, -1, err
-	}
-
-	return &ffi.LogEventView{
-			LogMessageView: unsafe.String(
-				(*byte)((unsafe.Pointer)(event.m_log_message.m_data)),
-				event.m_log_message.m_size,
-			),
-			Timestamp: ffi.EpochTimeMs(event.m_timestamp),
-		},
-		int(pos),
-		int(match),
-		nil
+	return preamble, nil
 }
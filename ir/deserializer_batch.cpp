@@ -0,0 +1,255 @@
+//go:build cgo && !purego
+
+#include "deserializer_batch.h"
+
+#include <cstddef>
+#include <cstdint>
+#include <string>
+#include <vector>
+
+#include "ffi_go/api_decoration.h"
+#include "ffi_go/defs.h"
+#include "ffi_go/ir/deserializer.h"
+#include "ffi_go/search/wildcard_query.h"
+
+namespace ffi_go::ir {
+namespace {
+// Error codes forwarded from ffi::ir_stream::IRErrorCode
+constexpr int cSuccess{0};
+constexpr int cCorruptedIr{3};
+constexpr int cIncompleteIr{4};
+
+/**
+ * Storage backing the results of the batch functions.
+ */
+struct DeserializerBatch {
+    std::vector<epoch_time_ms_t> m_timestamps;
+    std::string m_log_messages;
+    std::vector<size_t> m_log_message_ends;
+};
+
+/**
+ * Generic helper for ir_deserializer_deserialize_*_log_events
+ */
+template <class deserialize_log_event_fn>
+[[nodiscard]] auto deserialize_log_events(
+        deserialize_log_event_fn deserialize_log_event,
+        ByteSpan ir_view,
+        void* ir_deserializer,
+        void* ir_batch,
+        size_t max_log_events,
+        size_t* ir_pos,
+        StringView* log_messages,
+        SizetSpan* log_message_ends,
+        Int64tSpan* timestamps
+) -> int {
+    if (nullptr == ir_deserializer || nullptr == ir_batch || nullptr == ir_pos
+        || nullptr == log_messages || nullptr == log_message_ends || nullptr == timestamps)
+    {
+        return cCorruptedIr;
+    }
+    auto* batch{static_cast<DeserializerBatch*>(ir_batch)};
+    batch->m_timestamps.clear();
+    batch->m_log_messages.clear();
+    batch->m_log_message_ends.clear();
+
+    auto* const ir_data{static_cast<char*>(ir_view.m_data)};
+    size_t pos{0};
+    int err{cSuccess};
+    while (batch->m_timestamps.size() < max_log_events) {
+        size_t log_event_pos{0};
+        LogEventView log_event{};
+        err = deserialize_log_event(
+                ByteSpan{ir_data + pos, ir_view.m_size - pos},
+                ir_deserializer,
+                &log_event_pos,
+                &log_event
+        );
+        if (cSuccess != err) {
+            break;
+        }
+        pos += log_event_pos;
+        batch->m_log_messages.append(
+                log_event.m_log_message.m_data,
+                log_event.m_log_message.m_size
+        );
+        batch->m_log_message_ends.push_back(batch->m_log_messages.size());
+        batch->m_timestamps.push_back(log_event.m_timestamp);
+    }
+
+    *ir_pos = pos;
+    log_messages->m_data = batch->m_log_messages.data();
+    log_messages->m_size = batch->m_log_messages.size();
+    log_message_ends->m_data = batch->m_log_message_ends.data();
+    log_message_ends->m_size = batch->m_log_message_ends.size();
+    timestamps->m_data = batch->m_timestamps.data();
+    timestamps->m_size = batch->m_timestamps.size();
+    return err;
+}
+
+/**
+ * Generic helper for ir_deserializer_deserialize_*_wildcard_match_timestamps
+ */
+template <class deserialize_wildcard_match_fn>
+[[nodiscard]] auto deserialize_wildcard_match_timestamps(
+        deserialize_wildcard_match_fn deserialize_wildcard_match,
+        ByteSpan ir_view,
+        void* ir_deserializer,
+        void* ir_batch,
+        TimestampInterval time_interval,
+        MergedWildcardQueryView merged_query,
+        size_t max_matches,
+        epoch_time_ms_t* timestamp_ptr,
+        size_t* ir_pos,
+        Int64tSpan* timestamps
+) -> int {
+    if (nullptr == ir_deserializer || nullptr == ir_batch || nullptr == ir_pos
+        || nullptr == timestamps)
+    {
+        return cCorruptedIr;
+    }
+    auto* batch{static_cast<DeserializerBatch*>(ir_batch)};
+    batch->m_timestamps.clear();
+
+    auto* const ir_data{static_cast<char*>(ir_view.m_data)};
+    size_t pos{0};
+    int err{cSuccess};
+    while (batch->m_timestamps.size() < max_matches) {
+        epoch_time_ms_t const prev_timestamp{nullptr == timestamp_ptr ? 0 : *timestamp_ptr};
+        size_t match_pos{0};
+        LogEventView log_event{};
+        size_t matching_query{0};
+        err = deserialize_wildcard_match(
+                ByteSpan{ir_data + pos, ir_view.m_size - pos},
+                ir_deserializer,
+                time_interval,
+                merged_query,
+                &match_pos,
+                &log_event,
+                &matching_query
+        );
+        if (cSuccess != err) {
+            if (nullptr != timestamp_ptr && (cIncompleteIr == err || cCorruptedIr == err)) {
+                *timestamp_ptr = prev_timestamp;
+            }
+            break;
+        }
+        pos += match_pos;
+        batch->m_timestamps.push_back(log_event.m_timestamp);
+    }
+
+    *ir_pos = pos;
+    timestamps->m_data = batch->m_timestamps.data();
+    timestamps->m_size = batch->m_timestamps.size();
+    return err;
+}
+}  // namespace
+
+CLP_FFI_GO_METHOD auto ir_deserializer_batch_new() -> void* {
+    // NOLINTNEXTLINE(cppcoreguidelines-owning-memory)
+    return new DeserializerBatch{};
+}
+
+CLP_FFI_GO_METHOD auto ir_deserializer_batch_close(void* ir_batch) -> void {
+    // NOLINTNEXTLINE(cppcoreguidelines-owning-memory)
+    delete static_cast<DeserializerBatch*>(ir_batch);
+}
+
+CLP_FFI_GO_METHOD auto ir_deserializer_deserialize_eight_byte_log_events(
+        ByteSpan ir_view,
+        void* ir_deserializer,
+        void* ir_batch,
+        size_t max_log_events,
+        size_t* ir_pos,
+        StringView* log_messages,
+        SizetSpan* log_message_ends,
+        Int64tSpan* timestamps
+) -> int {
+    return deserialize_log_events(
+            ir_deserializer_deserialize_eight_byte_log_event,
+            ir_view,
+            ir_deserializer,
+            ir_batch,
+            max_log_events,
+            ir_pos,
+            log_messages,
+            log_message_ends,
+            timestamps
+    );
+}
+
+CLP_FFI_GO_METHOD auto ir_deserializer_deserialize_four_byte_log_events(
+        ByteSpan ir_view,
+        void* ir_deserializer,
+        void* ir_batch,
+        size_t max_log_events,
+        size_t* ir_pos,
+        StringView* log_messages,
+        SizetSpan* log_message_ends,
+        Int64tSpan* timestamps
+) -> int {
+    return deserialize_log_events(
+            ir_deserializer_deserialize_four_byte_log_event,
+            ir_view,
+            ir_deserializer,
+            ir_batch,
+            max_log_events,
+            ir_pos,
+            log_messages,
+            log_message_ends,
+            timestamps
+    );
+}
+
+CLP_FFI_GO_METHOD auto ir_deserializer_deserialize_eight_byte_wildcard_match_timestamps(
+        ByteSpan ir_view,
+        void* ir_deserializer,
+        void* ir_batch,
+        TimestampInterval time_interval,
+        MergedWildcardQueryView merged_query,
+        size_t max_matches,
+        size_t* ir_pos,
+        Int64tSpan* timestamps
+) -> int {
+    return deserialize_wildcard_match_timestamps(
+            ir_deserializer_deserialize_eight_byte_wildcard_match,
+            ir_view,
+            ir_deserializer,
+            ir_batch,
+            time_interval,
+            merged_query,
+            max_matches,
+            nullptr,
+            ir_pos,
+            timestamps
+    );
+}
+
+CLP_FFI_GO_METHOD auto ir_deserializer_deserialize_four_byte_wildcard_match_timestamps(
+        ByteSpan ir_view,
+        void* ir_deserializer,
+        void* ir_batch,
+        TimestampInterval time_interval,
+        MergedWildcardQueryView merged_query,
+        size_t max_matches,
+        epoch_time_ms_t* timestamp_ptr,
+        size_t* ir_pos,
+        Int64tSpan* timestamps
+) -> int {
+    if (nullptr == timestamp_ptr) {
+        return cCorruptedIr;
+    }
+    return deserialize_wildcard_match_timestamps(
+            ir_deserializer_deserialize_four_byte_wildcard_match,
+            ir_view,
+            ir_deserializer,
+            ir_batch,
+            time_interval,
+            merged_query,
+            max_matches,
+            timestamp_ptr,
+            ir_pos,
+            timestamps
+    );
+}
+}  // namespace ffi_go::ir
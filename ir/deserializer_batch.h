@@ -0,0 +1,167 @@
+//go:build cgo && !purego
+
+#ifndef FFI_GO_IR_DESERIALIZER_BATCH_H
+#define FFI_GO_IR_DESERIALIZER_BATCH_H
+// header must support C, making modernize checks inapplicable
+// NOLINTBEGIN(modernize-deprecated-headers)
+// NOLINTBEGIN(modernize-use-trailing-return-type)
+
+#include <stdint.h>
+#include <stdlib.h>
+
+#include "ffi_go/api_decoration.h"
+#include "ffi_go/defs.h"
+#include "ffi_go/search/wildcard_query.h"
+
+// The functions below deserialize many log events in a single call from Go by
+// repeatedly calling the per log event functions of ffi_go/ir/deserializer.h.
+// They only use the C API of the native library, so they are compiled with the
+// Go package and work with both the pre-built and external libraries.
+
+/**
+ * Create the storage (arena) backing the results of the batch functions of an
+ * ir::Deserializer. The results of a call are only valid until the next call
+ * using the same batch.
+ * @return The address of the new batch storage
+ */
+CLP_FFI_GO_METHOD void* ir_deserializer_batch_new();
+
+/**
+ * Clean up the storage created by ir_deserializer_batch_new.
+ * @param[in] ir_batch The address of the batch storage
+ */
+CLP_FFI_GO_METHOD void ir_deserializer_batch_close(void* ir_batch);
+
+/**
+ * Given a CLP IR buffer with eight byte encoding, repeatedly call
+ * ir_deserializer_deserialize_eight_byte_log_event, copying up to
+ * max_log_events log events into ir_batch. The log messages are stored one
+ * after the other in log_messages, with the end of each in log_message_ends.
+ * Deserialization stops at the first error, which includes a UTC offset change
+ * packet (as it is not supported by the native library). All pointer
+ * parameters must be non-null.
+ * @param[in] ir_view Byte buffer/slice containing CLP IR
+ * @param[in] ir_deserializer ir::Deserializer used as storage for each log
+ *     event
+ * @param[in] ir_batch Batch storage used as storage for the log events
+ * @param[in] max_log_events Maximum number of log events to deserialize
+ * @param[out] ir_pos Position in ir_view read to (the end of the last log
+ *     event)
+ * @param[out] log_messages Log messages stored in ir_batch
+ * @param[out] log_message_ends End of each log message in log_messages stored
+ *     in ir_batch
+ * @param[out] timestamps Timestamps of the log events stored in ir_batch
+ * @return ffi::ir_stream::IRErrorCode_Success if max_log_events were
+ *     deserialized
+ * @return The error code returned by the call that stopped deserialization
+ */
+CLP_FFI_GO_METHOD int ir_deserializer_deserialize_eight_byte_log_events(
+        ByteSpan ir_view,
+        void* ir_deserializer,
+        void* ir_batch,
+        size_t max_log_events,
+        size_t* ir_pos,
+        StringView* log_messages,
+        SizetSpan* log_message_ends,
+        Int64tSpan* timestamps
+);
+
+/**
+ * Given a CLP IR buffer with four byte encoding, repeatedly call
+ * ir_deserializer_deserialize_four_byte_log_event, copying up to
+ * max_log_events log events into ir_batch. The layout of the results is the
+ * same as ir_deserializer_deserialize_eight_byte_log_events. All pointer
+ * parameters must be non-null.
+ * @param[in] ir_view Byte buffer/slice containing CLP IR
+ * @param[in] ir_deserializer ir::Deserializer used as storage for each log
+ *     event
+ * @param[in] ir_batch Batch storage used as storage for the log events
+ * @param[in] max_log_events Maximum number of log events to deserialize
+ * @param[out] ir_pos Position in ir_view read to (the end of the last log
+ *     event)
+ * @param[out] log_messages Log messages stored in ir_batch
+ * @param[out] log_message_ends End of each log message in log_messages stored
+ *     in ir_batch
+ * @param[out] timestamps Timestamps of the log events stored in ir_batch
+ * @return ffi::ir_stream::IRErrorCode_Success if max_log_events were
+ *     deserialized
+ * @return The error code returned by the call that stopped deserialization
+ */
+CLP_FFI_GO_METHOD int ir_deserializer_deserialize_four_byte_log_events(
+        ByteSpan ir_view,
+        void* ir_deserializer,
+        void* ir_batch,
+        size_t max_log_events,
+        size_t* ir_pos,
+        StringView* log_messages,
+        SizetSpan* log_message_ends,
+        Int64tSpan* timestamps
+);
+
+/**
+ * Given a CLP IR buffer with eight byte encoding, repeatedly call
+ * ir_deserializer_deserialize_eight_byte_wildcard_match, collecting the
+ * timestamps of up to max_matches matching log events. Deserialization stops
+ * at the first error, which includes a UTC offset change packet (as it is not
+ * supported by the native library). All pointer parameters must be non-null.
+ * @param[in] ir_view Byte buffer/slice containing CLP IR
+ * @param[in] ir_deserializer ir::Deserializer used as storage for each match
+ * @param[in] ir_batch Batch storage used as storage for the timestamps
+ * @param[in] time_interval Timestamp interval: [lower, upper)
+ * @param[in] merged_query A concatenation of all queries to filter for; if
+ *     empty any log event as a match
+ * @param[in] max_matches Maximum number of matches to collect
+ * @param[out] ir_pos Position in ir_view read to (the end of the last match)
+ * @param[out] timestamps Timestamps of the matches stored in ir_batch
+ * @return ffi::ir_stream::IRErrorCode_Success if max_matches were collected
+ * @return The error code returned by the call that stopped deserialization
+ */
+CLP_FFI_GO_METHOD int ir_deserializer_deserialize_eight_byte_wildcard_match_timestamps(
+        ByteSpan ir_view,
+        void* ir_deserializer,
+        void* ir_batch,
+        TimestampInterval time_interval,
+        MergedWildcardQueryView merged_query,
+        size_t max_matches,
+        size_t* ir_pos,
+        Int64tSpan* timestamps
+);
+
+/**
+ * Given a CLP IR buffer with four byte encoding, repeatedly call
+ * ir_deserializer_deserialize_four_byte_wildcard_match, collecting the
+ * timestamps of up to max_matches matching log events. Deserialization stops
+ * at the first error, which includes a UTC offset change packet (as it is not
+ * supported by the native library). If the call stopping deserialization
+ * fails, the timestamp of ir_deserializer is rolled back to the timestamp of
+ * the last match, as the deltas of the log events it skipped over were
+ * applied. All pointer parameters must be non-null.
+ * @param[in] ir_view Byte buffer/slice containing CLP IR
+ * @param[in] ir_deserializer ir::Deserializer used as storage for each match
+ * @param[in] ir_batch Batch storage used as storage for the timestamps
+ * @param[in] time_interval Timestamp interval: [lower, upper)
+ * @param[in] merged_query A concatenation of all queries to filter for; if
+ *     empty any log event as a match
+ * @param[in] max_matches Maximum number of matches to collect
+ * @param[in] timestamp_ptr Address of m_timestamp inside ir_deserializer, as
+ *     returned by ir_deserializer_new_deserializer_with_preamble
+ * @param[out] ir_pos Position in ir_view read to (the end of the last match)
+ * @param[out] timestamps Timestamps of the matches stored in ir_batch
+ * @return ffi::ir_stream::IRErrorCode_Success if max_matches were collected
+ * @return The error code returned by the call that stopped deserialization
+ */
+CLP_FFI_GO_METHOD int ir_deserializer_deserialize_four_byte_wildcard_match_timestamps(
+        ByteSpan ir_view,
+        void* ir_deserializer,
+        void* ir_batch,
+        TimestampInterval time_interval,
+        MergedWildcardQueryView merged_query,
+        size_t max_matches,
+        epoch_time_ms_t* timestamp_ptr,
+        size_t* ir_pos,
+        Int64tSpan* timestamps
+);
+
+// NOLINTEND(modernize-use-trailing-return-type)
+// NOLINTEND(modernize-deprecated-headers)
+#endif  // FFI_GO_IR_DESERIALIZER_BATCH_H
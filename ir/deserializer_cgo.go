@@ -0,0 +1,652 @@
+//go:build cgo && !purego
+
+package ir
+
+/*
+#include <ffi_go/defs.h>
+#include <ffi_go/ir/deserializer.h>
+#include <ffi_go/search/wildcard_query.h>
+#include "deserializer_batch.h"
+*/
+import "C"
+
+import (
+	"math"
+	"runtime"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+	"github.com/y-scope/clp-ffi-go/search"
+)
+
+// DeserializePreamble attempts to read an IR stream preamble from irBuf,
+// returning an Deserializer (of the correct stream encoding size), the position
+// read to in irBuf (the end of the preamble), and an error. Note the metadata
+// stored in the preamble is sparse and certain fields in TimestampInfo may be 0
+// value. The IR protocol version is not validated (see [ValidateVersion]). On
+// error returns:
+//   - nil Deserializer
+//   - 0 position
+//   - [IrError] error: CLP failed to successfully deserialize
+//   - [encoding/json] error: unmarshalling the metadata failed
+func DeserializePreamble(irBuf []byte) (Deserializer, int, error) {
+	if 0 >= len(irBuf) {
+		return nil, 0, IncompleteIr
+	}
+
+	// The version is not validated here; see ValidateVersion and Reader.
+
+	var pos C.size_t
+	var irEncoding C.int8_t
+	var metadataType C.int8_t
+	var metadataPos C.size_t
+	var metadataSize C.uint16_t
+	var deserializerCptr unsafe.Pointer
+	var timestampCptr unsafe.Pointer
+	if err := IrError(C.ir_deserializer_new_deserializer_with_preamble(
+		newCByteSpan(irBuf),
+		&pos,
+		&irEncoding,
+		&metadataType,
+		&metadataPos,
+		&metadataSize,
+		&deserializerCptr,
+		&timestampCptr,
+	)); Success != err {
+		return nil, int(pos), err
+	}
+
+	if metadataType != 1 {
+		return nil, 0, UnsupportedVersion
+	}
+
+	metadata, err := unmarshalPreambleMetadata(
+		irBuf[metadataPos : metadataPos+C.size_t(metadataSize)],
+	)
+	if nil != err {
+		return nil, 0, err
+	}
+	// The native library only supports unstructured IR streams, so key-value
+	// pair IR streams are deserialized in Go.
+	if kvMetadataVersionValue == metadata.version {
+		C.ir_deserializer_close(deserializerCptr)
+		return newKVDeserializer(1 == irEncoding, metadata), int(pos), nil
+	}
+	tsInfo, refTs := metadata.tsInfo, metadata.refTs
+
+	var deserializer Deserializer
+	common := commonDeserializer{
+		tsInfo:       tsInfo,
+		userMetadata: metadata.userMetadata,
+		version:      metadata.version,
+		cptr:         deserializerCptr,
+		batchCptr:    C.ir_deserializer_batch_new(),
+	}
+	if irEncoding == 1 {
+		*(*ffi.EpochTimeMs)(timestampCptr) = refTs
+		irs := &fourByteDeserializer{common, refTs, timestampCptr}
+		irs.track()
+		deserializer = irs
+	} else {
+		irs := &eightByteDeserializer{common}
+		irs.track()
+		deserializer = irs
+	}
+
+	return deserializer, int(pos), nil
+}
+
+// commonDeserializer contains fields common to all types of CLP IR encoding.
+// TimestampInfo stores information common to all timestamps found in the IR.
+// userMetadata and version store the user-defined metadata and IR protocol
+// version found in the IR preamble. utcOffset stores the UTC offset of the
+// previous log event, as the native library does not support UTC offset
+// changes and they are read in Go.
+// cptr holds a reference to the underlying C++ objected used as backing storage
+// for the Views returned by the deserializer and batchCptr to the C++ storage
+// backing the results of the batch functions (see deserializer_batch.h). Close must be
+// called to free this underlying memory and failure to do so will result in a
+// memory leak. ref references the deserializer's leak tracking
+// (see [SetLeakTracking]).
+type commonDeserializer struct {
+	tsInfo       TimestampInfo
+	userMetadata map[string]any
+	version      string
+	utcOffset    time.Duration
+	cptr         unsafe.Pointer
+	batchCptr    unsafe.Pointer
+	ref          leakRef
+}
+
+// track tracks the deserializer for leaks. Its C++ deserializer is not deleted
+// once it is garbage collected, as returned views may still reference it.
+func (deserializer *commonDeserializer) track() {
+	deserializer.ref = trackLeaks(deserializer, "Deserializer", nil)
+}
+
+// Close will delete the underlying C++ allocated memory used by the
+// deserializer. Failure to call Close will result in a memory leak.
+func (deserializer *commonDeserializer) Close() error {
+	if nil != deserializer.cptr {
+		deserializer.ref.untrack()
+		C.ir_deserializer_close(deserializer.cptr)
+		C.ir_deserializer_batch_close(deserializer.batchCptr)
+		deserializer.cptr = nil
+		deserializer.batchCptr = nil
+	}
+	return nil
+}
+
+// Returns the TimestampInfo used by the Deserializer.
+func (deserializer commonDeserializer) TimestampInfo() TimestampInfo {
+	return deserializer.tsInfo
+}
+
+// Returns the user-defined metadata of the IR stream.
+func (deserializer commonDeserializer) Metadata() map[string]any {
+	return deserializer.userMetadata
+}
+
+// Returns the IR protocol version of the IR stream.
+func (deserializer commonDeserializer) Version() string {
+	return deserializer.version
+}
+
+// Returns the UTC offset of the most recently deserialized log event.
+func (deserializer commonDeserializer) UtcOffset() time.Duration {
+	return deserializer.utcOffset
+}
+
+type eightByteDeserializer struct {
+	commonDeserializer
+}
+
+// Returns [EncodingEightByte].
+func (deserializer *eightByteDeserializer) Encoding() Encoding {
+	return EncodingEightByte
+}
+
+// DeserializeLogEvent attempts to read the next log event from the IR stream in
+// irBuf, returning the deserialized [ffi.LogEventView], the position read to in
+// irBuf (the end of the log event in irBuf), and an error. On error returns:
+//   - nil *ffi.LogEventView
+//   - 0 position
+//   - [IrError] error: CLP failed to successfully deserialize
+//   - [EndOfIr] error: CLP found the IR stream EOF tag
+func (deserializer *eightByteDeserializer) DeserializeLogEvent(
+	irBuf []byte,
+) (*ffi.LogEventView, int, error) {
+	return deserializeLogEvent(deserializer, irBuf)
+}
+
+// DeserializeLogEvents attempts to read up to max log events (every complete
+// log event in irBuf if max is not positive) from the IR stream in irBuf in a
+// single cgo call, returning the deserialized [ffi.LogEventView]s, the position
+// read to in irBuf (the end of the last log event in irBuf), and an error. The
+// log messages are copied into memory owned by Go (see [BatchDeserializer]).
+// Deserialization stops early at the first error, which is only returned if
+// no log event was deserialized before it, so that the next call returns it
+// instead (e.g. [IncompleteIr] once irBuf ends). On error returns:
+//   - nil []ffi.LogEventView
+//   - 0 position
+//   - [IrError] error: CLP failed to successfully deserialize
+//   - [EndOfIr] error: CLP found the IR stream EOF tag
+func (deserializer *eightByteDeserializer) DeserializeLogEvents(
+	irBuf []byte,
+	max int,
+) ([]ffi.LogEventView, int, error) {
+	return deserializeNativeLogEvents(deserializer, irBuf, max)
+}
+
+// DeserializeWildcardMatchWithTimeInterval attempts to read the next log event
+// from the IR stream in irBuf that matches mergedQuery within timeInterval. It
+// returns the deserialized [ffi.LogEventView], the position read to in irBuf
+// (the end of the log event in irBuf), the index of the matched query in
+// mergedQuery, and an error. On error returns:
+//   - nil *ffi.LogEventView
+//   - 0 position
+//   - -1 index
+//   - [IrError] error: CLP failed to successfully deserialize
+//   - [EndOfIr] error: CLP found the IR stream EOF tag
+func (deserializer *eightByteDeserializer) DeserializeWildcardMatchWithTimeInterval(
+	irBuf []byte,
+	mergedQuery search.MergedWildcardQuery,
+	timeInterval search.TimestampInterval,
+) (*ffi.LogEventView, int, int, error) {
+	return deserializeWildcardMatch(deserializer, irBuf, mergedQuery, timeInterval)
+}
+
+// deserializeWildcardMatchTimestamps implements wildcardMatchTimestamper.
+func (deserializer *eightByteDeserializer) deserializeWildcardMatchTimestamps(
+	irBuf []byte,
+	mergedQuery search.MergedWildcardQuery,
+	timeInterval search.TimestampInterval,
+	max int,
+) ([]ffi.EpochTimeMs, int, error) {
+	return deserializeWildcardMatchTimestamps(deserializer, irBuf, mergedQuery, timeInterval, max)
+}
+
+// fourByteDeserializer contains both a common CLP IR deserializer and stores
+// the previously seen log event's timestamp. The previous timestamp is
+// necessary to calculate the current timestamp as four byte encoding only
+// encodes the timestamp delta between the current log event and the previous.
+// timestampCptr references the timestamp stored inside the underlying C++
+// object, which is the one actually used (and updated) during deserialization.
+type fourByteDeserializer struct {
+	commonDeserializer
+	prevTimestamp ffi.EpochTimeMs
+	timestampCptr unsafe.Pointer
+}
+
+// Returns [EncodingFourByte].
+func (deserializer *fourByteDeserializer) Encoding() Encoding {
+	return EncodingFourByte
+}
+
+// DeserializeLogEvent attempts to read the next log event from the IR stream in
+// irBuf, returning the deserialized [ffi.LogEventView], the position read to in
+// irBuf (the end of the log event in irBuf), and an error. On error returns:
+//   - nil *ffi.LogEventView
+//   - 0 position
+//   - [IrError] error: CLP failed to successfully deserialize
+//   - [EndOfIr] error: CLP found the IR stream EOF tag
+func (deserializer *fourByteDeserializer) DeserializeLogEvent(
+	irBuf []byte,
+) (*ffi.LogEventView, int, error) {
+	return deserializeLogEvent(deserializer, irBuf)
+}
+
+// DeserializeLogEvents attempts to read up to max log events (every complete
+// log event in irBuf if max is not positive) from the IR stream in irBuf in a
+// single cgo call, returning the deserialized [ffi.LogEventView]s, the position
+// read to in irBuf (the end of the last log event in irBuf), and an error. The
+// log messages are copied into memory owned by Go (see [BatchDeserializer]).
+// Deserialization stops early at the first error, which is only returned if
+// no log event was deserialized before it, so that the next call returns it
+// instead (e.g. [IncompleteIr] once irBuf ends). On error returns:
+//   - nil []ffi.LogEventView
+//   - 0 position
+//   - [IrError] error: CLP failed to successfully deserialize
+//   - [EndOfIr] error: CLP found the IR stream EOF tag
+func (deserializer *fourByteDeserializer) DeserializeLogEvents(
+	irBuf []byte,
+	max int,
+) ([]ffi.LogEventView, int, error) {
+	return deserializeNativeLogEvents(deserializer, irBuf, max)
+}
+
+// DeserializeWildcardMatchWithTimeInterval attempts to read the next log event
+// from the IR stream in irBuf that matches mergedQuery within timeInterval. It
+// returns the deserialized [ffi.LogEventView], the position read to in irBuf
+// (the end of the log event in irBuf), the index of the matched query in
+// mergedQuery, and an error. On error returns:
+//   - nil *ffi.LogEventView
+//   - 0 position
+//   - -1 index
+//   - [IrError] error: CLP failed to successfully deserialize
+//   - [EndOfIr] error: CLP found the IR stream EOF tag
+func (deserializer *fourByteDeserializer) DeserializeWildcardMatchWithTimeInterval(
+	irBuf []byte,
+	mergedQuery search.MergedWildcardQuery,
+	timeInterval search.TimestampInterval,
+) (*ffi.LogEventView, int, int, error) {
+	return deserializeWildcardMatch(deserializer, irBuf, mergedQuery, timeInterval)
+}
+
+// deserializeWildcardMatchTimestamps implements wildcardMatchTimestamper.
+func (deserializer *fourByteDeserializer) deserializeWildcardMatchTimestamps(
+	irBuf []byte,
+	mergedQuery search.MergedWildcardQuery,
+	timeInterval search.TimestampInterval,
+	max int,
+) ([]ffi.EpochTimeMs, int, error) {
+	return deserializeWildcardMatchTimestamps(deserializer, irBuf, mergedQuery, timeInterval, max)
+}
+
+// deserializeLogEvent implements DeserializeLogEvent for the native
+// deserializers. The UTC offset change packets preceding the log event are read
+// in Go and applied once the log event is deserialized.
+func deserializeLogEvent(
+	deserializer Deserializer,
+	irBuf []byte,
+) (*ffi.LogEventView, int, error) {
+	utcOffset, offsetPos, err := readUtcOffsetChanges(irBuf, deserializer.UtcOffset())
+	if nil != err {
+		return nil, 0, err
+	}
+	irBuf = irBuf[offsetPos:]
+	if 0 >= len(irBuf) {
+		return nil, 0, IncompleteIr
+	}
+
+	var pos C.size_t
+	var event C.LogEventView
+	var common *commonDeserializer
+	switch irs := deserializer.(type) {
+	case *eightByteDeserializer:
+		common = &irs.commonDeserializer
+		err = IrError(C.ir_deserializer_deserialize_eight_byte_log_event(
+			newCByteSpan(irBuf),
+			irs.cptr,
+			&pos,
+			&event,
+		))
+	case *fourByteDeserializer:
+		common = &irs.commonDeserializer
+		err = IrError(C.ir_deserializer_deserialize_four_byte_log_event(
+			newCByteSpan(irBuf),
+			irs.cptr,
+			&pos,
+			&event,
+		))
+	}
+	runtime.KeepAlive(deserializer)
+	if Success != err {
+		return nil, 0, err
+	}
+	common.utcOffset = utcOffset
+
+	return &ffi.LogEventView{
+			LogMessageView: unsafe.String(
+				(*byte)((unsafe.Pointer)(event.m_log_message.m_data)),
+				event.m_log_message.m_size,
+			),
+			Timestamp: ffi.EpochTimeMs(event.m_timestamp),
+		},
+		offsetPos + int(pos),
+		nil
+}
+
+// deserializeNativeLogEvents implements DeserializeLogEvents for the native
+// deserializers. The log events are copied into the deserializer's batch
+// storage (see deserializer_batch.h) by a single cgo call, from which their log
+// messages are copied into one string owned by Go, of which the returned views
+// are slices. The native library
+// cannot deserialize UTC offset change packets, so if it fails to deserialize
+// the first log event in irBuf, it is instead deserialized on its own by
+// [deserializeLogEvent].
+func deserializeNativeLogEvents(
+	deserializer Deserializer,
+	irBuf []byte,
+	max int,
+) ([]ffi.LogEventView, int, error) {
+	if 0 >= max {
+		max = math.MaxInt
+	}
+	var pos C.size_t
+	var logMessages C.StringView
+	var logMessageEnds C.SizetSpan
+	var timestamps C.Int64tSpan
+	var err IrError
+	switch irs := deserializer.(type) {
+	case *eightByteDeserializer:
+		err = IrError(C.ir_deserializer_deserialize_eight_byte_log_events(
+			newCByteSpan(irBuf),
+			irs.cptr,
+			irs.batchCptr,
+			C.size_t(max),
+			&pos,
+			&logMessages,
+			&logMessageEnds,
+			&timestamps,
+		))
+	case *fourByteDeserializer:
+		err = IrError(C.ir_deserializer_deserialize_four_byte_log_events(
+			newCByteSpan(irBuf),
+			irs.cptr,
+			irs.batchCptr,
+			C.size_t(max),
+			&pos,
+			&logMessages,
+			&logMessageEnds,
+			&timestamps,
+		))
+	}
+	if 0 == timestamps.m_size {
+		if CorruptedIr != err {
+			return nil, 0, err
+		}
+		event, n, err := deserializeLogEvent(deserializer, irBuf)
+		if nil != err {
+			return nil, 0, err
+		}
+		return []ffi.LogEventView{{
+			LogMessageView: strings.Clone(event.LogMessageView),
+			Timestamp:      event.Timestamp,
+		}}, n, nil
+	}
+
+	text := strings.Clone(
+		unsafe.String((*byte)(unsafe.Pointer(logMessages.m_data)), logMessages.m_size),
+	)
+	ends := unsafe.Slice((*C.size_t)(logMessageEnds.m_data), logMessageEnds.m_size)
+	timestampsView := unsafe.Slice(
+		(*ffi.EpochTimeMs)(unsafe.Pointer(timestamps.m_data)),
+		timestamps.m_size,
+	)
+	events := make([]ffi.LogEventView, len(ends))
+	start := 0
+	for i, end := range ends {
+		events[i] = ffi.LogEventView{
+			LogMessageView: text[start:end],
+			Timestamp:      timestampsView[i],
+		}
+		start = int(end)
+	}
+	runtime.KeepAlive(deserializer)
+	return events, int(pos), nil
+}
+
+// deserializeWildcardMatch implements DeserializeWildcardMatchWithTimeInterval
+// for the native deserializers. The native library cannot skip over UTC offset
+// change packets, so if it fails to deserialize irBuf the log events are
+// instead deserialized and matched one at a time by
+// [deserializeWildcardMatchByEvent].
+func deserializeWildcardMatch(
+	deserializer Deserializer,
+	irBuf []byte,
+	mergedQuery search.MergedWildcardQuery,
+	interval search.TimestampInterval,
+) (*ffi.LogEventView, int, int, error) {
+	utcOffset, offsetPos, err := readUtcOffsetChanges(irBuf, deserializer.UtcOffset())
+	if nil != err {
+		return nil, 0, -1, err
+	}
+	if 0 >= len(irBuf[offsetPos:]) {
+		return nil, 0, -1, IncompleteIr
+	}
+
+	var pos C.size_t
+	var event C.LogEventView
+	var match C.size_t
+	var common *commonDeserializer
+	switch irs := deserializer.(type) {
+	case *eightByteDeserializer:
+		common = &irs.commonDeserializer
+		err = IrError(C.ir_deserializer_deserialize_eight_byte_wildcard_match(
+			newCByteSpan(irBuf[offsetPos:]),
+			irs.cptr,
+			C.TimestampInterval{C.int64_t(interval.Lower), C.int64_t(interval.Upper)},
+			newMergedWildcardQueryView(mergedQuery),
+			&pos,
+			&event,
+			&match,
+		))
+	case *fourByteDeserializer:
+		common = &irs.commonDeserializer
+		// The C++ deserializer applies the timestamp delta of every log event
+		// it skips over. If irBuf ends before a match is found the same log
+		// events will be deserialized again once more IR is available, so the
+		// timestamp must be rolled back to avoid applying their deltas twice.
+		// The same applies if the log events are deserialized again one at a
+		// time.
+		prevTimestamp := *(*ffi.EpochTimeMs)(irs.timestampCptr)
+		err = IrError(C.ir_deserializer_deserialize_four_byte_wildcard_match(
+			newCByteSpan(irBuf[offsetPos:]),
+			irs.cptr,
+			C.TimestampInterval{C.int64_t(interval.Lower), C.int64_t(interval.Upper)},
+			newMergedWildcardQueryView(mergedQuery),
+			&pos,
+			&event,
+			&match,
+		))
+		if IncompleteIr == err || CorruptedIr == err {
+			*(*ffi.EpochTimeMs)(irs.timestampCptr) = prevTimestamp
+		}
+	}
+	runtime.KeepAlive(deserializer)
+	if CorruptedIr == err {
+		return deserializeWildcardMatchByEvent(deserializer, irBuf, mergedQuery, interval)
+	}
+	if Success != err {
+		return nil, 0, -1, err
+	}
+	common.utcOffset = utcOffset
+
+	return &ffi.LogEventView{
+			LogMessageView: unsafe.String(
+				(*byte)((unsafe.Pointer)(event.m_log_message.m_data)),
+				event.m_log_message.m_size,
+			),
+			Timestamp: ffi.EpochTimeMs(event.m_timestamp),
+		},
+		offsetPos + int(pos),
+		int(match),
+		nil
+}
+
+// deserializeWildcardMatchTimestamps implements wildcardMatchTimestamper for
+// the native deserializers, finding the matches in a single call to the native
+// library. The returned timestamps are only valid until the next call.
+func deserializeWildcardMatchTimestamps(
+	deserializer Deserializer,
+	irBuf []byte,
+	mergedQuery search.MergedWildcardQuery,
+	interval search.TimestampInterval,
+	max int,
+) ([]ffi.EpochTimeMs, int, error) {
+	var pos C.size_t
+	var timestamps C.Int64tSpan
+	var err IrError
+	switch irs := deserializer.(type) {
+	case *eightByteDeserializer:
+		err = IrError(C.ir_deserializer_deserialize_eight_byte_wildcard_match_timestamps(
+			newCByteSpan(irBuf),
+			irs.cptr,
+			irs.batchCptr,
+			C.TimestampInterval{C.int64_t(interval.Lower), C.int64_t(interval.Upper)},
+			newMergedWildcardQueryView(mergedQuery),
+			C.size_t(max),
+			&pos,
+			&timestamps,
+		))
+	case *fourByteDeserializer:
+		err = IrError(C.ir_deserializer_deserialize_four_byte_wildcard_match_timestamps(
+			newCByteSpan(irBuf),
+			irs.cptr,
+			irs.batchCptr,
+			C.TimestampInterval{C.int64_t(interval.Lower), C.int64_t(interval.Upper)},
+			newMergedWildcardQueryView(mergedQuery),
+			C.size_t(max),
+			(*C.epoch_time_ms_t)(irs.timestampCptr),
+			&pos,
+			&timestamps,
+		))
+	}
+	runtime.KeepAlive(deserializer)
+	var view []ffi.EpochTimeMs
+	if 0 != timestamps.m_size {
+		view = unsafe.Slice((*ffi.EpochTimeMs)(unsafe.Pointer(timestamps.m_data)), timestamps.m_size)
+	}
+	if Success != err {
+		return view, int(pos), err
+	}
+	return view, int(pos), nil
+}
+
+// deserializeWildcardMatchByEvent implements deserializeWildcardMatch by
+// deserializing each log event with [deserializeLogEvent], so any UTC offset
+// change packets between them are read. The return values are the same as
+// deserializeWildcardMatch.
+func deserializeWildcardMatchByEvent(
+	deserializer Deserializer,
+	irBuf []byte,
+	mergedQuery search.MergedWildcardQuery,
+	interval search.TimestampInterval,
+) (*ffi.LogEventView, int, int, error) {
+	// If irBuf ends before a match is found the same log events will be
+	// deserialized again once more IR is available, so the timestamp and UTC
+	// offset must be rolled back.
+	prevTimestamp, _ := fourByteTimestamp(deserializer)
+	utcOffset := deserializer.UtcOffset()
+	pos := 0
+	for {
+		event, n, err := deserializeLogEvent(deserializer, irBuf[pos:])
+		if IncompleteIr == err {
+			switch irs := deserializer.(type) {
+			case *eightByteDeserializer:
+				irs.utcOffset = utcOffset
+			case *fourByteDeserializer:
+				irs.utcOffset = utcOffset
+				*(*ffi.EpochTimeMs)(irs.timestampCptr) = prevTimestamp
+			}
+		}
+		if nil != err {
+			return nil, 0, -1, err
+		}
+		pos += n
+
+		if interval.Upper <= event.Timestamp {
+			return nil, 0, -1, QueryNotFound
+		}
+		if interval.Lower > event.Timestamp {
+			continue
+		}
+		if idx, ok := mergedQuery.Match(event.LogMessageView); ok {
+			return event, pos, idx, nil
+		}
+	}
+}
+
+// fourByteTimestamp returns the timestamp of the previous log event (or the
+// reference timestamp if there is none) tracked by deserializer, if it
+// deserializes four byte encoded IR. Returns:
+//   - four byte encoded IR: previous timestamp, true
+//   - otherwise: 0, false
+func fourByteTimestamp(deserializer Deserializer) (ffi.EpochTimeMs, bool) {
+	switch irs := deserializer.(type) {
+	case *fourByteDeserializer:
+		return *(*ffi.EpochTimeMs)(irs.timestampCptr), true
+	case *goDeserializer[FourByteEncoding]:
+		return irs.prevTimestamp, true
+	}
+	return 0, false
+}
+
+// setFourByteTimestamp sets the timestamp of the previous log event tracked by
+// deserializer to timestamp, if it deserializes four byte encoded IR.
+func setFourByteTimestamp(deserializer Deserializer, timestamp ffi.EpochTimeMs) {
+	switch irs := deserializer.(type) {
+	case *fourByteDeserializer:
+		*(*ffi.EpochTimeMs)(irs.timestampCptr) = timestamp
+	case *goDeserializer[FourByteEncoding]:
+		irs.prevTimestamp = timestamp
+	}
+}
+
+// setUtcOffset sets the UTC offset of the previous log event tracked by
+// deserializer, if it deserializes unstructured IR.
+func setUtcOffset(deserializer Deserializer, utcOffset time.Duration) {
+	switch irs := deserializer.(type) {
+	case *eightByteDeserializer:
+		irs.utcOffset = utcOffset
+	case *fourByteDeserializer:
+		irs.utcOffset = utcOffset
+	case *goDeserializer[EightByteEncoding]:
+		irs.utcOffset = utcOffset
+	case *goDeserializer[FourByteEncoding]:
+		irs.utcOffset = utcOffset
+	}
+}
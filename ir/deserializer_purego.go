@@ -0,0 +1,54 @@
+//go:build !cgo || purego
+
+package ir
+
+import (
+	"time"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+)
+
+// DeserializePreamble attempts to read an IR stream preamble from irBuf,
+// returning an Deserializer (of the correct stream encoding size), the position
+// read to in irBuf (the end of the preamble), and an error. Note the metadata
+// stored in the preamble is sparse and certain fields in TimestampInfo may be 0
+// value. The IR protocol version is not validated (see [ValidateVersion]). On
+// error returns:
+//   - nil Deserializer
+//   - 0 position
+//   - [IrError] error: CLP failed to successfully deserialize
+//   - [encoding/json] error: unmarshalling the metadata failed
+func DeserializePreamble(irBuf []byte) (Deserializer, int, error) {
+	return goDeserializePreamble(irBuf)
+}
+
+// fourByteTimestamp returns the timestamp of the previous log event (or the
+// reference timestamp if there is none) tracked by deserializer, if it
+// deserializes four byte encoded IR. Returns:
+//   - four byte encoded IR: previous timestamp, true
+//   - otherwise: 0, false
+func fourByteTimestamp(deserializer Deserializer) (ffi.EpochTimeMs, bool) {
+	if irs, ok := deserializer.(*goDeserializer[FourByteEncoding]); ok {
+		return irs.prevTimestamp, true
+	}
+	return 0, false
+}
+
+// setFourByteTimestamp sets the timestamp of the previous log event tracked by
+// deserializer to timestamp, if it deserializes four byte encoded IR.
+func setFourByteTimestamp(deserializer Deserializer, timestamp ffi.EpochTimeMs) {
+	if irs, ok := deserializer.(*goDeserializer[FourByteEncoding]); ok {
+		irs.prevTimestamp = timestamp
+	}
+}
+
+// setUtcOffset sets the UTC offset of the previous log event tracked by
+// deserializer, if it deserializes unstructured IR.
+func setUtcOffset(deserializer Deserializer, utcOffset time.Duration) {
+	switch irs := deserializer.(type) {
+	case *goDeserializer[EightByteEncoding]:
+		irs.utcOffset = utcOffset
+	case *goDeserializer[FourByteEncoding]:
+		irs.utcOffset = utcOffset
+	}
+}
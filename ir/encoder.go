@@ -1,13 +1,6 @@
 package ir
 
-/*
-#include <ffi_go/ir/encoder.h>
-*/
-import "C"
-
 import (
-	"unsafe"
-
 	"github.com/y-scope/clp-ffi-go/ffi"
 )
 
@@ -19,84 +12,61 @@ type Encoder[T EightByteEncoding | FourByteEncoding] interface {
 	Close() error
 }
 
-// Return a new Encoder that produces IR using [EightByteEncoding].
-func EightByteEncoder() (Encoder[EightByteEncoding], error) {
-	return &eightByteEncoder{C.ir_encoder_eight_byte_new()}, nil
-}
-
-// Return a new Encoder that produces IR using [FourByteEncoding].
-func FourByteEncoder() (Encoder[FourByteEncoding], error) {
-	return &fourByteEncoder{C.ir_encoder_four_byte_new()}, nil
-}
-
-type eightByteEncoder struct {
-	cptr unsafe.Pointer
-}
-
-// Close will delete the underlying C++ allocated memory used by the
-// deserializer. Failure to call Close will result in a memory leak.
-func (encoder *eightByteEncoder) Close() error {
-	if nil != encoder.cptr {
-		C.ir_encoder_eight_byte_close(encoder.cptr)
-		encoder.cptr = nil
-	}
-	return nil
+// A BatchEncoder is an [Encoder] that can also encode many log messages in one
+// call, which every Encoder of this package implements. It is separate from
+// Encoder so that implementations outside this package remain Encoders.
+// EncodeLogMessages encodes logMessages, copying the results into contiguous
+// storage owned by Go, so they remain valid after later calls.
+type BatchEncoder[T EightByteEncoding | FourByteEncoding] interface {
+	Encoder[T]
+	EncodeLogMessages(logMessages []ffi.LogMessage) ([]LogMessage[T], error)
 }
 
-// Encode a log message into CLP IR, returning a view of the encoded message.
-func (encoder *eightByteEncoder) EncodeLogMessage(
-	logMessage ffi.LogMessage,
-) (*LogMessageView[EightByteEncoding], error) {
-	var logtype C.StringView
-	var vars C.Int64tSpan
-	var dictVars C.StringView
-	var dictVarEndOffsets C.Int32tSpan
-	err := IrError(C.ir_encoder_encode_eight_byte_log_message(
-		newCStringView(logMessage),
-		encoder.cptr,
-		&logtype,
-		&vars,
-		&dictVars,
-		&dictVarEndOffsets,
-	))
-	if Success != err {
-		return nil, EncodeError
+// encodeLogMessages encodes each log message with encoder, copying the results
+// into contiguous storage owned by Go: one buffer for all logtypes and
+// dictionary variables, one for all encoded variables, and one for all
+// dictionary variable offsets. The returned LogMessages are slices of this
+// storage.
+func encodeLogMessages[T EightByteEncoding | FourByteEncoding](
+	encoder Encoder[T],
+	logMessages []ffi.LogMessage,
+) ([]LogMessage[T], error) {
+	type bounds struct {
+		logtypeEnd  int
+		dictVarsEnd int
+		varsEnd     int
+		offsetsEnd  int
 	}
-	return newLogMessageView[EightByteEncoding](logtype, vars, dictVars, dictVarEndOffsets), nil
-}
-
-type fourByteEncoder struct {
-	cptr unsafe.Pointer
-}
-
-// Close will delete the underlying C++ allocated memory used by the
-// deserializer. Failure to call Close will result in a memory leak.
-func (encoder *fourByteEncoder) Close() error {
-	if nil != encoder.cptr {
-		C.ir_encoder_four_byte_close(encoder.cptr)
-		encoder.cptr = nil
+	msgBounds := make([]bounds, len(logMessages))
+	var text []byte
+	var vars []T
+	var offsets []int32
+	for i, logMessage := range logMessages {
+		msgView, err := encoder.EncodeLogMessage(logMessage)
+		if nil != err {
+			return nil, err
+		}
+		text = append(text, msgView.Logtype...)
+		msgBounds[i].logtypeEnd = len(text)
+		text = append(text, msgView.DictVars...)
+		msgBounds[i].dictVarsEnd = len(text)
+		vars = append(vars, msgView.Vars...)
+		msgBounds[i].varsEnd = len(vars)
+		offsets = append(offsets, msgView.DictVarEndOffsets...)
+		msgBounds[i].offsetsEnd = len(offsets)
 	}
-	return nil
-}
 
-// Encode a log message into CLP IR, returning a view of the encoded message.
-func (encoder *fourByteEncoder) EncodeLogMessage(
-	logMessage ffi.LogMessage,
-) (*LogMessageView[FourByteEncoding], error) {
-	var logtype C.StringView
-	var vars C.Int32tSpan
-	var dictVars C.StringView
-	var dictVarEndOffsets C.Int32tSpan
-	err := IrError(C.ir_encoder_encode_four_byte_log_message(
-		newCStringView(logMessage),
-		encoder.cptr,
-		&logtype,
-		&vars,
-		&dictVars,
-		&dictVarEndOffsets,
-	))
-	if Success != err {
-		return nil, EncodeError
+	textStorage := string(text)
+	msgs := make([]LogMessage[T], len(logMessages))
+	var prev bounds
+	for i, curr := range msgBounds {
+		msgs[i] = LogMessage[T]{
+			Logtype:           textStorage[prev.dictVarsEnd:curr.logtypeEnd],
+			Vars:              vars[prev.varsEnd:curr.varsEnd:curr.varsEnd],
+			DictVars:          textStorage[curr.logtypeEnd:curr.dictVarsEnd],
+			DictVarEndOffsets: offsets[prev.offsetsEnd:curr.offsetsEnd:curr.offsetsEnd],
+		}
+		prev = curr
 	}
-	return newLogMessageView[FourByteEncoding](logtype, vars, dictVars, dictVarEndOffsets), nil
+	return msgs, nil
 }
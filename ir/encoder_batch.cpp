@@ -0,0 +1,243 @@
+//go:build cgo && !purego
+
+#include "encoder_batch.h"
+
+#include <cstddef>
+#include <cstdint>
+#include <string>
+#include <type_traits>
+#include <vector>
+
+#include "ffi_go/api_decoration.h"
+#include "ffi_go/defs.h"
+#include "ffi_go/ir/encoder.h"
+
+namespace ffi_go::ir {
+namespace {
+// Error codes forwarded from ffi::ir_stream::IRErrorCode
+constexpr int cSuccess{0};
+constexpr int cCorruptedIr{3};
+
+// The number of ends stored for each log message
+constexpr size_t cNumEnds{4};
+
+// Placeholders used in a logtype to mark the position of each variable. Must
+// match clp::ir::VariablePlaceholder.
+constexpr char cPlaceholderInteger{0x11};
+constexpr char cPlaceholderDictionary{0x12};
+constexpr char cPlaceholderFloat{0x13};
+constexpr char cPlaceholderEscape{'\\'};
+
+/**
+ * Counts the variables of a log message from the placeholders in its logtype.
+ * @param[in] logtype The log message's logtype
+ * @param[out] num_vars The number of encoded variables
+ * @param[out] num_dict_vars The number of dictionary variables
+ */
+auto count_placeholders(StringView logtype, size_t& num_vars, size_t& num_dict_vars) -> void {
+    num_vars = 0;
+    num_dict_vars = 0;
+    for (size_t i{0}; i < logtype.m_size; ++i) {
+        switch (logtype.m_data[i]) {
+            case cPlaceholderInteger:
+            case cPlaceholderFloat:
+                ++num_vars;
+                break;
+            case cPlaceholderDictionary:
+                ++num_dict_vars;
+                break;
+            case cPlaceholderEscape:
+                // The escaped character is static text
+                ++i;
+                break;
+            default:
+                break;
+        }
+    }
+}
+
+/**
+ * Storage backing the results of the batch functions.
+ */
+struct EncoderBatch {
+    std::string m_text;
+    std::vector<int64_t> m_eight_byte_vars;
+    std::vector<int32_t> m_four_byte_vars;
+    std::vector<int32_t> m_dict_var_end_offsets;
+    std::vector<size_t> m_ends;
+};
+
+/**
+ * Generic helper for ir_encoder_encode_*_log_messages
+ */
+template <class encoded_var_view_t, class encode_log_message_fn>
+[[nodiscard]] auto encode_log_messages(
+        encode_log_message_fn encode_log_message,
+        StringView log_messages,
+        SizetSpan log_message_ends,
+        void* ir_encoder,
+        void* ir_batch,
+        StringView* text,
+        encoded_var_view_t* vars,
+        Int32tSpan* dict_var_end_offsets,
+        SizetSpan* ends,
+        size_t* ir_encoder_size
+) -> int {
+    using encoded_var_t = std::conditional_t<
+            std::is_same_v<Int64tSpan, encoded_var_view_t>,
+            int64_t,
+            int32_t>;
+    if (nullptr == ir_encoder || nullptr == ir_batch || nullptr == text || nullptr == vars
+        || nullptr == dict_var_end_offsets || nullptr == ends || nullptr == ir_encoder_size)
+    {
+        return cCorruptedIr;
+    }
+    auto* batch{static_cast<EncoderBatch*>(ir_batch)};
+    std::vector<encoded_var_t>* batch_vars{nullptr};
+    if constexpr (std::is_same_v<int64_t, encoded_var_t>) {
+        batch_vars = &batch->m_eight_byte_vars;
+    } else {
+        batch_vars = &batch->m_four_byte_vars;
+    }
+    batch->m_text.clear();
+    batch_vars->clear();
+    batch->m_dict_var_end_offsets.clear();
+    batch->m_ends.clear();
+    batch->m_ends.reserve(cNumEnds * log_message_ends.m_size);
+    *ir_encoder_size = 0;
+
+    size_t begin{0};
+    for (size_t i{0}; i < log_message_ends.m_size; ++i) {
+        size_t const end{log_message_ends.m_data[i]};
+        if (end < begin || end > log_messages.m_size) {
+            return cCorruptedIr;
+        }
+        StringView logtype{};
+        encoded_var_view_t msg_vars{};
+        StringView dict_vars{};
+        Int32tSpan msg_dict_var_end_offsets{};
+        if (auto const err{encode_log_message(
+                    StringView{log_messages.m_data + begin, end - begin},
+                    ir_encoder,
+                    &logtype,
+                    &msg_vars,
+                    &dict_vars,
+                    &msg_dict_var_end_offsets
+            )};
+            cSuccess != err)
+        {
+            return err;
+        }
+
+        // The pre-built libraries do not clear an encoder's variables between
+        // log messages, so the variables of previous log messages may also be
+        // present. New encoded variables and dictionary variable end offsets
+        // are appended to the end of their storage, while new dictionary
+        // variables are inserted at the start of theirs.
+        size_t num_vars{0};
+        size_t num_dict_vars{0};
+        count_placeholders(logtype, num_vars, num_dict_vars);
+        if (num_vars > msg_vars.m_size || num_dict_vars > msg_dict_var_end_offsets.m_size) {
+            return cCorruptedIr;
+        }
+        auto const* vars_begin{msg_vars.m_data + (msg_vars.m_size - num_vars)};
+        auto const* offsets_begin{
+                msg_dict_var_end_offsets.m_data
+                + (msg_dict_var_end_offsets.m_size - num_dict_vars)
+        };
+        size_t dict_vars_size{0};
+        if (0 < num_dict_vars) {
+            dict_vars_size = static_cast<size_t>(offsets_begin[num_dict_vars - 1]);
+            if (dict_vars_size > dict_vars.m_size) {
+                return cCorruptedIr;
+            }
+        }
+        *ir_encoder_size = msg_vars.m_size + dict_vars.m_size + msg_dict_var_end_offsets.m_size;
+
+        batch->m_text.append(logtype.m_data, logtype.m_size);
+        batch->m_ends.push_back(batch->m_text.size());
+        batch->m_text.append(dict_vars.m_data, dict_vars_size);
+        batch->m_ends.push_back(batch->m_text.size());
+        batch_vars->insert(batch_vars->cend(), vars_begin, vars_begin + num_vars);
+        batch->m_ends.push_back(batch_vars->size());
+        batch->m_dict_var_end_offsets.insert(
+                batch->m_dict_var_end_offsets.cend(),
+                offsets_begin,
+                offsets_begin + num_dict_vars
+        );
+        batch->m_ends.push_back(batch->m_dict_var_end_offsets.size());
+        begin = end;
+    }
+
+    text->m_data = batch->m_text.data();
+    text->m_size = batch->m_text.size();
+    vars->m_data = batch_vars->data();
+    vars->m_size = batch_vars->size();
+    dict_var_end_offsets->m_data = batch->m_dict_var_end_offsets.data();
+    dict_var_end_offsets->m_size = batch->m_dict_var_end_offsets.size();
+    ends->m_data = batch->m_ends.data();
+    ends->m_size = batch->m_ends.size();
+    return cSuccess;
+}
+}  // namespace
+
+CLP_FFI_GO_METHOD auto ir_encoder_batch_new() -> void* {
+    // NOLINTNEXTLINE(cppcoreguidelines-owning-memory)
+    return new EncoderBatch{};
+}
+
+CLP_FFI_GO_METHOD auto ir_encoder_batch_close(void* ir_batch) -> void {
+    // NOLINTNEXTLINE(cppcoreguidelines-owning-memory)
+    delete static_cast<EncoderBatch*>(ir_batch);
+}
+
+CLP_FFI_GO_METHOD auto ir_encoder_encode_eight_byte_log_messages(
+        StringView log_messages,
+        SizetSpan log_message_ends,
+        void* ir_encoder,
+        void* ir_batch,
+        StringView* text,
+        Int64tSpan* vars,
+        Int32tSpan* dict_var_end_offsets,
+        SizetSpan* ends,
+        size_t* ir_encoder_size
+) -> int {
+    return encode_log_messages(
+            ir_encoder_encode_eight_byte_log_message,
+            log_messages,
+            log_message_ends,
+            ir_encoder,
+            ir_batch,
+            text,
+            vars,
+            dict_var_end_offsets,
+            ends,
+            ir_encoder_size
+    );
+}
+
+CLP_FFI_GO_METHOD auto ir_encoder_encode_four_byte_log_messages(
+        StringView log_messages,
+        SizetSpan log_message_ends,
+        void* ir_encoder,
+        void* ir_batch,
+        StringView* text,
+        Int32tSpan* vars,
+        Int32tSpan* dict_var_end_offsets,
+        SizetSpan* ends,
+        size_t* ir_encoder_size
+) -> int {
+    return encode_log_messages(
+            ir_encoder_encode_four_byte_log_message,
+            log_messages,
+            log_message_ends,
+            ir_encoder,
+            ir_batch,
+            text,
+            vars,
+            dict_var_end_offsets,
+            ends,
+            ir_encoder_size
+    );
+}
+}  // namespace ffi_go::ir
@@ -0,0 +1,102 @@
+//go:build cgo && !purego
+
+#ifndef FFI_GO_IR_ENCODER_BATCH_H
+#define FFI_GO_IR_ENCODER_BATCH_H
+// header must support C, making modernize checks inapplicable
+// NOLINTBEGIN(modernize-use-trailing-return-type)
+
+#include "ffi_go/api_decoration.h"
+#include "ffi_go/defs.h"
+
+// The functions below encode many log messages in a single call from Go by
+// repeatedly calling the functions of ffi_go/ir/encoder.h. They only use the C
+// API of the native library, so they are compiled with the Go package and work
+// with both the pre-built and external libraries.
+
+/**
+ * Create the storage (arena) backing the results of the batch functions of an
+ * ir::Encoder. The results of a call are only valid until the next call using
+ * the same batch.
+ * @return The address of the new batch storage
+ */
+CLP_FFI_GO_METHOD void* ir_encoder_batch_new();
+
+/**
+ * Clean up the storage created by ir_encoder_batch_new.
+ * @param[in] ir_batch The address of the batch storage
+ */
+CLP_FFI_GO_METHOD void ir_encoder_batch_close(void* ir_batch);
+
+/**
+ * Given log messages, encode each of them with
+ * ir_encoder_encode_eight_byte_log_message, storing the results contiguously
+ * in ir_batch. Each log message's logtype and dictionary variables are stored
+ * one after the other in text. For each log message, ends holds the end of its
+ * logtype in text, the end of its dictionary variables in text, the end of its
+ * variables in vars, and the end of its dictionary variable end offsets in
+ * dict_var_end_offsets. The pre-built libraries do not clear the variables
+ * left in ir_encoder by previous log messages, so only the variables of each
+ * log message (counted from the placeholders in its logtype) are stored, and
+ * ir_encoder_size reports how much storage ir_encoder holds, so the caller can
+ * replace an encoder that has grown too large. All pointer parameters must be
+ * non-null.
+ * @param[in] log_messages The log messages, one after the other
+ * @param[in] log_message_ends The end of each log message in log_messages
+ * @param[in] ir_encoder ir::Encoder to be used as storage for each log message
+ * @param[in] ir_batch Batch storage used as storage for the results
+ * @param[out] text Logtypes and dictionary variables stored in ir_batch
+ * @param[out] vars Encoded variables stored in ir_batch
+ * @param[out] dict_var_end_offsets Dictionary variable end offsets stored in
+ *     ir_batch
+ * @param[out] ends Ends of each log message's components stored in ir_batch
+ * @param[out] ir_encoder_size The number of variables, dictionary variable
+ *     bytes, and dictionary variable end offsets stored in ir_encoder
+ * @return ffi::ir_stream::IRErrorCode forwarded from
+ *     ir_encoder_encode_eight_byte_log_message
+ */
+CLP_FFI_GO_METHOD int ir_encoder_encode_eight_byte_log_messages(
+        StringView log_messages,
+        SizetSpan log_message_ends,
+        void* ir_encoder,
+        void* ir_batch,
+        StringView* text,
+        Int64tSpan* vars,
+        Int32tSpan* dict_var_end_offsets,
+        SizetSpan* ends,
+        size_t* ir_encoder_size
+);
+
+/**
+ * Given log messages, encode each of them with
+ * ir_encoder_encode_four_byte_log_message, storing the results contiguously in
+ * ir_batch. The layout of the results is the same as
+ * ir_encoder_encode_eight_byte_log_messages. All pointer parameters must be
+ * non-null.
+ * @param[in] log_messages The log messages, one after the other
+ * @param[in] log_message_ends The end of each log message in log_messages
+ * @param[in] ir_encoder ir::Encoder to be used as storage for each log message
+ * @param[in] ir_batch Batch storage used as storage for the results
+ * @param[out] text Logtypes and dictionary variables stored in ir_batch
+ * @param[out] vars Encoded variables stored in ir_batch
+ * @param[out] dict_var_end_offsets Dictionary variable end offsets stored in
+ *     ir_batch
+ * @param[out] ends Ends of each log message's components stored in ir_batch
+ * @param[out] ir_encoder_size The number of variables, dictionary variable
+ *     bytes, and dictionary variable end offsets stored in ir_encoder
+ * @return ffi::ir_stream::IRErrorCode forwarded from
+ *     ir_encoder_encode_four_byte_log_message
+ */
+CLP_FFI_GO_METHOD int ir_encoder_encode_four_byte_log_messages(
+        StringView log_messages,
+        SizetSpan log_message_ends,
+        void* ir_encoder,
+        void* ir_batch,
+        StringView* text,
+        Int32tSpan* vars,
+        Int32tSpan* dict_var_end_offsets,
+        SizetSpan* ends,
+        size_t* ir_encoder_size
+);
+
+// NOLINTEND(modernize-use-trailing-return-type)
+#endif  // FFI_GO_IR_ENCODER_BATCH_H
@@ -0,0 +1,277 @@
+//go:build cgo && !purego
+
+package ir
+
+/*
+#include <ffi_go/ir/encoder.h>
+#include "encoder_batch.h"
+*/
+import "C"
+
+import (
+	"runtime"
+	"strings"
+	"unsafe"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+)
+
+// Return a new Encoder that produces IR using [EightByteEncoding].
+func EightByteEncoder() (Encoder[EightByteEncoding], error) {
+	encoder := &eightByteEncoder{
+		cptr:      C.ir_encoder_eight_byte_new(),
+		batchCptr: C.ir_encoder_batch_new(),
+	}
+	encoder.track()
+	return encoder, nil
+}
+
+// Return a new Encoder that produces IR using [FourByteEncoding].
+func FourByteEncoder() (Encoder[FourByteEncoding], error) {
+	encoder := &fourByteEncoder{
+		cptr:      C.ir_encoder_four_byte_new(),
+		batchCptr: C.ir_encoder_batch_new(),
+	}
+	encoder.track()
+	return encoder, nil
+}
+
+// eightByteEncoder holds a reference to the underlying C++ encoder in cptr, to the
+// C++ storage backing its results in batchCptr (see encoder_batch.h), and to
+// its leak tracking (see [SetLeakTracking]) in ref.
+type eightByteEncoder struct {
+	cptr      unsafe.Pointer
+	batchCptr unsafe.Pointer
+	ref       leakRef
+}
+
+// track tracks the encoder for leaks. Its C++ encoder is not deleted once it is
+// garbage collected, as returned views may still reference it.
+func (encoder *eightByteEncoder) track() {
+	encoder.ref = trackLeaks(encoder, "Encoder", nil)
+}
+
+// Close will delete the underlying C++ allocated memory used by the
+// deserializer. Failure to call Close will result in a memory leak.
+func (encoder *eightByteEncoder) Close() error {
+	if nil != encoder.cptr {
+		encoder.ref.untrack()
+		C.ir_encoder_eight_byte_close(encoder.cptr)
+		C.ir_encoder_batch_close(encoder.batchCptr)
+		encoder.cptr = nil
+		encoder.batchCptr = nil
+	}
+	return nil
+}
+
+// Encode a log message into CLP IR, returning a view of the encoded message.
+func (encoder *eightByteEncoder) EncodeLogMessage(
+	logMessage ffi.LogMessage,
+) (*LogMessageView[EightByteEncoding], error) {
+	batch, err := encodeLogMessageBatch[EightByteEncoding](encoder, []ffi.LogMessage{logMessage})
+	if nil != err {
+		return nil, err
+	}
+	return &LogMessageView[EightByteEncoding]{batch.logMessage(0)}, nil
+}
+
+// Encode log messages into CLP IR, returning the encoded messages. The encoded
+// messages are copied into memory owned by Go, so they remain valid after
+// future calls.
+func (encoder *eightByteEncoder) EncodeLogMessages(
+	logMessages []ffi.LogMessage,
+) ([]LogMessage[EightByteEncoding], error) {
+	return encodeNativeLogMessages[EightByteEncoding](encoder, logMessages)
+}
+
+// fourByteEncoder holds a reference to the underlying C++ encoder in cptr, to the
+// C++ storage backing its results in batchCptr (see encoder_batch.h), and to
+// its leak tracking (see [SetLeakTracking]) in ref.
+type fourByteEncoder struct {
+	cptr      unsafe.Pointer
+	batchCptr unsafe.Pointer
+	ref       leakRef
+}
+
+// track tracks the encoder for leaks. Its C++ encoder is not deleted once it is
+// garbage collected, as returned views may still reference it.
+func (encoder *fourByteEncoder) track() {
+	encoder.ref = trackLeaks(encoder, "Encoder", nil)
+}
+
+// Close will delete the underlying C++ allocated memory used by the
+// deserializer. Failure to call Close will result in a memory leak.
+func (encoder *fourByteEncoder) Close() error {
+	if nil != encoder.cptr {
+		encoder.ref.untrack()
+		C.ir_encoder_four_byte_close(encoder.cptr)
+		C.ir_encoder_batch_close(encoder.batchCptr)
+		encoder.cptr = nil
+		encoder.batchCptr = nil
+	}
+	return nil
+}
+
+// Encode a log message into CLP IR, returning a view of the encoded message.
+func (encoder *fourByteEncoder) EncodeLogMessage(
+	logMessage ffi.LogMessage,
+) (*LogMessageView[FourByteEncoding], error) {
+	batch, err := encodeLogMessageBatch[FourByteEncoding](encoder, []ffi.LogMessage{logMessage})
+	if nil != err {
+		return nil, err
+	}
+	return &LogMessageView[FourByteEncoding]{batch.logMessage(0)}, nil
+}
+
+// Encode log messages into CLP IR, returning the encoded messages. The encoded
+// messages are copied into memory owned by Go, so they remain valid after
+// future calls.
+func (encoder *fourByteEncoder) EncodeLogMessages(
+	logMessages []ffi.LogMessage,
+) ([]LogMessage[FourByteEncoding], error) {
+	return encodeNativeLogMessages[FourByteEncoding](encoder, logMessages)
+}
+
+// logMessageBatch holds views of log messages encoded by the native library,
+// stored one after the other (see encoder_batch.h). ends holds four ends for
+// each log message: the end of its logtype in text, the end of its dictionary
+// variables in text, the end of its variables in vars, and the end of its
+// dictionary variable end offsets in dictVarEndOffsets.
+type logMessageBatch[T EightByteEncoding | FourByteEncoding] struct {
+	text              string
+	vars              []T
+	dictVarEndOffsets []int32
+	ends              []C.size_t
+}
+
+// logMessage returns the i-th log message of the batch, referencing the
+// batch's memory.
+func (batch logMessageBatch[T]) logMessage(i int) LogMessage[T] {
+	var prev [4]C.size_t
+	if 0 < i {
+		copy(prev[:], batch.ends[4*(i-1):])
+	}
+	curr := batch.ends[4*i:]
+	return LogMessage[T]{
+		Logtype:           batch.text[prev[1]:curr[0]],
+		Vars:              batch.vars[prev[2]:curr[2]:curr[2]],
+		DictVars:          batch.text[curr[0]:curr[1]],
+		DictVarEndOffsets: batch.dictVarEndOffsets[prev[3]:curr[3]:curr[3]],
+	}
+}
+
+// maxNativeEncoderSize is the storage size (see encoder_batch.h) past which a
+// native encoder is replaced. The pre-built native libraries never clear the
+// variables an encoder stores, so its storage grows (and encoding slows down)
+// with each log message encoded until it is replaced.
+const maxNativeEncoderSize = 4096
+
+// encodeLogMessageBatch encodes logMessages (of which there must be at least
+// one) with encoder in a single call to the native library. The returned batch
+// references the encoder's C++ batch storage and is only valid until the next
+// call. The encoder's C++ encoder is replaced once it grows too large (see
+// maxNativeEncoderSize).
+// On error returns:
+//   - 0 value batch
+//   - [EncodeError] error: encoding a log message failed
+func encodeLogMessageBatch[T EightByteEncoding | FourByteEncoding](
+	encoder Encoder[T],
+	logMessages []ffi.LogMessage,
+) (logMessageBatch[T], error) {
+	// The log messages are passed to C++ one after the other, as a slice of
+	// strings contains Go pointers and cannot be passed to C.
+	text := logMessages[0]
+	ends := make([]C.size_t, len(logMessages))
+	ends[0] = C.size_t(len(text))
+	if 1 < len(logMessages) {
+		var sb strings.Builder
+		for i, logMessage := range logMessages {
+			sb.WriteString(logMessage)
+			ends[i] = C.size_t(sb.Len())
+		}
+		text = sb.String()
+	}
+	cEnds := C.SizetSpan{(*C.size_t)(unsafe.SliceData(ends)), C.size_t(len(ends))}
+
+	var batch logMessageBatch[T]
+	var cText C.StringView
+	var cDictVarEndOffsets C.Int32tSpan
+	var cBatchEnds C.SizetSpan
+	var cEncoderSize C.size_t
+	var err IrError
+	switch irs := any(encoder).(type) {
+	case *eightByteEncoder:
+		var cVars C.Int64tSpan
+		err = IrError(C.ir_encoder_encode_eight_byte_log_messages(
+			newCStringView(text),
+			cEnds,
+			irs.cptr,
+			irs.batchCptr,
+			&cText,
+			&cVars,
+			&cDictVarEndOffsets,
+			&cBatchEnds,
+			&cEncoderSize,
+		))
+		batch.vars = unsafe.Slice((*T)(unsafe.Pointer(cVars.m_data)), cVars.m_size)
+		if maxNativeEncoderSize < cEncoderSize {
+			C.ir_encoder_eight_byte_close(irs.cptr)
+			irs.cptr = C.ir_encoder_eight_byte_new()
+		}
+	case *fourByteEncoder:
+		var cVars C.Int32tSpan
+		err = IrError(C.ir_encoder_encode_four_byte_log_messages(
+			newCStringView(text),
+			cEnds,
+			irs.cptr,
+			irs.batchCptr,
+			&cText,
+			&cVars,
+			&cDictVarEndOffsets,
+			&cBatchEnds,
+			&cEncoderSize,
+		))
+		batch.vars = unsafe.Slice((*T)(unsafe.Pointer(cVars.m_data)), cVars.m_size)
+		if maxNativeEncoderSize < cEncoderSize {
+			C.ir_encoder_four_byte_close(irs.cptr)
+			irs.cptr = C.ir_encoder_four_byte_new()
+		}
+	}
+	runtime.KeepAlive(encoder)
+	if Success != err {
+		return logMessageBatch[T]{}, EncodeError
+	}
+	batch.text = unsafe.String((*byte)(unsafe.Pointer(cText.m_data)), cText.m_size)
+	batch.dictVarEndOffsets = unsafe.Slice(
+		(*int32)(cDictVarEndOffsets.m_data),
+		cDictVarEndOffsets.m_size,
+	)
+	batch.ends = unsafe.Slice(cBatchEnds.m_data, cBatchEnds.m_size)
+	return batch, nil
+}
+
+// encodeNativeLogMessages implements EncodeLogMessages for the native encoders,
+// encoding logMessages in a single call to the native library and copying the
+// results into contiguous storage owned by Go, laid out like the results of
+// [encodeLogMessages].
+func encodeNativeLogMessages[T EightByteEncoding | FourByteEncoding](
+	encoder Encoder[T],
+	logMessages []ffi.LogMessage,
+) ([]LogMessage[T], error) {
+	if 0 == len(logMessages) {
+		return []LogMessage[T]{}, nil
+	}
+	batch, err := encodeLogMessageBatch(encoder, logMessages)
+	if nil != err {
+		return nil, err
+	}
+	batch.text = strings.Clone(batch.text)
+	batch.vars = append([]T(nil), batch.vars...)
+	batch.dictVarEndOffsets = append([]int32(nil), batch.dictVarEndOffsets...)
+	batch.ends = append([]C.size_t(nil), batch.ends...)
+	msgs := make([]LogMessage[T], len(logMessages))
+	for i := range msgs {
+		msgs[i] = batch.logMessage(i)
+	}
+	return msgs, nil
+}
@@ -0,0 +1,13 @@
+//go:build !cgo || purego
+
+package ir
+
+// Return a new Encoder that produces IR using [EightByteEncoding].
+func EightByteEncoder() (Encoder[EightByteEncoding], error) {
+	return &goEncoder[EightByteEncoding]{}, nil
+}
+
+// Return a new Encoder that produces IR using [FourByteEncoding].
+func FourByteEncoder() (Encoder[FourByteEncoding], error) {
+	return &goEncoder[FourByteEncoding]{}, nil
+}
@@ -0,0 +1,149 @@
+package ir
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+)
+
+var encoderTestMessages = []ffi.LogMessage{
+	"static text dict=var notint123 -1.234 4321.",
+	"static123 text321 dict=var0123 321.1234 -3210.",
+	"textint1234 textequal=variable",
+	fmt.Sprintf("test=bigint %v", math.MaxInt32+1),
+	"static text log zero.",
+	"request from user=alice took 12.5 ms",
+}
+
+func TestEncodeLogMessagesEightByte(t *testing.T) {
+	encoder, _ := EightByteEncoder()
+	defer encoder.Close()
+	decoder, _ := EightByteDecoder()
+	defer decoder.Close()
+	testEncodeLogMessages(t, encoder, decoder)
+}
+
+func TestEncodeLogMessagesFourByte(t *testing.T) {
+	encoder, _ := FourByteEncoder()
+	defer encoder.Close()
+	decoder, _ := FourByteDecoder()
+	defer decoder.Close()
+	testEncodeLogMessages(t, encoder, decoder)
+}
+
+func testEncodeLogMessages[T EightByteEncoding | FourByteEncoding](
+	t *testing.T,
+	encoder Encoder[T],
+	decoder Decoder[T],
+) {
+	batchEncoder, ok := encoder.(BatchEncoder[T])
+	if false == ok {
+		t.Fatalf("%T is not a BatchEncoder", encoder)
+	}
+	// Enough log messages for a native encoder to be replaced (see
+	// maxNativeEncoderSize)
+	var logMessages []ffi.LogMessage
+	for range 200 {
+		logMessages = append(logMessages, encoderTestMessages...)
+	}
+	msgs, err := batchEncoder.EncodeLogMessages(logMessages)
+	if nil != err {
+		t.Fatalf("EncodeLogMessages failed: %v", err)
+	}
+	if len(logMessages) != len(msgs) {
+		t.Fatalf("EncodeLogMessages wrong length: %v", len(msgs))
+	}
+	for i, logMessage := range logMessages {
+		// Encoding a single message must give the same result as the batch
+		msgView, err := encoder.EncodeLogMessage(logMessage)
+		if nil != err {
+			t.Fatalf("EncodeLogMessage failed: %v", err)
+		}
+		assertLogMessagesEqual(t, msgView.LogMessage, msgs[i])
+
+		decoded, err := decoder.DecodeLogMessage(msgs[i])
+		if nil != err {
+			t.Fatalf("DecodeLogMessage failed: %v", err)
+		}
+		if logMessage != *decoded {
+			t.Fatalf("DecodeLogMessage wrong message: '%v' != '%v'", *decoded, logMessage)
+		}
+	}
+}
+
+func assertLogMessagesEqual[T EightByteEncoding | FourByteEncoding](
+	t *testing.T,
+	actual LogMessage[T],
+	expected LogMessage[T],
+) {
+	if actual.Logtype != expected.Logtype ||
+		actual.DictVars != expected.DictVars ||
+		fmt.Sprint(actual.Vars) != fmt.Sprint(expected.Vars) ||
+		fmt.Sprint(actual.DictVarEndOffsets) != fmt.Sprint(expected.DictVarEndOffsets) {
+		t.Fatalf("LogMessages differ: %+v != %+v", actual, expected)
+	}
+}
+
+func TestLogMessageTokens(t *testing.T) {
+	encoder, _ := EightByteEncoder()
+	defer encoder.Close()
+	msgView, err := encoder.EncodeLogMessage("user=alice took 12.50 ms \x11 -3 retries")
+	if nil != err {
+		t.Fatalf("EncodeLogMessage failed: %v", err)
+	}
+	tokens, err := msgView.Tokens()
+	if nil != err {
+		t.Fatalf("Tokens failed: %v", err)
+	}
+	expected := []Token{
+		{Kind: StaticText, Text: "user="},
+		{Kind: DictionaryVar, Text: "alice"},
+		{Kind: StaticText, Text: " took "},
+		{Kind: FloatVar, Text: "12.50", Float: 12.5},
+		{Kind: StaticText, Text: " ms \x11 "},
+		{Kind: IntegerVar, Text: "-3", Int: -3},
+		{Kind: StaticText, Text: " retries"},
+	}
+	if len(expected) != len(tokens) {
+		t.Fatalf("Tokens wrong length: %+v", tokens)
+	}
+	for i := range expected {
+		if expected[i] != tokens[i] {
+			t.Fatalf("Tokens wrong token %v: %+v != %+v", i, tokens[i], expected[i])
+		}
+	}
+}
+
+func TestLogMessageTokensRoundTrip(t *testing.T) {
+	eightByteEncoder, _ := EightByteEncoder()
+	defer eightByteEncoder.Close()
+	fourByteEncoder, _ := FourByteEncoder()
+	defer fourByteEncoder.Close()
+	for _, logMessage := range encoderTestMessages {
+		eightByteMsg, _ := eightByteEncoder.EncodeLogMessage(logMessage)
+		assertTokensRoundTrip(t, eightByteMsg.LogMessage, logMessage)
+		fourByteMsg, _ := fourByteEncoder.EncodeLogMessage(logMessage)
+		assertTokensRoundTrip(t, fourByteMsg.LogMessage, logMessage)
+	}
+}
+
+func assertTokensRoundTrip[T EightByteEncoding | FourByteEncoding](
+	t *testing.T,
+	msg LogMessage[T],
+	logMessage ffi.LogMessage,
+) {
+	tokens, err := msg.Tokens()
+	if nil != err {
+		t.Fatalf("Tokens failed: %v", err)
+	}
+	var sb strings.Builder
+	for _, token := range tokens {
+		sb.WriteString(token.Text)
+	}
+	if logMessage != sb.String() {
+		t.Fatalf("Tokens wrong text: '%v' != '%v'", sb.String(), logMessage)
+	}
+}
@@ -0,0 +1,37 @@
+package ir
+
+import (
+	"unsafe"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+)
+
+// goDecoder is a [Decoder] implemented purely in Go, producing results
+// identical to the native (C++) decoder. Its buffer is reused for each
+// LogMessageView it returns, matching the lifetime of views returned by the
+// native decoder. Unlike the native decoder, escaped placeholders in the
+// logtype are supported.
+type goDecoder[T EightByteEncoding | FourByteEncoding] struct {
+	logMessage []byte
+}
+
+// Close releases the decoder's buffer. A goDecoder does not use any C++
+// allocated memory, so calling Close is not required to avoid a leak.
+func (decoder *goDecoder[T]) Close() error {
+	decoder.logMessage = nil
+	return nil
+}
+
+// Decode an IR encoded log message, returning a view of the original
+// (non-encoded) log message.
+func (decoder *goDecoder[T]) DecodeLogMessage(
+	irMessage LogMessage[T],
+) (*ffi.LogMessageView, error) {
+	logMessage, err := appendDecodedMessage(decoder.logMessage[:0], irMessage)
+	if nil != err {
+		return nil, err
+	}
+	decoder.logMessage = logMessage
+	view := unsafe.String(unsafe.SliceData(decoder.logMessage), len(decoder.logMessage))
+	return &view, nil
+}
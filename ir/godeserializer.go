@@ -0,0 +1,449 @@
+package ir
+
+import (
+	"encoding/binary"
+	"time"
+	"unsafe"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+	"github.com/y-scope/clp-ffi-go/search"
+)
+
+// goDeserializePreamble is the pure Go equivalent of [DeserializePreamble],
+// always returning a goDeserializer of the correct stream encoding size (or a
+// [KVDeserializer] for key-value pair IR streams).
+func goDeserializePreamble(irBuf []byte) (Deserializer, int, error) {
+	if len(irBuf) < len(eightByteEncodingMagicNumber) {
+		return nil, 0, IncompleteIr
+	}
+	isFourByte := false
+	switch [4]byte(irBuf) {
+	case eightByteEncodingMagicNumber:
+	case fourByteEncodingMagicNumber:
+		isFourByte = true
+	default:
+		return nil, 0, CorruptedIr
+	}
+	pos := len(eightByteEncodingMagicNumber)
+
+	if len(irBuf) <= pos {
+		return nil, 0, IncompleteIr
+	}
+	metadataType := irBuf[pos]
+	pos++
+
+	if len(irBuf) <= pos {
+		return nil, 0, IncompleteIr
+	}
+	var metadataSize int
+	switch irBuf[pos] {
+	case tagMetadataLenUByte:
+		if len(irBuf) < pos+2 {
+			return nil, 0, IncompleteIr
+		}
+		metadataSize = int(irBuf[pos+1])
+		pos += 2
+	case tagMetadataLenUShort:
+		if len(irBuf) < pos+3 {
+			return nil, 0, IncompleteIr
+		}
+		metadataSize = int(binary.BigEndian.Uint16(irBuf[pos+1:]))
+		pos += 3
+	default:
+		return nil, 0, CorruptedIr
+	}
+	if len(irBuf) < pos+metadataSize {
+		return nil, 0, IncompleteIr
+	}
+	metadataBuf := irBuf[pos : pos+metadataSize]
+	pos += metadataSize
+
+	if metadataEncodingJson != metadataType {
+		return nil, 0, UnsupportedVersion
+	}
+	metadata, err := unmarshalPreambleMetadata(metadataBuf)
+	if nil != err {
+		return nil, 0, err
+	}
+
+	if kvMetadataVersionValue == metadata.version {
+		return newKVDeserializer(isFourByte, metadata), pos, nil
+	}
+	if isFourByte {
+		return &goDeserializer[FourByteEncoding]{
+			tsInfo:        metadata.tsInfo,
+			userMetadata:  metadata.userMetadata,
+			version:       metadata.version,
+			prevTimestamp: metadata.refTs,
+		}, pos, nil
+	}
+	return &goDeserializer[EightByteEncoding]{
+		tsInfo:       metadata.tsInfo,
+		userMetadata: metadata.userMetadata,
+		version:      metadata.version,
+	}, pos, nil
+}
+
+// goDeserializer is a [Deserializer] implemented purely in Go, producing
+// results identical to the native (C++) deserializer. Its buffers are reused
+// for each view it returns, matching the lifetime of views returned by the
+// native deserializer. For four byte encoded IR, prevTimestamp stores the
+// previous log event's timestamp (initially the reference timestamp), as only
+// the timestamp delta between log events is encoded. utcOffset stores the UTC
+// offset of the previous log event.
+type goDeserializer[T EightByteEncoding | FourByteEncoding] struct {
+	encodedLogMessageBuf[T]
+	tsInfo        TimestampInfo
+	userMetadata  map[string]any
+	version       string
+	prevTimestamp ffi.EpochTimeMs
+	utcOffset     time.Duration
+	logMessage    []byte
+}
+
+// Close releases the deserializer's buffers. A goDeserializer does not use any
+// C++ allocated memory, so calling Close is not required to avoid a leak.
+func (deserializer *goDeserializer[T]) Close() error {
+	deserializer.logtype = nil
+	deserializer.vars = nil
+	deserializer.dictVars = nil
+	deserializer.dictVarEndOffsets = nil
+	deserializer.logMessage = nil
+	return nil
+}
+
+// Returns the TimestampInfo used by the Deserializer.
+func (deserializer *goDeserializer[T]) TimestampInfo() TimestampInfo {
+	return deserializer.tsInfo
+}
+
+// Returns the user-defined metadata of the IR stream.
+func (deserializer *goDeserializer[T]) Metadata() map[string]any {
+	return deserializer.userMetadata
+}
+
+// Returns the IR protocol version of the IR stream.
+func (deserializer *goDeserializer[T]) Version() string {
+	return deserializer.version
+}
+
+// Returns the encoding of the IR stream.
+func (deserializer *goDeserializer[T]) Encoding() Encoding {
+	return encodingOf[T]()
+}
+
+// Returns the UTC offset of the most recently deserialized log event.
+func (deserializer *goDeserializer[T]) UtcOffset() time.Duration {
+	return deserializer.utcOffset
+}
+
+// DeserializeLogEvent attempts to read the next log event from the IR stream in
+// irBuf, returning the deserialized [ffi.LogEventView], the position read to in
+// irBuf (the end of the log event in irBuf), and an error. On error returns:
+//   - nil *ffi.LogEventView
+//   - 0 position
+//   - [IrError] error: CLP failed to successfully deserialize
+//   - [EndOfIr] error: CLP found the IR stream EOF tag
+func (deserializer *goDeserializer[T]) DeserializeLogEvent(
+	irBuf []byte,
+) (*ffi.LogEventView, int, error) {
+	msg, timestamp, pos, err := deserializer.deserializeEncodedLogEvent(irBuf)
+	if nil != err {
+		return nil, 0, err
+	}
+	event, err := deserializer.decodeLogEvent(msg, timestamp)
+	if nil != err {
+		return nil, 0, err
+	}
+	return event, pos, nil
+}
+
+// DeserializeLogEvents attempts to read up to max log events (every complete
+// log event in irBuf if max is not positive) from the IR stream in irBuf,
+// returning the deserialized [ffi.LogEventView]s, the position read to in irBuf
+// (the end of the last log event in irBuf), and an error. The log messages are
+// copied into memory owned by Go (see [BatchDeserializer]).
+// Deserialization stops early at the first error, which is only returned if no
+// log event was deserialized before it, so that the next call returns it
+// instead (e.g. [IncompleteIr] once irBuf ends). On error returns:
+//   - nil []ffi.LogEventView
+//   - 0 position
+//   - [IrError] error: CLP failed to successfully deserialize
+//   - [EndOfIr] error: CLP found the IR stream EOF tag
+func (deserializer *goDeserializer[T]) DeserializeLogEvents(
+	irBuf []byte,
+	max int,
+) ([]ffi.LogEventView, int, error) {
+	return deserializeLogEvents(deserializer, irBuf, max)
+}
+
+// DeserializeWildcardMatchWithTimeInterval attempts to read the next log event
+// from the IR stream in irBuf that matches mergedQuery within timeInterval. It
+// returns the deserialized [ffi.LogEventView], the position read to in irBuf
+// (the end of the log event in irBuf), the index of the matched query in
+// mergedQuery, and an error. On error returns:
+//   - nil *ffi.LogEventView
+//   - 0 position
+//   - -1 index
+//   - [IrError] error: CLP failed to successfully deserialize
+//   - [EndOfIr] error: CLP found the IR stream EOF tag
+//   - [QueryNotFound] error: a log event past timeInterval was found
+func (deserializer *goDeserializer[T]) DeserializeWildcardMatchWithTimeInterval(
+	irBuf []byte,
+	mergedQuery search.MergedWildcardQuery,
+	timeInterval search.TimestampInterval,
+) (*ffi.LogEventView, int, int, error) {
+	// If irBuf ends before a match is found the same log events will be
+	// deserialized again once more IR is available, so the timestamp must be
+	// rolled back to avoid applying their deltas twice (and the UTC offset to
+	// match it).
+	prevTimestamp := deserializer.prevTimestamp
+	utcOffset := deserializer.utcOffset
+	pos := 0
+	for {
+		msg, timestamp, n, err := deserializer.deserializeEncodedLogEvent(irBuf[pos:])
+		if IncompleteIr == err {
+			deserializer.prevTimestamp = prevTimestamp
+			deserializer.utcOffset = utcOffset
+		}
+		if nil != err {
+			return nil, 0, -1, err
+		}
+		pos += n
+
+		if timeInterval.Upper <= timestamp {
+			return nil, 0, -1, QueryNotFound
+		}
+		if timeInterval.Lower > timestamp {
+			continue
+		}
+		event, err := deserializer.decodeLogEvent(msg, timestamp)
+		if nil != err {
+			return nil, 0, -1, err
+		}
+		if idx, ok := mergedQuery.Match(event.LogMessageView); ok {
+			return event, pos, idx, nil
+		}
+	}
+}
+
+// decodeLogEvent decodes msg into the deserializer's log message buffer,
+// returning a view of the log event.
+func (deserializer *goDeserializer[T]) decodeLogEvent(
+	msg LogMessage[T],
+	timestamp ffi.EpochTimeMs,
+) (*ffi.LogEventView, error) {
+	logMessage, err := appendDecodedMessage(deserializer.logMessage[:0], msg)
+	if nil != err {
+		return nil, err
+	}
+	deserializer.logMessage = logMessage
+	return &ffi.LogEventView{
+		LogMessageView: unsafe.String(unsafe.SliceData(logMessage), len(logMessage)),
+		Timestamp:      timestamp,
+	}, nil
+}
+
+// deserializeEncodedLogEvent reads the next log event from the IR stream in
+// irBuf without decoding its log message. The returned LogMessage is a view of
+// the deserializer's buffers, valid until the next deserialization. The UTC
+// offset change packets preceding the log event are applied once the log event
+// is read. Returns:
+//   - success: the encoded log message, the log event's timestamp, the
+//     position read to in irBuf, nil
+//   - error: 0 value LogMessage, 0 timestamp, 0 position, [IrError] error
+func (deserializer *goDeserializer[T]) deserializeEncodedLogEvent(
+	irBuf []byte,
+) (LogMessage[T], ffi.EpochTimeMs, int, error) {
+	utcOffset, pos, err := readUtcOffsetChanges(irBuf, deserializer.utcOffset)
+	if nil != err {
+		return LogMessage[T]{}, 0, 0, err
+	}
+	if len(irBuf) <= pos {
+		return LogMessage[T]{}, 0, 0, IncompleteIr
+	}
+	if tagEof == irBuf[pos] {
+		return LogMessage[T]{}, 0, 0, EndOfIr
+	}
+
+	msg, n, err := deserializer.readEncodedLogMessage(irBuf[pos:])
+	if nil != err {
+		return LogMessage[T]{}, 0, 0, err
+	}
+	pos += n
+
+	var t T
+	_, isFourByte := any(t).(FourByteEncoding)
+	timestamp, n, err := readTimestamp(irBuf[pos:], isFourByte)
+	if nil != err {
+		return LogMessage[T]{}, 0, 0, err
+	}
+	pos += n
+	if isFourByte {
+		timestamp += deserializer.prevTimestamp
+	}
+	deserializer.prevTimestamp = timestamp
+	deserializer.utcOffset = utcOffset
+	return msg, timestamp, pos, nil
+}
+
+// encodedLogMessageBuf holds the reusable buffers backing the LogMessage views
+// returned when reading encoded log messages.
+type encodedLogMessageBuf[T EightByteEncoding | FourByteEncoding] struct {
+	logtype           []byte
+	vars              []T
+	dictVars          []byte
+	dictVarEndOffsets []int32
+}
+
+// readEncodedLogMessage reads an encoded log message, serialized as each of its
+// variables followed by its logtype, from irBuf. The returned LogMessage is a
+// view of buf, valid until the next read. Returns:
+//   - success: the encoded log message, the position read to in irBuf, nil
+//   - error: 0 value LogMessage, 0 position, [IrError] error
+func (buf *encodedLogMessageBuf[T]) readEncodedLogMessage(
+	irBuf []byte,
+) (LogMessage[T], int, error) {
+	var t T
+	_, isFourByte := any(t).(FourByteEncoding)
+	logtype := buf.logtype[:0]
+	vars := buf.vars[:0]
+	dictVars := buf.dictVars[:0]
+	dictVarEndOffsets := buf.dictVarEndOffsets[:0]
+
+	pos := 0
+	for hasLogtype := false; false == hasLogtype; {
+		if len(irBuf) <= pos {
+			return LogMessage[T]{}, 0, IncompleteIr
+		}
+		tag := irBuf[pos]
+		pos++
+		switch tag {
+		case tagVarFourByteEncoding, tagVarEightByteEncoding:
+			if isFourByte != (tagVarFourByteEncoding == tag) {
+				return LogMessage[T]{}, 0, CorruptedIr
+			}
+			size := int(unsafe.Sizeof(t))
+			if len(irBuf) < pos+size {
+				return LogMessage[T]{}, 0, IncompleteIr
+			}
+			if isFourByte {
+				vars = append(vars, T(int32(binary.BigEndian.Uint32(irBuf[pos:]))))
+			} else {
+				vars = append(vars, T(int64(binary.BigEndian.Uint64(irBuf[pos:]))))
+			}
+			pos += size
+		case tagVarStrLenUByte, tagVarStrLenUShort, tagVarStrLenInt:
+			str, n, err := readLengthPrefixed(irBuf[pos:], tag, tagVarStrLenUByte)
+			if nil != err {
+				return LogMessage[T]{}, 0, err
+			}
+			dictVars = append(dictVars, str...)
+			dictVarEndOffsets = append(dictVarEndOffsets, int32(len(dictVars)))
+			pos += n
+		case tagLogtypeStrLenUByte, tagLogtypeStrLenUShort, tagLogtypeStrLenInt:
+			str, n, err := readLengthPrefixed(irBuf[pos:], tag, tagLogtypeStrLenUByte)
+			if nil != err {
+				return LogMessage[T]{}, 0, err
+			}
+			logtype = append(logtype, str...)
+			pos += n
+			hasLogtype = true
+		default:
+			return LogMessage[T]{}, 0, CorruptedIr
+		}
+	}
+	buf.logtype = logtype
+	buf.vars = vars
+	buf.dictVars = dictVars
+	buf.dictVarEndOffsets = dictVarEndOffsets
+
+	return LogMessage[T]{
+		Logtype:           unsafe.String(unsafe.SliceData(logtype), len(logtype)),
+		Vars:              vars,
+		DictVars:          unsafe.String(unsafe.SliceData(dictVars), len(dictVars)),
+		DictVarEndOffsets: dictVarEndOffsets,
+	}, pos, nil
+}
+
+// readLengthPrefixed reads a string, preceded by its length, from irBuf. tag
+// is the tag read before irBuf and determines the length's integer type.
+// uByteTag is the tag for a uint8 length, and is followed by the tags for a
+// uint16 and int32 length. Returns:
+//   - success: the string, the position read to in irBuf, nil
+//   - error: nil, 0, [IncompleteIr] or [CorruptedIr] error
+func readLengthPrefixed(irBuf []byte, tag byte, uByteTag byte) ([]byte, int, error) {
+	var length int
+	var pos int
+	switch tag - uByteTag {
+	case 0:
+		if len(irBuf) < 1 {
+			return nil, 0, IncompleteIr
+		}
+		length = int(irBuf[0])
+		pos = 1
+	case 1:
+		if len(irBuf) < 2 {
+			return nil, 0, IncompleteIr
+		}
+		length = int(binary.BigEndian.Uint16(irBuf))
+		pos = 2
+	default:
+		if len(irBuf) < 4 {
+			return nil, 0, IncompleteIr
+		}
+		length = int(int32(binary.BigEndian.Uint32(irBuf)))
+		if 0 > length {
+			return nil, 0, CorruptedIr
+		}
+		pos = 4
+	}
+	if len(irBuf) < pos+length {
+		return nil, 0, IncompleteIr
+	}
+	return irBuf[pos : pos+length], pos + length, nil
+}
+
+// readTimestamp reads a tagged timestamp from irBuf. Eight byte encoded IR
+// stores the timestamp itself, while four byte encoded IR stores the delta
+// from the previous log event's timestamp using the smallest integer type
+// possible. Returns:
+//   - success: the timestamp or timestamp delta, the position read to in
+//     irBuf, nil
+//   - error: 0, 0, [IncompleteIr] or [CorruptedIr] error
+func readTimestamp(irBuf []byte, isFourByte bool) (ffi.EpochTimeMs, int, error) {
+	if 0 >= len(irBuf) {
+		return 0, 0, IncompleteIr
+	}
+	tag := irBuf[0]
+	var size int
+	switch {
+	case false == isFourByte && tagTimestampVal == tag:
+		size = 8
+	case isFourByte && tagTimestampDeltaByte == tag:
+		size = 1
+	case isFourByte && tagTimestampDeltaShort == tag:
+		size = 2
+	case isFourByte && tagTimestampDeltaInt == tag:
+		size = 4
+	case isFourByte && tagTimestampDeltaLong == tag:
+		size = 8
+	default:
+		return 0, 0, CorruptedIr
+	}
+	if len(irBuf) < 1+size {
+		return 0, 0, IncompleteIr
+	}
+	var timestamp ffi.EpochTimeMs
+	switch size {
+	case 1:
+		timestamp = ffi.EpochTimeMs(int8(irBuf[1]))
+	case 2:
+		timestamp = ffi.EpochTimeMs(int16(binary.BigEndian.Uint16(irBuf[1:])))
+	case 4:
+		timestamp = ffi.EpochTimeMs(int32(binary.BigEndian.Uint32(irBuf[1:])))
+	default:
+		timestamp = ffi.EpochTimeMs(int64(binary.BigEndian.Uint64(irBuf[1:])))
+	}
+	return timestamp, 1 + size, nil
+}
@@ -0,0 +1,53 @@
+package ir
+
+import (
+	"unsafe"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+)
+
+// goEncoder is an [Encoder] implemented purely in Go, producing results
+// identical to the native (C++) encoder. Its buffers are reused for each
+// LogMessageView it returns, matching the lifetime of views returned by the
+// native encoder.
+type goEncoder[T EightByteEncoding | FourByteEncoding] struct {
+	logtype           []byte
+	vars              []T
+	dictVars          []byte
+	dictVarEndOffsets []int32
+}
+
+// Close releases the encoder's buffers. A goEncoder does not use any C++
+// allocated memory, so calling Close is not required to avoid a leak.
+func (encoder *goEncoder[T]) Close() error {
+	*encoder = goEncoder[T]{}
+	return nil
+}
+
+// Encode a log message into CLP IR, returning a view of the encoded message.
+func (encoder *goEncoder[T]) EncodeLogMessage(
+	logMessage ffi.LogMessage,
+) (*LogMessageView[T], error) {
+	encoder.logtype, encoder.vars, encoder.dictVars, encoder.dictVarEndOffsets = encodeMessage(
+		logMessage,
+		encoder.logtype[:0],
+		encoder.vars[:0],
+		encoder.dictVars[:0],
+		encoder.dictVarEndOffsets[:0],
+	)
+	var msgView LogMessageView[T]
+	msgView.Logtype = unsafe.String(unsafe.SliceData(encoder.logtype), len(encoder.logtype))
+	msgView.Vars = encoder.vars
+	msgView.DictVars = unsafe.String(unsafe.SliceData(encoder.dictVars), len(encoder.dictVars))
+	msgView.DictVarEndOffsets = encoder.dictVarEndOffsets
+	return &msgView, nil
+}
+
+// Encode log messages into CLP IR, returning the encoded messages. The encoded
+// messages are stored in contiguous memory that is not reused by the encoder,
+// so they remain valid after future calls.
+func (encoder *goEncoder[T]) EncodeLogMessages(
+	logMessages []ffi.LogMessage,
+) ([]LogMessage[T], error) {
+	return encodeLogMessages[T](encoder, logMessages)
+}
@@ -0,0 +1,326 @@
+//go:build cgo && !purego
+
+package ir
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+	"github.com/y-scope/clp-ffi-go/search"
+)
+
+// Fragments used to build random log messages that exercise the boundaries of
+// CLP's variable encoding rules.
+var crossTestFragments = []string{
+	" ", " ", " ", ":", ",", "/", "=", "-", ".", "+", "_", "\\", "\x11", "\x12", "\x13", "\xff",
+	"0", "1", "9", "a", "f", "F", "x", "z", "Z", "1.", ".5", "-.5", "-0", "00", "0.0", "00.50",
+	"2147483647", "2147483648", "-2147483648", "-2147483649",
+	"9223372036854775807", "9223372036854775808", "-9223372036854775808",
+	"3355.4431", "3355.4432", "-3355.4431", "1234567.8", "12345678.9", "0.00000001",
+	"9.007199254740993", "1234567890123456.7", "deadbeef", "0x1f", "ff", "user=alice",
+}
+
+func generateCrossTestMessages(num int) []ffi.LogMessage {
+	rng := rand.New(rand.NewSource(0))
+	messages := make([]ffi.LogMessage, num)
+	for i := range messages {
+		var sb strings.Builder
+		for j := rng.Intn(24); j > 0; j-- {
+			sb.WriteString(crossTestFragments[rng.Intn(len(crossTestFragments))])
+		}
+		messages[i] = sb.String()
+	}
+	return append(messages, encoderTestMessages...)
+}
+
+func TestGoEncoderMatchesNativeEightByte(t *testing.T) {
+	native, _ := EightByteEncoder()
+	defer native.Close()
+	testGoEncoderMatchesNative(t, native, &goEncoder[EightByteEncoding]{})
+}
+
+func TestGoEncoderMatchesNativeFourByte(t *testing.T) {
+	native, _ := FourByteEncoder()
+	defer native.Close()
+	testGoEncoderMatchesNative(t, native, &goEncoder[FourByteEncoding]{})
+}
+
+func TestGoDecoderMatchesNativeEightByte(t *testing.T) {
+	native, _ := EightByteDecoder()
+	defer native.Close()
+	testGoDecoderMatchesNative(
+		t,
+		&goEncoder[EightByteEncoding]{},
+		native,
+		&goDecoder[EightByteEncoding]{},
+	)
+}
+
+func TestGoDecoderMatchesNativeFourByte(t *testing.T) {
+	native, _ := FourByteDecoder()
+	defer native.Close()
+	testGoDecoderMatchesNative(
+		t,
+		&goEncoder[FourByteEncoding]{},
+		native,
+		&goDecoder[FourByteEncoding]{},
+	)
+}
+
+func testGoEncoderMatchesNative[T EightByteEncoding | FourByteEncoding](
+	t *testing.T,
+	native Encoder[T],
+	goenc *goEncoder[T],
+) {
+	logMessages := generateCrossTestMessages(10000)
+	for _, logMessage := range logMessages {
+		expected, err := native.EncodeLogMessage(logMessage)
+		if nil != err {
+			t.Fatalf("native EncodeLogMessage failed: %v", err)
+		}
+		actual, err := goenc.EncodeLogMessage(logMessage)
+		if nil != err {
+			t.Fatalf("goEncoder.EncodeLogMessage failed: %v", err)
+		}
+		assertLogMessagesEqual(t, actual.LogMessage, expected.LogMessage)
+	}
+
+	// The native encoder encodes the whole batch in a single call
+	expected, err := native.(BatchEncoder[T]).EncodeLogMessages(logMessages)
+	if nil != err {
+		t.Fatalf("native EncodeLogMessages failed: %v", err)
+	}
+	actual, err := goenc.EncodeLogMessages(logMessages)
+	if nil != err {
+		t.Fatalf("goEncoder.EncodeLogMessages failed: %v", err)
+	}
+	for i := range logMessages {
+		assertLogMessagesEqual(t, actual[i], expected[i])
+	}
+}
+
+func testGoDecoderMatchesNative[T EightByteEncoding | FourByteEncoding](
+	t *testing.T,
+	encoder Encoder[T],
+	native Decoder[T],
+	godec Decoder[T],
+) {
+	for _, logMessage := range generateCrossTestMessages(10000) {
+		msg, _ := encoder.EncodeLogMessage(logMessage)
+		actual, err := godec.DecodeLogMessage(msg.LogMessage)
+		if nil != err {
+			t.Fatalf("goDecoder.DecodeLogMessage failed: %v", err)
+		}
+		if logMessage != *actual {
+			t.Fatalf("goDecoder.DecodeLogMessage wrong message: %q != %q", *actual, logMessage)
+		}
+		// The native decoder does not support escaped placeholders
+		if strings.ContainsAny(logMessage, "\x11\x12\x13\\") {
+			continue
+		}
+		expected, err := native.DecodeLogMessage(msg.LogMessage)
+		if nil != err {
+			t.Fatalf("native DecodeLogMessage failed: %v", err)
+		}
+		if *expected != *actual {
+			t.Fatalf("DecodeLogMessage mismatch: %q != %q", *actual, *expected)
+		}
+	}
+}
+
+// generateCrossTestEvents returns log events using the messages from
+// generateCrossTestMessages, with timestamp deltas exercising every size of
+// four byte encoded timestamp delta.
+func generateCrossTestEvents(num int) []ffi.LogEvent {
+	rng := rand.New(rand.NewSource(1))
+	deltas := []ffi.EpochTimeMs{0, 1, -1, 127, -128, 128, 32767, -32769, 1 << 31, -(1 << 40)}
+	messages := generateCrossTestMessages(num)
+	events := make([]ffi.LogEvent, len(messages))
+	timestamp := ffi.EpochTimeMs(1700000000000)
+	for i, msg := range messages {
+		timestamp += deltas[rng.Intn(len(deltas))]
+		events[i] = ffi.LogEvent{LogMessage: msg, Timestamp: timestamp}
+	}
+	return events
+}
+
+var crossTestTimestampInfo = TimestampInfo{
+	Pattern:       "<%Y-%m-%d> \"%H:%M:%S\"",
+	PatternSyntax: "java & more",
+	TimeZoneId:    "Ünïcode/Zone",
+}
+
+func TestGoSerializerMatchesNativeEightByte(t *testing.T) {
+	tsInfo := crossTestTimestampInfo
+	native, nativePreamble, err := EightByteSerializer(
+		tsInfo.Pattern,
+		tsInfo.PatternSyntax,
+		tsInfo.TimeZoneId,
+	)
+	if nil != err {
+		t.Fatalf("EightByteSerializer failed: %v", err)
+	}
+	defer native.Close()
+	goser, goPreamble, err := goEightByteSerializer(
+		tsInfo.Pattern,
+		tsInfo.PatternSyntax,
+		tsInfo.TimeZoneId,
+	)
+	if nil != err {
+		t.Fatalf("goEightByteSerializer failed: %v", err)
+	}
+	testGoSerializerMatchesNative(t, native, nativePreamble, goser, goPreamble)
+}
+
+func TestGoSerializerMatchesNativeFourByte(t *testing.T) {
+	tsInfo := crossTestTimestampInfo
+	native, nativePreamble, err := FourByteSerializer(
+		tsInfo.Pattern,
+		tsInfo.PatternSyntax,
+		tsInfo.TimeZoneId,
+		1700000000000,
+	)
+	if nil != err {
+		t.Fatalf("FourByteSerializer failed: %v", err)
+	}
+	defer native.Close()
+	goser, goPreamble, err := goFourByteSerializer(
+		tsInfo.Pattern,
+		tsInfo.PatternSyntax,
+		tsInfo.TimeZoneId,
+		1700000000000,
+	)
+	if nil != err {
+		t.Fatalf("goFourByteSerializer failed: %v", err)
+	}
+	testGoSerializerMatchesNative(t, native, nativePreamble, goser, goPreamble)
+}
+
+func testGoSerializerMatchesNative(
+	t *testing.T,
+	native Serializer,
+	nativePreamble BufView,
+	goser Serializer,
+	goPreamble BufView,
+) {
+	if false == bytes.Equal(nativePreamble, goPreamble) {
+		t.Fatalf("preamble mismatch:\n%q\n%q", goPreamble, nativePreamble)
+	}
+	for _, event := range generateCrossTestEvents(10000) {
+		expected, err := native.SerializeLogEvent(event)
+		if nil != err {
+			t.Fatalf("native SerializeLogEvent failed: %v", err)
+		}
+		actual, err := goser.SerializeLogEvent(event)
+		if nil != err {
+			t.Fatalf("goSerializer.SerializeLogEvent failed: %v", err)
+		}
+		if false == bytes.Equal(expected, actual) {
+			t.Fatalf("SerializeLogEvent mismatch for %q:\n%x\n%x", event, actual, expected)
+		}
+	}
+}
+
+func TestGoDeserializerMatchesNativeEightByte(t *testing.T) {
+	serializer, preamble, _ := EightByteSerializer("", "", "")
+	defer serializer.Close()
+	testGoDeserializerMatchesNative(
+		t,
+		serializeTestStream(t, serializer, preamble, generateCrossTestEvents(10000)),
+	)
+}
+
+func TestGoDeserializerMatchesNativeFourByte(t *testing.T) {
+	serializer, preamble, _ := FourByteSerializer("", "", "", 1700000000000)
+	defer serializer.Close()
+	testGoDeserializerMatchesNative(
+		t,
+		serializeTestStream(t, serializer, preamble, generateCrossTestEvents(10000)),
+	)
+}
+
+func testGoDeserializerMatchesNative(t *testing.T, irStream []byte) {
+	queries := []search.WildcardQuery{
+		search.NewWildcardQuery("*user=ALICE*", false),
+		search.NewWildcardQuery("*\\\\?1*", true),
+		search.NewWildcardQuery("?*9.0*7", true),
+		search.NewWildcardQuery("*-0 *", true),
+	}
+	timeIntervals := []search.TimestampInterval{
+		{Lower: math.MinInt64, Upper: math.MaxInt64},
+		{Lower: 1700000000000 - 1<<42, Upper: 1700000000000 + 1<<40},
+	}
+	// Each case deserializes the whole stream with the given queries and time
+	// interval, or with DeserializeLogEvent if there are no queries
+	type crossTestCase struct {
+		queries      []search.WildcardQuery
+		timeInterval search.TimestampInterval
+	}
+	testCases := []crossTestCase{{}}
+	for i := range queries {
+		for _, timeInterval := range timeIntervals {
+			testCases = append(testCases, crossTestCase{queries[i : i+1], timeInterval})
+		}
+	}
+	testCases = append(testCases, crossTestCase{queries, timeIntervals[1]})
+
+	for _, testCase := range testCases {
+		native, nativePos, err := DeserializePreamble(irStream)
+		if nil != err {
+			t.Fatalf("DeserializePreamble failed: %v", err)
+		}
+		godes, goPos, err := goDeserializePreamble(irStream)
+		if nil != err {
+			t.Fatalf("goDeserializePreamble failed: %v", err)
+		}
+		if nativePos != goPos || native.TimestampInfo() != godes.TimestampInfo() {
+			t.Fatalf("preamble mismatch: %v %v != %v %v",
+				goPos, godes.TimestampInfo(), nativePos, native.TimestampInfo())
+		}
+		mergedQuery := search.MergeWildcardQueries(testCase.queries)
+		numEvents := 0
+		for {
+			var expected, actual *ffi.LogEventView
+			var expectedErr, actualErr error
+			var expectedIdx, actualIdx int
+			var n int
+			if nil == testCase.queries {
+				expected, n, expectedErr = native.DeserializeLogEvent(irStream[nativePos:])
+				nativePos += n
+				actual, n, actualErr = godes.DeserializeLogEvent(irStream[goPos:])
+				goPos += n
+			} else {
+				expected, n, expectedIdx, expectedErr =
+					native.DeserializeWildcardMatchWithTimeInterval(
+						irStream[nativePos:],
+						mergedQuery,
+						testCase.timeInterval,
+					)
+				nativePos += n
+				actual, n, actualIdx, actualErr = godes.DeserializeWildcardMatchWithTimeInterval(
+					irStream[goPos:],
+					mergedQuery,
+					testCase.timeInterval,
+				)
+				goPos += n
+			}
+			if expectedErr != actualErr || nativePos != goPos || expectedIdx != actualIdx {
+				t.Fatalf("deserialization mismatch after %v events: %v %v %v != %v %v %v",
+					numEvents, actualErr, goPos, actualIdx, expectedErr, nativePos, expectedIdx)
+			}
+			if nil != expectedErr {
+				break
+			}
+			if *expected != *actual {
+				t.Fatalf("deserialized event mismatch: %q != %q", *actual, *expected)
+			}
+			numEvents++
+		}
+		native.Close()
+		godes.Close()
+	}
+}
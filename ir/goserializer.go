@@ -0,0 +1,328 @@
+package ir
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"strconv"
+	"time"
+	"unsafe"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+)
+
+// goEightByteSerializer is the pure Go equivalent of [EightByteSerializer].
+func goEightByteSerializer(
+	tsPattern string,
+	tsPatternSyntax string,
+	timeZoneId string,
+) (Serializer, BufView, error) {
+	irs := goSerializer[EightByteEncoding]{
+		tsInfo: TimestampInfo{tsPattern, tsPatternSyntax, timeZoneId},
+	}
+	preamble, err := appendPreamble(nil, false, irs.tsInfo, 0, nil)
+	if nil != err {
+		return nil, nil, err
+	}
+	irs.irBuf = preamble
+	return &irs, preamble, nil
+}
+
+// goFourByteSerializer is the pure Go equivalent of [FourByteSerializer].
+func goFourByteSerializer(
+	tsPattern string,
+	tsPatternSyntax string,
+	timeZoneId string,
+	referenceTs ffi.EpochTimeMs,
+) (Serializer, BufView, error) {
+	irs := goSerializer[FourByteEncoding]{
+		tsInfo:        TimestampInfo{tsPattern, tsPatternSyntax, timeZoneId},
+		prevTimestamp: referenceTs,
+	}
+	preamble, err := appendPreamble(nil, true, irs.tsInfo, referenceTs, nil)
+	if nil != err {
+		return nil, nil, err
+	}
+	irs.irBuf = preamble
+	return &irs, preamble, nil
+}
+
+// goSerializer is a [Serializer] implemented purely in Go, producing IR
+// identical to the native (C++) serializer. Its buffers are reused for each
+// BufView it returns, matching the lifetime of views returned by the native
+// serializer. For four byte encoded IR, prevTimestamp stores the previous log
+// event's timestamp (initially the reference timestamp), as only the
+// timestamp delta between log events is encoded.
+type goSerializer[T EightByteEncoding | FourByteEncoding] struct {
+	encodedLogMessageBuf[T]
+	tsInfo        TimestampInfo
+	prevTimestamp ffi.EpochTimeMs
+	irBuf         []byte
+}
+
+// Close releases the serializer's buffers. A goSerializer does not use any C++
+// allocated memory, so calling Close is not required to avoid a leak.
+func (serializer *goSerializer[T]) Close() error {
+	serializer.logtype = nil
+	serializer.vars = nil
+	serializer.dictVars = nil
+	serializer.dictVarEndOffsets = nil
+	serializer.irBuf = nil
+	return nil
+}
+
+// Returns the TimestampInfo of the Serializer.
+func (serializer *goSerializer[T]) TimestampInfo() TimestampInfo {
+	return serializer.tsInfo
+}
+
+// SerializeLogEvent attempts to serialize the log event, event, into a CLP IR
+// byte stream. On error returns:
+//   - nil BufView
+//   - [IrError] error: the log event could not be serialized
+func (serializer *goSerializer[T]) SerializeLogEvent(event ffi.LogEvent) (BufView, error) {
+	msg := serializer.encodeLogMessage(event.LogMessage)
+	return serializer.serializeEncodedLogEvent(msg, event.Timestamp)
+}
+
+// SerializeUtcOffsetChange serializes a change of the UTC offset to utcOffset
+// (truncated to whole seconds), applying to the log events serialized after
+// it. Always returns a nil error.
+func (serializer *goSerializer[T]) SerializeUtcOffsetChange(
+	utcOffset time.Duration,
+) (BufView, error) {
+	serializer.irBuf = appendUtcOffsetChange(serializer.irBuf[:0], utcOffset)
+	return serializer.irBuf, nil
+}
+
+// serializeEncodedLogEvent serializes an already encoded log message with
+// timestamp into the serializer's buffer, returning a view of it. On error
+// returns:
+//   - nil BufView
+//   - [CorruptedIr] error: a component of msg is too large to serialize, or
+//     msg's placeholders are inconsistent with its variables
+func (serializer *goSerializer[T]) serializeEncodedLogEvent(
+	msg LogMessage[T],
+	timestamp ffi.EpochTimeMs,
+) (BufView, error) {
+	var t T
+	_, isFourByte := any(t).(FourByteEncoding)
+	irTimestamp := timestamp
+	if isFourByte {
+		irTimestamp = timestamp - serializer.prevTimestamp
+	}
+	irBuf, err := appendEncodedLogEvent(serializer.irBuf[:0], msg, irTimestamp)
+	if nil != err {
+		return nil, err
+	}
+	serializer.irBuf = irBuf
+	serializer.prevTimestamp = timestamp
+	return irBuf, nil
+}
+
+// encodeLogMessage encodes logMessage into buf, returning a view of it valid
+// until the next encoding.
+func (buf *encodedLogMessageBuf[T]) encodeLogMessage(logMessage ffi.LogMessage) LogMessage[T] {
+	buf.logtype, buf.vars, buf.dictVars, buf.dictVarEndOffsets = encodeMessage(
+		logMessage,
+		buf.logtype[:0],
+		buf.vars[:0],
+		buf.dictVars[:0],
+		buf.dictVarEndOffsets[:0],
+	)
+	return LogMessage[T]{
+		Logtype:           unsafe.String(unsafe.SliceData(buf.logtype), len(buf.logtype)),
+		Vars:              buf.vars,
+		DictVars:          unsafe.String(unsafe.SliceData(buf.dictVars), len(buf.dictVars)),
+		DictVarEndOffsets: buf.dictVarEndOffsets,
+	}
+}
+
+// appendPreamble appends an IR stream preamble, containing the stream's magic
+// number and its metadata encoded as JSON, to irBuf and returns the extended
+// buffer. The reference timestamp is only stored for four byte encoded IR, and
+// userMetadata is only stored if it is not nil. The metadata is encoded with
+// its keys sorted and without escaping HTML characters, matching the native
+// serializer. On error returns:
+//   - irBuf unchanged
+//   - [CorruptedIr] error: the metadata is too large to serialize
+//   - [encoding/json] error: marshalling the metadata failed
+func appendPreamble(
+	irBuf []byte,
+	isFourByte bool,
+	tsInfo TimestampInfo,
+	referenceTs ffi.EpochTimeMs,
+	userMetadata map[string]any,
+) ([]byte, error) {
+	metadata := map[string]any{
+		metadataVersionKey:                   metadataVersionValue,
+		metadataVariablesSchemaIdKey:         metadataVariablesSchemaIdValue,
+		metadataVariableEncodingMethodsIdKey: metadataVariableEncodingMethodsIdValue,
+		metadataTimestampPatternKey:          tsInfo.Pattern,
+		metadataTimestampPatternSyntaxKey:    tsInfo.PatternSyntax,
+		metadataTzIdKey:                      tsInfo.TimeZoneId,
+	}
+	if isFourByte {
+		metadata[metadataReferenceTimestampKey] = strconv.FormatInt(int64(referenceTs), 10)
+	}
+	if nil != userMetadata {
+		metadata[metadataUserDefinedMetadataKey] = userMetadata
+	}
+	return appendPreambleMetadata(irBuf, isFourByte, metadata)
+}
+
+// appendPreambleMetadata appends an IR stream preamble containing metadata to
+// irBuf, as described by [appendPreamble]. On error returns:
+//   - irBuf unchanged
+//   - [CorruptedIr] error: the metadata is too large to serialize
+//   - [encoding/json] error: marshalling the metadata failed
+func appendPreambleMetadata(irBuf []byte, isFourByte bool, metadata any) ([]byte, error) {
+	var metadataBuf bytes.Buffer
+	encoder := json.NewEncoder(&metadataBuf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(metadata); nil != err {
+		return irBuf, err
+	}
+	// Encode terminates the JSON with a newline
+	metadataJson := bytes.TrimSuffix(metadataBuf.Bytes(), []byte{'\n'})
+	if len(metadataJson) > math.MaxUint16 {
+		return irBuf, CorruptedIr
+	}
+
+	if isFourByte {
+		irBuf = append(irBuf, fourByteEncodingMagicNumber[:]...)
+	} else {
+		irBuf = append(irBuf, eightByteEncodingMagicNumber[:]...)
+	}
+	irBuf = append(irBuf, metadataEncodingJson)
+	if len(metadataJson) <= math.MaxUint8 {
+		irBuf = append(irBuf, tagMetadataLenUByte, byte(len(metadataJson)))
+	} else {
+		irBuf = append(irBuf, tagMetadataLenUShort)
+		irBuf = binary.BigEndian.AppendUint16(irBuf, uint16(len(metadataJson)))
+	}
+	return append(irBuf, metadataJson...), nil
+}
+
+// appendEncodedLogEvent appends the serialized form of an encoded log message
+// and its timestamp to irBuf and returns the extended buffer. The log message is
+// serialized by [appendEncodedLogMessage], followed by the timestamp. For four
+// byte encoded IR, timestamp must be the delta from the previous log event's
+// timestamp. Mirrors the c++ equivalent
+// clp::ffi::ir_stream::*_encoding::serialize_log_event. On error returns:
+//   - irBuf unchanged
+//   - [CorruptedIr] error: a component of msg is too large to serialize, or
+//     msg's placeholders are inconsistent with its variables
+func appendEncodedLogEvent[T EightByteEncoding | FourByteEncoding](
+	irBuf []byte,
+	msg LogMessage[T],
+	timestamp ffi.EpochTimeMs,
+) ([]byte, error) {
+	irBuf, err := appendEncodedLogMessage(irBuf, msg)
+	if nil != err {
+		return irBuf, err
+	}
+
+	var t T
+	if _, isFourByte := any(t).(FourByteEncoding); false == isFourByte {
+		irBuf = append(irBuf, tagTimestampVal)
+		return binary.BigEndian.AppendUint64(irBuf, uint64(timestamp)), nil
+	}
+	switch {
+	case math.MinInt8 <= timestamp && timestamp <= math.MaxInt8:
+		irBuf = append(irBuf, tagTimestampDeltaByte, byte(int8(timestamp)))
+	case math.MinInt16 <= timestamp && timestamp <= math.MaxInt16:
+		irBuf = append(irBuf, tagTimestampDeltaShort)
+		irBuf = binary.BigEndian.AppendUint16(irBuf, uint16(timestamp))
+	case math.MinInt32 <= timestamp && timestamp <= math.MaxInt32:
+		irBuf = append(irBuf, tagTimestampDeltaInt)
+		irBuf = binary.BigEndian.AppendUint32(irBuf, uint32(timestamp))
+	default:
+		irBuf = append(irBuf, tagTimestampDeltaLong)
+		irBuf = binary.BigEndian.AppendUint64(irBuf, uint64(timestamp))
+	}
+	return irBuf, nil
+}
+
+// appendEncodedLogMessage appends the serialized form of an encoded log message
+// to irBuf and returns the extended buffer. Each variable is serialized in the
+// order it appears in the log message, followed by the logtype. On error
+// returns:
+//   - irBuf unchanged
+//   - [CorruptedIr] error: a component of msg is too large to serialize, or
+//     msg's placeholders are inconsistent with its variables
+func appendEncodedLogMessage[T EightByteEncoding | FourByteEncoding](
+	irBuf []byte,
+	msg LogMessage[T],
+) ([]byte, error) {
+	origLen := len(irBuf)
+	var t T
+	_, isFourByte := any(t).(FourByteEncoding)
+	varIdx := 0
+	dictVarIdx := 0
+	dictVarBegin := int32(0)
+	var ok bool
+	for i := 0; i < len(msg.Logtype); i++ {
+		switch msg.Logtype[i] {
+		case placeholderInteger, placeholderFloat:
+			if varIdx >= len(msg.Vars) {
+				return irBuf[:origLen], CorruptedIr
+			}
+			if isFourByte {
+				irBuf = append(irBuf, tagVarFourByteEncoding)
+				irBuf = binary.BigEndian.AppendUint32(irBuf, uint32(msg.Vars[varIdx]))
+			} else {
+				irBuf = append(irBuf, tagVarEightByteEncoding)
+				irBuf = binary.BigEndian.AppendUint64(irBuf, uint64(msg.Vars[varIdx]))
+			}
+			varIdx++
+		case placeholderDictionary:
+			if dictVarIdx >= len(msg.DictVarEndOffsets) {
+				return irBuf[:origLen], CorruptedIr
+			}
+			dictVarEnd := msg.DictVarEndOffsets[dictVarIdx]
+			if dictVarEnd < dictVarBegin || int(dictVarEnd) > len(msg.DictVars) {
+				return irBuf[:origLen], CorruptedIr
+			}
+			irBuf, ok = appendLengthPrefixed(
+				irBuf,
+				tagVarStrLenUByte,
+				msg.DictVars[dictVarBegin:dictVarEnd],
+			)
+			if false == ok {
+				return irBuf[:origLen], CorruptedIr
+			}
+			dictVarBegin = dictVarEnd
+			dictVarIdx++
+		case placeholderEscape:
+			i++
+		}
+	}
+	irBuf, ok = appendLengthPrefixed(irBuf, tagLogtypeStrLenUByte, msg.Logtype)
+	if false == ok {
+		return irBuf[:origLen], CorruptedIr
+	}
+	return irBuf, nil
+}
+
+// appendLengthPrefixed appends str to irBuf, preceded by the tag and length
+// using the smallest integer type possible. uByteTag is the tag for a uint8
+// length, and is followed by the tags for a uint16 and int32 length. Returns:
+//   - success: the extended buffer, true
+//   - failure: undefined buffer, false if str is too long to serialize
+func appendLengthPrefixed(irBuf []byte, uByteTag byte, str string) ([]byte, bool) {
+	switch {
+	case len(str) <= math.MaxUint8:
+		irBuf = append(irBuf, uByteTag, byte(len(str)))
+	case len(str) <= math.MaxUint16:
+		irBuf = append(irBuf, uByteTag+1)
+		irBuf = binary.BigEndian.AppendUint16(irBuf, uint16(len(str)))
+	case len(str) <= math.MaxInt32:
+		irBuf = append(irBuf, uByteTag+2)
+		irBuf = binary.BigEndian.AppendUint32(irBuf, uint32(len(str)))
+	default:
+		return irBuf, false
+	}
+	return append(irBuf, str...), true
+}
@@ -10,17 +10,34 @@
 // [log viewer]: https://github.com/y-scope/yscope-log-viewer
 package ir
 
-/*
-#include <ffi_go/defs.h>
-*/
-import "C"
-
 // Must match c++ equivalent types
 type (
 	EightByteEncoding = int64
 	FourByteEncoding  = int32
 )
 
+// Encoding identifies the encoding of an IR stream: the type used to store the
+// encoded variables of each log message ([EightByteEncoding] or
+// [FourByteEncoding]). Four byte encoded IR also stores each timestamp as the
+// delta from the previous log event's timestamp.
+//
+//go:generate stringer -type=Encoding
+type Encoding int
+
+const (
+	EncodingEightByte Encoding = iota
+	EncodingFourByte
+)
+
+// encodingOf returns the Encoding using T for its encoded variables.
+func encodingOf[T EightByteEncoding | FourByteEncoding]() Encoding {
+	var t T
+	if _, ok := any(t).(FourByteEncoding); ok {
+		return EncodingFourByte
+	}
+	return EncodingEightByte
+}
+
 // TimestampInfo contains general information applying to all timestamps in
 // contiguous IR. This information comes from the metadata in the IR preamble.
 type TimestampInfo struct {
@@ -0,0 +1,88 @@
+package ir
+
+import "errors"
+
+var (
+	errNotKVStream = errors.New("not a key-value pair IR stream")
+	errKVStream    = errors.New("unsupported key-value pair IR stream")
+)
+
+// A KVSerializer exports functions to serialize structured log events into a
+// CLP key-value pair IR byte stream. Key-value pair IR streams store each log
+// event (e.g. a JSON object) as key-value pairs, where each key is a node of a
+// [SchemaTree] built up as the stream is serialized. A log event only stores
+// the id of each of its keys followed by their values, which may be:
+//   - nil
+//   - bool
+//   - integers (unsigned integers must fit in an int64)
+//   - floats
+//   - strings (strings containing a space are encoded like log messages, with
+//     their variables extracted)
+//   - slices and arrays (stored as JSON, without adding their contents to the
+//     SchemaTree)
+//   - maps with string keys (nested log events)
+//
+// Key-value pair IR streams have no timestamp or [TimestampInfo] outside the
+// log events themselves. They are only supported by the pure Go
+// implementation, as the native library predates them. Like a [Serializer],
+// each function returns a BufView into memory owned by the KVSerializer that
+// is reused by the next serialization. Close should be called once the
+// KVSerializer is no longer needed.
+type KVSerializer interface {
+	SerializeKVEvent(event map[string]any) (BufView, error)
+	SchemaTree() *SchemaTree
+	Close() error
+}
+
+// EightByteKVSerializer creates and returns a new KVSerializer that writes
+// eight byte encoded key-value pair CLP IR and serializes an IR preamble into
+// a BufView using it. On error returns:
+//   - nil KVSerializer
+//   - nil BufView
+//   - [IrError] or [encoding/json] error: serializing the preamble failed
+func EightByteKVSerializer() (KVSerializer, BufView, error) {
+	return newKVSerializer[EightByteEncoding](nil)
+}
+
+// FourByteKVSerializer creates and returns a new KVSerializer that writes four
+// byte encoded key-value pair CLP IR and serializes an IR preamble into a
+// BufView using it. On error returns:
+//   - nil KVSerializer
+//   - nil BufView
+//   - [IrError] or [encoding/json] error: serializing the preamble failed
+func FourByteKVSerializer() (KVSerializer, BufView, error) {
+	return newKVSerializer[FourByteEncoding](nil)
+}
+
+// EightByteKVSerializerWithMetadata is [EightByteKVSerializer], additionally
+// storing userMetadata in the preamble's metadata. It is exposed when reading
+// the stream by [Deserializer].Metadata.
+func EightByteKVSerializerWithMetadata(
+	userMetadata map[string]any,
+) (KVSerializer, BufView, error) {
+	return newKVSerializer[EightByteEncoding](userMetadata)
+}
+
+// FourByteKVSerializerWithMetadata is [FourByteKVSerializer], additionally
+// storing userMetadata in the preamble's metadata. It is exposed when reading
+// the stream by [Deserializer].Metadata.
+func FourByteKVSerializerWithMetadata(
+	userMetadata map[string]any,
+) (KVSerializer, BufView, error) {
+	return newKVSerializer[FourByteEncoding](userMetadata)
+}
+
+// A KVDeserializer exports functions to deserialize structured log events from
+// a CLP key-value pair IR byte stream. [DeserializePreamble] returns a
+// KVDeserializer when the preamble belongs to a key-value pair IR stream. As a
+// [Deserializer], it deserializes each log event as its JSON encoding (with
+// sorted keys) and a 0 timestamp, so key-value pair IR streams can also be
+// read and searched as unstructured IR. The maps returned by
+// DeserializeKVEvent are newly allocated and owned by the caller. They use
+// int64 and float64 for all numbers, []any for arrays, and map[string]any for
+// nested objects.
+type KVDeserializer interface {
+	Deserializer
+	DeserializeKVEvent(irBuf []byte) (map[string]any, int, error)
+	SchemaTree() *SchemaTree
+}
@@ -0,0 +1,248 @@
+package ir
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"testing/iotest"
+
+	"github.com/y-scope/clp-ffi-go/search"
+)
+
+// kvTestEvents are structured log events and their expected deserialization.
+var kvTestEvents = []struct {
+	event    map[string]any
+	expected map[string]any
+}{
+	{
+		map[string]any{
+			"level":   "INFO",
+			"message": "took 12.5 ms to read 2147483648 bytes from file.txt",
+			"count":   42,
+			"ratio":   0.25,
+			"ok":      true,
+			"err":     nil,
+		},
+		map[string]any{
+			"level":   "INFO",
+			"message": "took 12.5 ms to read 2147483648 bytes from file.txt",
+			"count":   int64(42),
+			"ratio":   0.25,
+			"ok":      true,
+			"err":     nil,
+		},
+	},
+	{map[string]any{}, map[string]any{}},
+	{
+		map[string]any{
+			"count": "not an int",
+			"ok":    false,
+			"ctx": map[string]any{
+				"pod":     map[string]string{"name": "pod-1", "ns": "default"},
+				"retries": uint8(3),
+				"tags":    []string{"a", "b c"},
+				"empty":   map[string]any{},
+			},
+		},
+		map[string]any{
+			"count": "not an int",
+			"ok":    false,
+			"ctx": map[string]any{
+				"pod":     map[string]any{"name": "pod-1", "ns": "default"},
+				"retries": int64(3),
+				"tags":    []any{"a", "b c"},
+				"empty":   map[string]any{},
+			},
+		},
+	},
+	{
+		map[string]any{
+			"int8":  int64(math.MinInt8),
+			"int16": int64(math.MaxInt16),
+			"int32": int32(math.MinInt32),
+			"int64": uint64(math.MaxInt64),
+			"float": float32(-1.5),
+			"num":   json.Number("-9007199254740993"),
+			"array": []any{1, 2.5, "x", nil, map[string]any{"k": []int{7}}},
+			"esc":   "escaped \x11 \x12 \x13 \\ placeholders",
+		},
+		map[string]any{
+			"int8":  int64(math.MinInt8),
+			"int16": int64(math.MaxInt16),
+			"int32": int64(math.MinInt32),
+			"int64": int64(math.MaxInt64),
+			"float": -1.5,
+			"num":   int64(-9007199254740993),
+			"array": []any{int64(1), 2.5, "x", nil, map[string]any{"k": []any{int64(7)}}},
+			"esc":   "escaped \x11 \x12 \x13 \\ placeholders",
+		},
+	},
+}
+
+func TestKVSerDer(t *testing.T) {
+	for _, encoding := range []Encoding{EncodingEightByte, EncodingFourByte} {
+		t.Run(encoding.String(), func(t *testing.T) {
+			serializer, irStream := newTestKVSerializer(t, encoding)
+			defer serializer.Close()
+			for _, test := range kvTestEvents {
+				irView, err := serializer.SerializeKVEvent(test.event)
+				if nil != err {
+					t.Fatalf("SerializeKVEvent failed: %v", err)
+				}
+				irStream = append(irStream, irView...)
+			}
+			irStream = append(irStream, tagEof)
+
+			// Read one byte at a time to deserialize from incomplete IR
+			irreader, err := NewReaderSize(iotest.OneByteReader(bytes.NewReader(irStream)), 8)
+			if nil != err {
+				t.Fatalf("NewReader failed: %v", err)
+			}
+			defer irreader.Close()
+			kvDeserializer, ok := irreader.Deserializer.(KVDeserializer)
+			if false == ok {
+				t.Fatalf("NewReader returned %T, not a KVDeserializer", irreader.Deserializer)
+			}
+			for _, test := range kvTestEvents {
+				event, err := irreader.ReadKVEvent()
+				if nil != err {
+					t.Fatalf("ReadKVEvent failed: %v", err)
+				}
+				if false == reflect.DeepEqual(test.expected, event) {
+					t.Fatalf("ReadKVEvent wrong event:\n%#v\n%#v", event, test.expected)
+				}
+			}
+			if _, err = irreader.ReadKVEvent(); EndOfIr != err {
+				t.Fatalf("ReadKVEvent did not return EndOfIr: %v", err)
+			}
+			if false == reflect.DeepEqual(serializer.SchemaTree(), kvDeserializer.SchemaTree()) {
+				t.Fatalf("Deserializer schema tree differs from serializer")
+			}
+
+			// Skipping log events must still add their keys to the schema tree
+			irreader, err = NewReaderSize(iotest.OneByteReader(bytes.NewReader(irStream)), 8)
+			if nil != err {
+				t.Fatalf("NewReader failed: %v", err)
+			}
+			defer irreader.Close()
+			query := search.NewWildcardQuery("*placeholders*", true)
+			event, _, err := irreader.ReadToWildcardMatch([]search.WildcardQuery{query})
+			if nil != err {
+				t.Fatalf("ReadToWildcardMatch failed: %v", err)
+			}
+			expected, _ := json.Marshal(kvTestEvents[3].expected)
+			if string(expected) != event.LogMessageView {
+				t.Fatalf("ReadToWildcardMatch wrong event: %v != %s", event, expected)
+			}
+			kvDeserializer = irreader.Deserializer.(KVDeserializer)
+			if false == reflect.DeepEqual(serializer.SchemaTree(), kvDeserializer.SchemaTree()) {
+				t.Fatalf("Deserializer schema tree differs from serializer")
+			}
+
+			// As a Deserializer, log events are read as JSON
+			irreader, err = NewReader(bytes.NewReader(irStream))
+			if nil != err {
+				t.Fatalf("NewReader failed: %v", err)
+			}
+			defer irreader.Close()
+			for _, test := range kvTestEvents {
+				expected, _ := json.Marshal(test.expected)
+				event, err := irreader.Read()
+				if nil != err {
+					t.Fatalf("Read failed: %v", err)
+				}
+				if string(expected) != event.LogMessageView || 0 != event.Timestamp {
+					t.Fatalf("Read wrong event: %v != %s", event, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestKVSerializerIr(t *testing.T) {
+	serializer, _ := newTestKVSerializer(t, EncodingEightByte)
+	defer serializer.Close()
+	event := map[string]any{"b": map[string]any{"c": "x"}, "a": 1}
+	expectedIrs := [][]byte{
+		{
+			// New schema tree nodes: a (Int), b (Obj), b.c (Str)
+			tagSchemaTreeNodeInt, tagSchemaTreeNodeParentIdByte, 0, tagStrLenUByte, 1, 'a',
+			tagSchemaTreeNodeObj, tagSchemaTreeNodeParentIdByte, 0, tagStrLenUByte, 1, 'b',
+			tagSchemaTreeNodeStr, tagSchemaTreeNodeParentIdByte, 2, tagStrLenUByte, 1, 'c',
+			// Keys
+			tagSchemaTreeNodeIdByte, 1, tagSchemaTreeNodeIdByte, 3,
+			// Values
+			tagValueInt8, 1, tagStrLenUByte, 1, 'x',
+		},
+		{
+			tagSchemaTreeNodeIdByte, 1, tagSchemaTreeNodeIdByte, 3,
+			tagValueInt8, 1, tagStrLenUByte, 1, 'x',
+		},
+	}
+	for _, expectedIr := range expectedIrs {
+		irView, err := serializer.SerializeKVEvent(event)
+		if nil != err {
+			t.Fatalf("SerializeKVEvent failed: %v", err)
+		}
+		if false == bytes.Equal(expectedIr, irView) {
+			t.Fatalf("SerializeKVEvent wrong IR:\n%x\n%x", irView, expectedIr)
+		}
+	}
+	tree := serializer.SchemaTree()
+	if path := tree.Path(3); false == reflect.DeepEqual([]string{"b", "c"}, path) {
+		t.Fatalf("SchemaTree wrong path: %v", path)
+	}
+	if id, ok := tree.Find(SchemaTreeRootId, "a", SchemaTreeNodeInt); 1 != id || false == ok {
+		t.Fatalf("SchemaTree.Find returned %v, %v", id, ok)
+	}
+}
+
+func TestKVSerializerErrors(t *testing.T) {
+	serializer, _ := newTestKVSerializer(t, EncodingFourByte)
+	defer serializer.Close()
+	for _, event := range []map[string]any{
+		{"new": 1, "unsupported": make(chan int)},
+		{"new": 1, "uint64": uint64(math.MaxUint64)},
+		{"new": 1, "map": map[int]any{1: 1}},
+		{"new": 1, "num": json.Number("x")},
+	} {
+		if _, err := serializer.SerializeKVEvent(event); nil == err {
+			t.Fatalf("SerializeKVEvent succeeded for %v", event)
+		}
+		if 1 != serializer.SchemaTree().Len() {
+			t.Fatalf("SerializeKVEvent failure modified the schema tree")
+		}
+	}
+
+	irreader, err := NewReader(bytes.NewReader(serializeEncodedTestStream(
+		t,
+		EncodingFourByte,
+		TimestampInfo{},
+		nil,
+	)))
+	if nil != err {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer irreader.Close()
+	if _, err = irreader.ReadKVEvent(); nil == err {
+		t.Fatalf("ReadKVEvent succeeded for an unstructured IR stream")
+	}
+}
+
+// newTestKVSerializer returns a new KVSerializer for encoding and its preamble.
+func newTestKVSerializer(t *testing.T, encoding Encoding) (KVSerializer, []byte) {
+	var serializer KVSerializer
+	var preamble BufView
+	var err error
+	if EncodingFourByte == encoding {
+		serializer, preamble, err = FourByteKVSerializer()
+	} else {
+		serializer, preamble, err = EightByteKVSerializer()
+	}
+	if nil != err {
+		t.Fatalf("KVSerializer construction failed: %v", err)
+	}
+	return serializer, append([]byte{}, preamble...)
+}
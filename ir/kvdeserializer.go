@@ -0,0 +1,505 @@
+package ir
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"time"
+	"unsafe"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+	"github.com/y-scope/clp-ffi-go/search"
+)
+
+// newKVDeserializer returns a KVDeserializer for a key-value pair IR stream of
+// the given encoding and preamble metadata.
+func newKVDeserializer(isFourByte bool, metadata preambleMetadata) KVDeserializer {
+	if isFourByte {
+		return &kvDeserializer[FourByteEncoding]{
+			schemaTree:   newSchemaTree(),
+			userMetadata: metadata.userMetadata,
+			version:      metadata.version,
+		}
+	}
+	return &kvDeserializer[EightByteEncoding]{
+		schemaTree:   newSchemaTree(),
+		userMetadata: metadata.userMetadata,
+		version:      metadata.version,
+	}
+}
+
+// kvDeserializer is a [KVDeserializer] for an encoding of T, deserializing the
+// log events written by a [KVSerializer]. keyIds and logMessage are reused for
+// each log event, while the maps returned are always newly allocated.
+// utcOffset stores the UTC offset of the previous log event.
+type kvDeserializer[T EightByteEncoding | FourByteEncoding] struct {
+	encodedLogMessageBuf[T]
+	schemaTree   *SchemaTree
+	userMetadata map[string]any
+	version      string
+	utcOffset    time.Duration
+	keyIds       []int
+	logMessage   []byte
+}
+
+// Close releases the deserializer's buffers.
+func (deserializer *kvDeserializer[T]) Close() error {
+	deserializer.encodedLogMessageBuf = encodedLogMessageBuf[T]{}
+	deserializer.keyIds = nil
+	deserializer.logMessage = nil
+	return nil
+}
+
+// Returns the 0 value TimestampInfo, as key-value pair IR streams have none.
+func (deserializer *kvDeserializer[T]) TimestampInfo() TimestampInfo {
+	return TimestampInfo{}
+}
+
+// Returns the user-defined metadata of the IR stream.
+func (deserializer *kvDeserializer[T]) Metadata() map[string]any {
+	return deserializer.userMetadata
+}
+
+// Returns the IR protocol version of the IR stream.
+func (deserializer *kvDeserializer[T]) Version() string {
+	return deserializer.version
+}
+
+// Returns the encoding of the IR stream.
+func (deserializer *kvDeserializer[T]) Encoding() Encoding {
+	return encodingOf[T]()
+}
+
+// Returns the UTC offset of the most recently deserialized log event.
+func (deserializer *kvDeserializer[T]) UtcOffset() time.Duration {
+	return deserializer.utcOffset
+}
+
+// SchemaTree returns the schema tree containing every key deserialized so far.
+// The tree is owned by the deserializer and must not be modified.
+func (deserializer *kvDeserializer[T]) SchemaTree() *SchemaTree {
+	return deserializer.schemaTree
+}
+
+// DeserializeLogEvent attempts to read the next log event from the IR stream in
+// irBuf, returning it as an [ffi.LogEventView] whose log message is the log
+// event's JSON encoding and whose timestamp is 0, the position read to in irBuf
+// (the end of the log event in irBuf), and an error. On error returns:
+//   - nil *ffi.LogEventView
+//   - 0 position
+//   - [IrError] error: the log event could not be deserialized
+//   - [EndOfIr] error: found the IR stream EOF tag
+//   - [encoding/json] error: marshalling the log event failed
+func (deserializer *kvDeserializer[T]) DeserializeLogEvent(
+	irBuf []byte,
+) (*ffi.LogEventView, int, error) {
+	event, pos, err := deserializer.DeserializeKVEvent(irBuf)
+	if nil != err {
+		return nil, 0, err
+	}
+	view, err := deserializer.marshalLogEvent(event)
+	if nil != err {
+		return nil, 0, err
+	}
+	return view, pos, nil
+}
+
+// DeserializeLogEvents attempts to read up to max log events (every complete
+// log event in irBuf if max is not positive) from the IR stream in irBuf,
+// returning the deserialized [ffi.LogEventView]s, the position read to in irBuf
+// (the end of the last log event in irBuf), and an error. The log messages are
+// copied into memory owned by Go (see [BatchDeserializer]).
+// Deserialization stops early at the first error, which is only returned if no
+// log event was deserialized before it, so that the next call returns it
+// instead (e.g. [IncompleteIr] once irBuf ends). On error returns:
+//   - nil []ffi.LogEventView
+//   - 0 position
+//   - [IrError] error: CLP failed to successfully deserialize
+//   - [EndOfIr] error: CLP found the IR stream EOF tag
+func (deserializer *kvDeserializer[T]) DeserializeLogEvents(
+	irBuf []byte,
+	max int,
+) ([]ffi.LogEventView, int, error) {
+	return deserializeLogEvents(deserializer, irBuf, max)
+}
+
+// DeserializeWildcardMatchWithTimeInterval attempts to read the next log event
+// from the IR stream in irBuf whose JSON encoding matches mergedQuery. As every
+// log event has a 0 timestamp, either all or none of them are within
+// timeInterval. It returns the deserialized [ffi.LogEventView], the position
+// read to in irBuf (the end of the log event in irBuf), the index of the
+// matched query in mergedQuery, and an error. On error returns:
+//   - nil *ffi.LogEventView
+//   - 0 position
+//   - -1 index
+//   - [IrError] error: a log event could not be deserialized
+//   - [EndOfIr] error: found the IR stream EOF tag
+//   - [QueryNotFound] error: a log event past timeInterval was found
+//   - [encoding/json] error: marshalling a log event failed
+func (deserializer *kvDeserializer[T]) DeserializeWildcardMatchWithTimeInterval(
+	irBuf []byte,
+	mergedQuery search.MergedWildcardQuery,
+	timeInterval search.TimestampInterval,
+) (*ffi.LogEventView, int, int, error) {
+	// If irBuf ends before a match is found the same log events will be
+	// deserialized again once more IR is available, so the schema tree nodes
+	// they added must be removed (and the UTC offset rolled back).
+	numNodes := deserializer.schemaTree.Len()
+	utcOffset := deserializer.utcOffset
+	pos := 0
+	for {
+		event, n, err := deserializer.DeserializeKVEvent(irBuf[pos:])
+		if IncompleteIr == err {
+			deserializer.schemaTree.truncate(numNodes)
+			deserializer.utcOffset = utcOffset
+		}
+		if nil != err {
+			return nil, 0, -1, err
+		}
+		pos += n
+
+		if timeInterval.Upper <= 0 {
+			return nil, 0, -1, QueryNotFound
+		}
+		if timeInterval.Lower > 0 {
+			continue
+		}
+		view, err := deserializer.marshalLogEvent(event)
+		if nil != err {
+			return nil, 0, -1, err
+		}
+		if idx, ok := mergedQuery.Match(view.LogMessageView); ok {
+			return view, pos, idx, nil
+		}
+	}
+}
+
+// DeserializeKVEvent attempts to read the next log event from the IR stream in
+// irBuf, returning the deserialized structured log event, the position read to
+// in irBuf (the end of the log event in irBuf), and an error. If the log event
+// is not deserialized, the schema tree is left unchanged. The UTC offset change
+// packets preceding the log event are applied once the log event is read. On
+// error returns:
+//   - nil map
+//   - 0 position
+//   - [IncompleteIr] error: irBuf ends before the end of the log event
+//   - [CorruptedIr] error: the log event is malformed or inconsistent with the
+//     schema tree
+//   - [EndOfIr] error: found the IR stream EOF tag
+func (deserializer *kvDeserializer[T]) DeserializeKVEvent(
+	irBuf []byte,
+) (map[string]any, int, error) {
+	utcOffset, offsetPos, err := readUtcOffsetChanges(irBuf, deserializer.utcOffset)
+	if nil != err {
+		return nil, 0, err
+	}
+	numNodes := deserializer.schemaTree.Len()
+	event, pos, err := deserializer.deserializeKVEvent(irBuf[offsetPos:])
+	if nil != err {
+		deserializer.schemaTree.truncate(numNodes)
+		return nil, 0, err
+	}
+	deserializer.utcOffset = utcOffset
+	return event, offsetPos + pos, nil
+}
+
+// deserializeKVEvent implements DeserializeKVEvent, without removing the
+// schema tree nodes added on error. A log event is serialized as the schema
+// tree nodes it added, followed by the id of each of its keys, followed by the
+// value of each key (in the same order). An empty log event is serialized as a
+// single empty value. Mirrors the c++ equivalent
+// clp::ffi::ir_stream::Deserializer::deserialize_next_ir_unit.
+func (deserializer *kvDeserializer[T]) deserializeKVEvent(
+	irBuf []byte,
+) (map[string]any, int, error) {
+	if 0 >= len(irBuf) {
+		return nil, 0, IncompleteIr
+	}
+	if tagEof == irBuf[0] {
+		return nil, 0, EndOfIr
+	}
+
+	tree := deserializer.schemaTree
+	pos := 0
+	for {
+		if len(irBuf) <= pos {
+			return nil, 0, IncompleteIr
+		}
+		nodeTag := irBuf[pos]
+		if nodeTag < tagSchemaTreeNodeInt || nodeTag > tagSchemaTreeNodeObj {
+			break
+		}
+		parentId, n, err := readSchemaTreeNodeId(irBuf[pos+1:], tagSchemaTreeNodeParentIdByte)
+		if nil != err {
+			return nil, 0, err
+		}
+		pos += 1 + n
+		if len(irBuf) <= pos {
+			return nil, 0, IncompleteIr
+		}
+		tag := irBuf[pos]
+		if tag < tagStrLenUByte || tag > tagStrLenUInt {
+			return nil, 0, CorruptedIr
+		}
+		key, n, err := readLengthPrefixed(irBuf[pos+1:], tag, tagStrLenUByte)
+		if nil != err {
+			return nil, 0, err
+		}
+		pos += 1 + n
+
+		parent, ok := tree.Node(parentId)
+		if false == ok || SchemaTreeNodeObj != parent.Type {
+			return nil, 0, CorruptedIr
+		}
+		nodeType := SchemaTreeNodeType(nodeTag - tagSchemaTreeNodeInt)
+		if _, isNew := tree.insert(parentId, string(key), nodeType); false == isNew {
+			return nil, 0, CorruptedIr
+		}
+	}
+
+	if tagValueEmpty == irBuf[pos] {
+		return map[string]any{}, pos + 1, nil
+	}
+
+	keyIds := deserializer.keyIds[:0]
+	for {
+		if len(irBuf) <= pos {
+			return nil, 0, IncompleteIr
+		}
+		if tag := irBuf[pos]; tag < tagSchemaTreeNodeIdByte || tag > tagSchemaTreeNodeIdInt {
+			break
+		}
+		id, n, err := readSchemaTreeNodeId(irBuf[pos:], tagSchemaTreeNodeIdByte)
+		if nil != err {
+			return nil, 0, err
+		}
+		if SchemaTreeRootId == id || id >= tree.Len() {
+			return nil, 0, CorruptedIr
+		}
+		keyIds = append(keyIds, id)
+		pos += n
+	}
+	deserializer.keyIds = keyIds
+	if 0 == len(keyIds) {
+		return nil, 0, CorruptedIr
+	}
+
+	event := map[string]any{}
+	objs := map[int]map[string]any{SchemaTreeRootId: event}
+	for _, id := range keyIds {
+		node := tree.nodes[id]
+		value, n, err := deserializer.readValue(irBuf[pos:], node.Type)
+		if nil != err {
+			return nil, 0, err
+		}
+		pos += n
+		parent := kvEventObj(tree, objs, node.ParentId)
+		parent[node.Key] = value
+		if m, ok := value.(map[string]any); ok {
+			objs[id] = m
+		}
+	}
+	return event, pos, nil
+}
+
+// readValue reads a tagged value of a key with nodeType from irBuf. Returns:
+//   - success: the value, the position read to in irBuf, nil
+//   - error: nil, 0, [IncompleteIr] or [CorruptedIr] error
+func (deserializer *kvDeserializer[T]) readValue(
+	irBuf []byte,
+	nodeType SchemaTreeNodeType,
+) (any, int, error) {
+	if 0 >= len(irBuf) {
+		return nil, 0, IncompleteIr
+	}
+	var t T
+	_, isFourByte := any(t).(FourByteEncoding)
+	clpStrTag := tagValueEightByteEncodingClpStr
+	if isFourByte {
+		clpStrTag = tagValueFourByteEncodingClpStr
+	}
+	tag := irBuf[0]
+
+	switch {
+	case SchemaTreeNodeInt == nodeType && tagValueInt8 <= tag && tag <= tagValueInt64:
+		size := 1 << (tag - tagValueInt8)
+		if len(irBuf) < 1+size {
+			return nil, 0, IncompleteIr
+		}
+		var i int64
+		switch size {
+		case 1:
+			i = int64(int8(irBuf[1]))
+		case 2:
+			i = int64(int16(binary.BigEndian.Uint16(irBuf[1:])))
+		case 4:
+			i = int64(int32(binary.BigEndian.Uint32(irBuf[1:])))
+		default:
+			i = int64(binary.BigEndian.Uint64(irBuf[1:]))
+		}
+		return i, 1 + size, nil
+	case SchemaTreeNodeFloat == nodeType && tagValueFloat == tag:
+		if len(irBuf) < 9 {
+			return nil, 0, IncompleteIr
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(irBuf[1:])), 9, nil
+	case SchemaTreeNodeBool == nodeType && (tagValueTrue == tag || tagValueFalse == tag):
+		return tagValueTrue == tag, 1, nil
+	case SchemaTreeNodeStr == nodeType && tagStrLenUByte <= tag && tag <= tagStrLenUInt:
+		str, n, err := readLengthPrefixed(irBuf[1:], tag, tagStrLenUByte)
+		if nil != err {
+			return nil, 0, err
+		}
+		return string(str), 1 + n, nil
+	case SchemaTreeNodeStr == nodeType && clpStrTag == tag:
+		str, n, err := deserializer.readClpStr(irBuf[1:])
+		if nil != err {
+			return nil, 0, err
+		}
+		return string(str), 1 + n, nil
+	case SchemaTreeNodeUnstructuredArray == nodeType && clpStrTag == tag:
+		array, n, err := deserializer.readClpStr(irBuf[1:])
+		if nil != err {
+			return nil, 0, err
+		}
+		value, err := unmarshalKVArray(array)
+		if nil != err {
+			return nil, 0, CorruptedIr
+		}
+		return value, 1 + n, nil
+	case SchemaTreeNodeObj == nodeType && tagValueNull == tag:
+		return nil, 1, nil
+	case SchemaTreeNodeObj == nodeType && tagValueEmpty == tag:
+		return map[string]any{}, 1, nil
+	default:
+		return nil, 0, CorruptedIr
+	}
+}
+
+// readClpStr reads a string encoded like a log message from irBuf, decoding it
+// into the deserializer's log message buffer. Returns:
+//   - success: the decoded string, the position read to in irBuf, nil
+//   - error: nil, 0, [IrError] error
+func (deserializer *kvDeserializer[T]) readClpStr(irBuf []byte) ([]byte, int, error) {
+	msg, pos, err := deserializer.readEncodedLogMessage(irBuf)
+	if nil != err {
+		return nil, 0, err
+	}
+	str, err := appendDecodedMessage(deserializer.logMessage[:0], msg)
+	if nil != err {
+		return nil, 0, CorruptedIr
+	}
+	deserializer.logMessage = str
+	return str, pos, nil
+}
+
+// marshalLogEvent encodes event as JSON into the deserializer's log message
+// buffer, returning a view of it as a log event with a 0 timestamp. Keys are
+// sorted and HTML characters are not escaped.
+func (deserializer *kvDeserializer[T]) marshalLogEvent(
+	event map[string]any,
+) (*ffi.LogEventView, error) {
+	buf := bytes.NewBuffer(deserializer.logMessage[:0])
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(event); nil != err {
+		return nil, err
+	}
+	// Encode terminates the JSON with a newline
+	logMessage := bytes.TrimSuffix(buf.Bytes(), []byte{'\n'})
+	deserializer.logMessage = logMessage
+	return &ffi.LogEventView{
+		LogMessageView: unsafe.String(unsafe.SliceData(logMessage), len(logMessage)),
+		Timestamp:      0,
+	}, nil
+}
+
+// kvEventObj returns the map for the Obj node id within the log event being
+// deserialized, creating it (and any missing ancestors) if the log event does
+// not contain it yet. objs maps node ids to the maps already created.
+func kvEventObj(tree *SchemaTree, objs map[int]map[string]any, id int) map[string]any {
+	if obj, ok := objs[id]; ok {
+		return obj
+	}
+	node := tree.nodes[id]
+	obj := map[string]any{}
+	kvEventObj(tree, objs, node.ParentId)[node.Key] = obj
+	objs[id] = obj
+	return obj
+}
+
+// unmarshalKVArray unmarshals a JSON array, using int64 for integers and
+// float64 for all other numbers.
+func unmarshalKVArray(array []byte) ([]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(array))
+	decoder.UseNumber()
+	var value []any
+	if err := decoder.Decode(&value); nil != err {
+		return nil, err
+	}
+	for i, element := range value {
+		value[i] = normalizeJsonNumbers(element)
+	}
+	return value, nil
+}
+
+// normalizeJsonNumbers replaces each json.Number within value with an int64 if
+// it is an integer, or otherwise a float64.
+func normalizeJsonNumbers(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); nil == err {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []any:
+		for i, element := range v {
+			v[i] = normalizeJsonNumbers(element)
+		}
+	case map[string]any:
+		for key, element := range v {
+			v[key] = normalizeJsonNumbers(element)
+		}
+	}
+	return value
+}
+
+// readSchemaTreeNodeId reads a tagged schema tree node id from irBuf. byteTag
+// is the tag for an int8 id, and is followed by the tags for an int16 and int32
+// id. Returns:
+//   - success: the id, the position read to in irBuf, nil
+//   - error: -1, 0, [IncompleteIr] or [CorruptedIr] error
+func readSchemaTreeNodeId(irBuf []byte, byteTag byte) (int, int, error) {
+	if 0 >= len(irBuf) {
+		return -1, 0, IncompleteIr
+	}
+	var id int
+	var size int
+	switch irBuf[0] - byteTag {
+	case 0:
+		size = 1
+	case 1:
+		size = 2
+	case 2:
+		size = 4
+	default:
+		return -1, 0, CorruptedIr
+	}
+	if len(irBuf) < 1+size {
+		return -1, 0, IncompleteIr
+	}
+	switch size {
+	case 1:
+		id = int(int8(irBuf[1]))
+	case 2:
+		id = int(int16(binary.BigEndian.Uint16(irBuf[1:])))
+	default:
+		id = int(int32(binary.BigEndian.Uint32(irBuf[1:])))
+	}
+	if 0 > id {
+		return -1, 0, CorruptedIr
+	}
+	return id, 1 + size, nil
+}
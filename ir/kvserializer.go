@@ -0,0 +1,329 @@
+package ir
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// newKVSerializer implements [EightByteKVSerializerWithMetadata] and
+// [FourByteKVSerializerWithMetadata] for an encoding of T. userMetadata is only
+// stored if it is not nil.
+func newKVSerializer[T EightByteEncoding | FourByteEncoding](
+	userMetadata map[string]any,
+) (KVSerializer, BufView, error) {
+	var t T
+	_, isFourByte := any(t).(FourByteEncoding)
+	metadata := map[string]any{
+		metadataVersionKey:                   kvMetadataVersionValue,
+		metadataVariablesSchemaIdKey:         metadataVariablesSchemaIdValue,
+		metadataVariableEncodingMethodsIdKey: metadataVariableEncodingMethodsIdValue,
+	}
+	if nil != userMetadata {
+		metadata[metadataUserDefinedMetadataKey] = userMetadata
+	}
+	preamble, err := appendPreambleMetadata(nil, isFourByte, metadata)
+	if nil != err {
+		return nil, nil, err
+	}
+	return &kvSerializer[T]{schemaTree: newSchemaTree(), irBuf: preamble}, preamble, nil
+}
+
+// kvSerializer is a [KVSerializer] for an encoding of T. Each log event is
+// serialized as the schema tree nodes it added, followed by the id of each of
+// its keys, followed by the value of each key (in the same order). These three
+// groups are built up separately in nodeBuf, keyBuf, and valueBuf before being
+// joined in irBuf. Mirrors the c++ equivalent clp::ffi::ir_stream::Serializer.
+type kvSerializer[T EightByteEncoding | FourByteEncoding] struct {
+	encodedLogMessageBuf[T]
+	schemaTree *SchemaTree
+	nodeBuf    []byte
+	keyBuf     []byte
+	valueBuf   []byte
+	irBuf      []byte
+}
+
+// Close releases the serializer's buffers.
+func (serializer *kvSerializer[T]) Close() error {
+	serializer.encodedLogMessageBuf = encodedLogMessageBuf[T]{}
+	serializer.nodeBuf = nil
+	serializer.keyBuf = nil
+	serializer.valueBuf = nil
+	serializer.irBuf = nil
+	return nil
+}
+
+// SchemaTree returns the schema tree containing every key serialized so far.
+// The tree is owned by the serializer and must not be modified.
+func (serializer *kvSerializer[T]) SchemaTree() *SchemaTree {
+	return serializer.schemaTree
+}
+
+// SerializeKVEvent attempts to serialize the structured log event, event, into
+// a CLP key-value pair IR byte stream. Keys are serialized in sorted order. If
+// serialization fails, the schema tree is left unchanged. On error returns:
+//   - nil BufView
+//   - [CorruptedIr] error: a key or value is too large to serialize
+//   - unsupported value error: event contains a value of an unsupported type
+//   - [encoding/json] error: marshalling an array failed
+func (serializer *kvSerializer[T]) SerializeKVEvent(event map[string]any) (BufView, error) {
+	if 0 == len(event) {
+		serializer.irBuf = append(serializer.irBuf[:0], tagValueEmpty)
+		return serializer.irBuf, nil
+	}
+
+	serializer.nodeBuf = serializer.nodeBuf[:0]
+	serializer.keyBuf = serializer.keyBuf[:0]
+	serializer.valueBuf = serializer.valueBuf[:0]
+	numNodes := serializer.schemaTree.Len()
+	if err := serializer.serializeMap(SchemaTreeRootId, event); nil != err {
+		serializer.schemaTree.truncate(numNodes)
+		return nil, err
+	}
+
+	irBuf := append(serializer.irBuf[:0], serializer.nodeBuf...)
+	irBuf = append(irBuf, serializer.keyBuf...)
+	serializer.irBuf = append(irBuf, serializer.valueBuf...)
+	return serializer.irBuf, nil
+}
+
+// serializeMap serializes each key-value pair in m, nested under the Obj node
+// parentId, in sorted key order. Nested maps are serialized recursively, unless
+// they are empty.
+func (serializer *kvSerializer[T]) serializeMap(parentId int, m map[string]any) error {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := m[key]
+		if nested, ok := toKVMap(value); ok && 0 < len(nested) {
+			id, err := serializer.addNode(parentId, key, SchemaTreeNodeObj)
+			if nil != err {
+				return err
+			}
+			if err = serializer.serializeMap(id, nested); nil != err {
+				return err
+			}
+			continue
+		}
+		if err := serializer.serializeKeyValue(parentId, key, value); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// serializeKeyValue serializes the id of key, nested under the Obj node
+// parentId, into keyBuf and its value into valueBuf. value must not be a
+// non-empty map.
+func (serializer *kvSerializer[T]) serializeKeyValue(parentId int, key string, value any) error {
+	var t T
+	_, isFourByte := any(t).(FourByteEncoding)
+	var nodeType SchemaTreeNodeType
+	valueBuf := serializer.valueBuf
+	var ok bool
+
+	switch v := value.(type) {
+	case nil:
+		nodeType = SchemaTreeNodeObj
+		valueBuf = append(valueBuf, tagValueNull)
+	case json.Number:
+		if i, err := v.Int64(); nil == err {
+			nodeType = SchemaTreeNodeInt
+			valueBuf = appendKVInt(valueBuf, i)
+		} else if f, err := v.Float64(); nil == err {
+			nodeType = SchemaTreeNodeFloat
+			valueBuf = appendKVFloat(valueBuf, f)
+		} else {
+			return fmt.Errorf("unsupported value %q for key %q", v, key)
+		}
+	default:
+		rv := reflect.ValueOf(value)
+		switch rv.Kind() {
+		case reflect.Bool:
+			nodeType = SchemaTreeNodeBool
+			if rv.Bool() {
+				valueBuf = append(valueBuf, tagValueTrue)
+			} else {
+				valueBuf = append(valueBuf, tagValueFalse)
+			}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			nodeType = SchemaTreeNodeInt
+			valueBuf = appendKVInt(valueBuf, rv.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Uintptr:
+			if rv.Uint() > math.MaxInt64 {
+				return fmt.Errorf("unsupported value %v for key %q: exceeds int64", v, key)
+			}
+			nodeType = SchemaTreeNodeInt
+			valueBuf = appendKVInt(valueBuf, int64(rv.Uint()))
+		case reflect.Float32, reflect.Float64:
+			nodeType = SchemaTreeNodeFloat
+			valueBuf = appendKVFloat(valueBuf, rv.Float())
+		case reflect.String:
+			nodeType = SchemaTreeNodeStr
+			str := rv.String()
+			if false == strings.Contains(str, " ") {
+				valueBuf, ok = appendLengthPrefixed(valueBuf, tagStrLenUByte, str)
+				if false == ok {
+					return CorruptedIr
+				}
+				break
+			}
+			var err error
+			if valueBuf, err = serializer.appendClpStr(valueBuf, str, isFourByte); nil != err {
+				return err
+			}
+		case reflect.Slice, reflect.Array:
+			nodeType = SchemaTreeNodeUnstructuredArray
+			var arrayBuf bytes.Buffer
+			encoder := json.NewEncoder(&arrayBuf)
+			encoder.SetEscapeHTML(false)
+			if err := encoder.Encode(value); nil != err {
+				return err
+			}
+			// Encode terminates the JSON with a newline
+			array := strings.TrimSuffix(arrayBuf.String(), "\n")
+			var err error
+			if valueBuf, err = serializer.appendClpStr(valueBuf, array, isFourByte); nil != err {
+				return err
+			}
+		case reflect.Map:
+			if _, ok = toKVMap(value); false == ok {
+				return fmt.Errorf("unsupported value type %T for key %q", value, key)
+			}
+			nodeType = SchemaTreeNodeObj
+			valueBuf = append(valueBuf, tagValueEmpty)
+		default:
+			return fmt.Errorf("unsupported value type %T for key %q", value, key)
+		}
+	}
+	serializer.valueBuf = valueBuf
+
+	id, err := serializer.addNode(parentId, key, nodeType)
+	if nil != err {
+		return err
+	}
+	serializer.keyBuf, ok = appendSchemaTreeNodeId(serializer.keyBuf, tagSchemaTreeNodeIdByte, id)
+	if false == ok {
+		return CorruptedIr
+	}
+	return nil
+}
+
+// addNode returns the id of the schema tree node for key, nested under the Obj
+// node parentId, with nodeType. If the node is new, it is added to the schema
+// tree and serialized into nodeBuf.
+func (serializer *kvSerializer[T]) addNode(
+	parentId int,
+	key string,
+	nodeType SchemaTreeNodeType,
+) (int, error) {
+	id, isNew := serializer.schemaTree.insert(parentId, key, nodeType)
+	if false == isNew {
+		return id, nil
+	}
+	nodeBuf := append(serializer.nodeBuf, tagSchemaTreeNodeInt+byte(nodeType))
+	nodeBuf, ok := appendSchemaTreeNodeId(nodeBuf, tagSchemaTreeNodeParentIdByte, parentId)
+	if false == ok {
+		return -1, CorruptedIr
+	}
+	if nodeBuf, ok = appendLengthPrefixed(nodeBuf, tagStrLenUByte, key); false == ok {
+		return -1, CorruptedIr
+	}
+	serializer.nodeBuf = nodeBuf
+	return id, nil
+}
+
+// appendClpStr appends str to valueBuf encoded like a log message: as its
+// variables followed by its logtype. On error returns:
+//   - valueBuf unchanged
+//   - [CorruptedIr] error: a component of str is too large to serialize
+func (serializer *kvSerializer[T]) appendClpStr(
+	valueBuf []byte,
+	str string,
+	isFourByte bool,
+) ([]byte, error) {
+	if isFourByte {
+		valueBuf = append(valueBuf, tagValueFourByteEncodingClpStr)
+	} else {
+		valueBuf = append(valueBuf, tagValueEightByteEncodingClpStr)
+	}
+	valueBuf, err := appendEncodedLogMessage(valueBuf, serializer.encodeLogMessage(str))
+	if nil != err {
+		return valueBuf[:len(valueBuf)-1], err
+	}
+	return valueBuf, nil
+}
+
+// toKVMap returns value as a map[string]any if it is a map with string keys.
+// Returns:
+//   - success: the map, true
+//   - failure: nil, false
+func toKVMap(value any) (map[string]any, bool) {
+	if m, ok := value.(map[string]any); ok {
+		return m, true
+	}
+	rv := reflect.ValueOf(value)
+	if reflect.Map != rv.Kind() || reflect.String != rv.Type().Key().Kind() {
+		return nil, false
+	}
+	m := make(map[string]any, rv.Len())
+	for iter := rv.MapRange(); iter.Next(); {
+		m[iter.Key().String()] = iter.Value().Interface()
+	}
+	return m, true
+}
+
+// appendKVInt appends the tagged value i to valueBuf using the smallest
+// integer type possible, and returns the extended buffer.
+func appendKVInt(valueBuf []byte, i int64) []byte {
+	switch {
+	case math.MinInt8 <= i && i <= math.MaxInt8:
+		return append(valueBuf, tagValueInt8, byte(int8(i)))
+	case math.MinInt16 <= i && i <= math.MaxInt16:
+		valueBuf = append(valueBuf, tagValueInt16)
+		return binary.BigEndian.AppendUint16(valueBuf, uint16(i))
+	case math.MinInt32 <= i && i <= math.MaxInt32:
+		valueBuf = append(valueBuf, tagValueInt32)
+		return binary.BigEndian.AppendUint32(valueBuf, uint32(i))
+	default:
+		valueBuf = append(valueBuf, tagValueInt64)
+		return binary.BigEndian.AppendUint64(valueBuf, uint64(i))
+	}
+}
+
+// appendKVFloat appends the tagged value f to valueBuf as its IEEE 754 binary
+// representation, and returns the extended buffer.
+func appendKVFloat(valueBuf []byte, f float64) []byte {
+	valueBuf = append(valueBuf, tagValueFloat)
+	return binary.BigEndian.AppendUint64(valueBuf, math.Float64bits(f))
+}
+
+// appendSchemaTreeNodeId appends the schema tree node id, preceded by its tag,
+// to irBuf using the smallest integer type possible. byteTag is the tag for an
+// int8 id, and is followed by the tags for an int16 and int32 id. Returns:
+//   - success: the extended buffer, true
+//   - failure: undefined buffer, false if id is too large to serialize
+func appendSchemaTreeNodeId(irBuf []byte, byteTag byte, id int) ([]byte, bool) {
+	switch {
+	case id <= math.MaxInt8:
+		return append(irBuf, byteTag, byte(id)), true
+	case id <= math.MaxInt16:
+		irBuf = append(irBuf, byteTag+1)
+		return binary.BigEndian.AppendUint16(irBuf, uint16(id)), true
+	case id <= math.MaxInt32:
+		irBuf = append(irBuf, byteTag+2)
+		return binary.BigEndian.AppendUint32(irBuf, uint32(id)), true
+	default:
+		return irBuf, false
+	}
+}
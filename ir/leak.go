@@ -0,0 +1,193 @@
+package ir
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxLeakStackDepth is the maximum number of frames recorded in the
+// construction stack of an object tracked for leaks.
+const maxLeakStackDepth = 32
+
+// UnclosedObject describes an object holding native (C++) memory or other
+// resources that was created while leak tracking was enabled (see
+// [SetLeakTracking]) and has not been closed yet.
+//   - Kind: the kind of the object (e.g. "Deserializer" or "Reader")
+//   - Created: the time the object was created at
+//   - Stack: the stack trace of the object's construction
+//   - Collected: whether the object was garbage collected without being
+//     closed, leaking the memory backing the views it returned
+type UnclosedObject struct {
+	Kind      string
+	Created   time.Time
+	Stack     string
+	Collected bool
+}
+
+// leakTracker records the objects created while leak tracking is enabled
+// until they are closed, with id the last id given to an object.
+var leakTracker struct {
+	enabled atomic.Bool
+	mutex   sync.Mutex
+	id      uint64
+	live    map[uint64]UnclosedObject
+}
+
+// SetLeakTracking enables or disables leak tracking. While enabled, the
+// construction stack of each [Encoder], [Decoder], [Serializer], and
+// [Deserializer] holding native memory (and of each [Reader] holding a
+// decompressor, memory-mapped file, or prefetching goroutine) is recorded
+// until the object is closed, so that [UnclosedObjects] can report the
+// objects that were never closed. Objects created while leak tracking is
+// disabled are never tracked. Recording stacks is costly, so leak tracking is
+// meant for debugging.
+//
+// Independently of leak tracking, a [Reader] stops its prefetching goroutine
+// and releases its decompressor once it is garbage collected (when built with
+// Go 1.24 or later), as a safety net for a forgotten Close. Native memory and
+// memory-mapped files are never released this way, as views returned by their
+// owner may still reference them; a tracked object garbage collected without
+// being closed stays reported by [UnclosedObjects] with Collected set.
+func SetLeakTracking(enabled bool) {
+	leakTracker.enabled.Store(enabled)
+}
+
+// UnclosedObjects returns the tracked objects (see [SetLeakTracking]) that
+// have not been closed yet, in the order they were created.
+func UnclosedObjects() []UnclosedObject {
+	leakTracker.mutex.Lock()
+	defer leakTracker.mutex.Unlock()
+	ids := make([]uint64, 0, len(leakTracker.live))
+	for id := range leakTracker.live {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	objs := make([]UnclosedObject, 0, len(ids))
+	for _, id := range ids {
+		objs = append(objs, leakTracker.live[id])
+	}
+	return objs
+}
+
+// DumpUnclosedObjects writes each object returned by [UnclosedObjects] with
+// its construction stack to w, noting whether it was garbage collected.
+// Returns:
+//   - success: nil
+//   - error: error propagated from [io.Writer.Write]
+func DumpUnclosedObjects(w io.Writer) error {
+	for _, obj := range UnclosedObjects() {
+		collected := ""
+		if obj.Collected {
+			collected = " (garbage collected)"
+		}
+		_, err := fmt.Fprintf(
+			w,
+			"unclosed %v%v created at %v:\n%v\n",
+			obj.Kind,
+			collected,
+			obj.Created.Format(time.RFC3339Nano),
+			obj.Stack,
+		)
+		if nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// leakRef references an object tracked by trackLeaks. stop (if not nil)
+// cancels the cleanup run when the object is garbage collected and id is the
+// object's id in leakTracker (0 if it is not tracked).
+type leakRef struct {
+	stop func()
+	id   uint64
+}
+
+// trackLeaks registers release (if not nil) to be called once owner is
+// garbage collected and, if leak tracking is enabled, records owner's
+// construction stack (see [SetLeakTracking]). release must not reference owner,
+// or owner will never be garbage collected, and must not release memory that
+// views returned by owner may reference. untrack must be called when owner is
+// closed.
+func trackLeaks[T any](owner *T, kind string, release func()) leakRef {
+	var id uint64
+	var stack string
+	if leakTracker.enabled.Load() {
+		stack = callerStack()
+		leakTracker.mutex.Lock()
+		leakTracker.id++
+		id = leakTracker.id
+		if nil == leakTracker.live {
+			leakTracker.live = make(map[uint64]UnclosedObject)
+		}
+		leakTracker.live[id] = UnclosedObject{kind, time.Now(), stack, false}
+		leakTracker.mutex.Unlock()
+	}
+	if 0 == id && nil == release {
+		return leakRef{}
+	}
+	stop := addCleanup(owner, func() {
+		if 0 != id {
+			markCollected(id)
+		}
+		if nil != release {
+			release()
+		}
+	})
+	return leakRef{stop, id}
+}
+
+// untrack stops tracking the object referenced by ref, as it was closed.
+func (ref *leakRef) untrack() {
+	if nil != ref.stop {
+		ref.stop()
+		ref.stop = nil
+	}
+	if 0 != ref.id {
+		untrackLeak(ref.id)
+		ref.id = 0
+	}
+}
+
+// untrackLeak removes the object with id from leakTracker.
+func untrackLeak(id uint64) {
+	leakTracker.mutex.Lock()
+	defer leakTracker.mutex.Unlock()
+	delete(leakTracker.live, id)
+}
+
+// markCollected marks the object with id in leakTracker as garbage collected,
+// if it is still tracked.
+func markCollected(id uint64) {
+	leakTracker.mutex.Lock()
+	defer leakTracker.mutex.Unlock()
+	if obj, ok := leakTracker.live[id]; ok {
+		obj.Collected = true
+		leakTracker.live[id] = obj
+	}
+}
+
+// callerStack returns the stack trace of the caller of the function calling
+// trackLeaks, formatted like a goroutine's stack trace in a panic.
+func callerStack() string {
+	var pcs [maxLeakStackDepth]uintptr
+	// Skip runtime.Callers, callerStack, trackLeaks, and the function tracking
+	// its object.
+	n := runtime.Callers(4, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	var stack strings.Builder
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&stack, "%v\n\t%v:%v\n", frame.Function, frame.File, frame.Line)
+		if false == more {
+			break
+		}
+	}
+	return stack.String()
+}
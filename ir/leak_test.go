@@ -0,0 +1,128 @@
+package ir
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+)
+
+func TestLeakTracking(t *testing.T) {
+	SetLeakTracking(true)
+	defer SetLeakTracking(false)
+
+	irStream, _ := serializeValidateTestStream(
+		t,
+		[]ffi.LogEvent{{LogMessage: "started in 12 ms", Timestamp: 100}},
+	)
+	path := filepath.Join(t.TempDir(), "test.clp")
+	if err := os.WriteFile(path, irStream, 0o644); nil != err {
+		t.Fatalf("os.WriteFile failed: %v", err)
+	}
+	irreader, err := OpenFile(path)
+	if nil != err {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	encoder, err := EightByteEncoder()
+	if nil != err {
+		t.Fatalf("EightByteEncoder failed: %v", err)
+	}
+	decoder, err := FourByteDecoder()
+	if nil != err {
+		t.Fatalf("FourByteDecoder failed: %v", err)
+	}
+	serializer, _, err := EightByteSerializer("", "", "UTC")
+	if nil != err {
+		t.Fatalf("EightByteSerializer failed: %v", err)
+	}
+
+	// Only the Reader and native objects are tracked
+	expected := []string{"Reader"}
+	if _, ok := irreader.Deserializer.(*goDeserializer[FourByteEncoding]); false == ok {
+		expected = []string{"Deserializer", "Reader", "Encoder", "Decoder", "Serializer"}
+	}
+	objs := UnclosedObjects()
+	if len(expected) != len(objs) {
+		t.Fatalf("Wrong unclosed objects: %+v", objs)
+	}
+	for i, obj := range objs {
+		if expected[i] != obj.Kind || false == strings.Contains(obj.Stack, "TestLeakTracking") {
+			t.Fatalf("Wrong unclosed object: %+v", obj)
+		}
+	}
+	var dump bytes.Buffer
+	if err = DumpUnclosedObjects(&dump); nil != err {
+		t.Fatalf("DumpUnclosedObjects failed: %v", err)
+	}
+	if len(expected) != strings.Count(dump.String(), "unclosed ") {
+		t.Fatalf("Wrong dump: %v", dump.String())
+	}
+
+	irreader.Close()
+	encoder.Close()
+	decoder.Close()
+	serializer.Close()
+	if objs = UnclosedObjects(); 0 != len(objs) {
+		t.Fatalf("Closed objects still tracked: %+v", objs)
+	}
+
+	// Objects created while leak tracking is disabled are not tracked
+	SetLeakTracking(false)
+	irreader, err = OpenFile(path)
+	if nil != err {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	defer irreader.Close()
+	if objs = UnclosedObjects(); 0 != len(objs) {
+		t.Fatalf("Objects tracked while leak tracking is disabled: %+v", objs)
+	}
+}
+
+func TestLeakCleanup(t *testing.T) {
+	if false == cleanupSupported {
+		t.Skip("runtime.AddCleanup is not supported")
+	}
+	SetLeakTracking(true)
+	defer SetLeakTracking(false)
+	defer func() {
+		// Forget the collected Reader, which is never closed
+		leakTracker.mutex.Lock()
+		clear(leakTracker.live)
+		leakTracker.mutex.Unlock()
+	}()
+
+	// An unclosed Reader garbage collected stays reported as collected
+	func() {
+		irStream, _ := serializeValidateTestStream(t, nil)
+		if _, err := OpenReaderWithOptions(
+			bytes.NewReader(irStream),
+			ReaderOptions{Prefetch: 1},
+		); nil != err {
+			t.Fatalf("OpenReaderWithOptions failed: %v", err)
+		}
+	}()
+	var objs []UnclosedObject
+	for range 100 {
+		runtime.GC()
+		objs = UnclosedObjects()
+		if 0 != len(objs) && objs[len(objs)-1].Collected {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if 0 == len(objs) || "Reader" != objs[len(objs)-1].Kind || false == objs[len(objs)-1].Collected {
+		t.Fatalf("Garbage collected Reader not reported: %+v", objs)
+	}
+	var dump bytes.Buffer
+	if err := DumpUnclosedObjects(&dump); nil != err {
+		t.Fatalf("DumpUnclosedObjects failed: %v", err)
+	}
+	if false == strings.Contains(dump.String(), "unclosed Reader (garbage collected)") {
+		t.Fatalf("Wrong dump: %v", dump.String())
+	}
+}
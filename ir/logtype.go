@@ -0,0 +1,52 @@
+package ir
+
+import (
+	"strings"
+)
+
+// Placeholders used in a logtype to mark the position of each variable
+// extracted from a log message. Must match the c++ equivalent
+// clp::ir::VariablePlaceholder.
+const (
+	placeholderInteger    byte = 0x11
+	placeholderDictionary byte = 0x12
+	placeholderFloat      byte = 0x13
+	placeholderEscape     byte = '\\'
+)
+
+// isDelim returns whether c is a delimiter, meaning it can never be part of a
+// variable. Must match the c++ equivalent clp::ffi::is_delim.
+func isDelim(c byte) bool {
+	return !('+' == c ||
+		('-' <= c && c <= '.') ||
+		('0' <= c && c <= '9') ||
+		('A' <= c && c <= 'Z') ||
+		'\\' == c ||
+		'_' == c ||
+		('a' <= c && c <= 'z'))
+}
+
+// logtypeTemplate returns a human readable version of logtype, with escaping
+// removed and each placeholder replaced by "<int>", "<float>", or "<dict>".
+func logtypeTemplate(logtype string) string {
+	var sb strings.Builder
+	sb.Grow(len(logtype))
+	for i := 0; i < len(logtype); i++ {
+		switch logtype[i] {
+		case placeholderInteger:
+			sb.WriteString("<int>")
+		case placeholderFloat:
+			sb.WriteString("<float>")
+		case placeholderDictionary:
+			sb.WriteString("<dict>")
+		case placeholderEscape:
+			if i+1 < len(logtype) {
+				i++
+				sb.WriteByte(logtype[i])
+			}
+		default:
+			sb.WriteByte(logtype[i])
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,147 @@
+package ir
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+)
+
+// LogtypeStats summarizes all the log events in an IR stream sharing the same
+// logtype (the static text of a log message with each variable replaced by a
+// placeholder). Generally, each logtype corresponds to a single log statement
+// in the source code of the application producing the logs.
+//   - Logtype: the logtype in its encoded form
+//   - Count: number of log events with this logtype
+//   - Bytes: total size of these log events in the IR stream
+//   - FirstTimestamp/LastTimestamp: timestamps of the first and last log
+//     events seen with this logtype
+//   - ExampleVars: the variables of the first log event with this logtype
+type LogtypeStats struct {
+	Logtype        string
+	Count          int
+	Bytes          int
+	FirstTimestamp ffi.EpochTimeMs
+	LastTimestamp  ffi.EpochTimeMs
+	ExampleVars    []string
+}
+
+// Template returns a human readable version of the logtype, with each
+// placeholder replaced by "<int>", "<float>", or "<dict>" based on how the
+// variable was encoded.
+func (stats LogtypeStats) Template() string {
+	return logtypeTemplate(stats.Logtype)
+}
+
+// LogtypeStats reads the remainder of the IR stream and groups its log events
+// by logtype. Each log event's logtype is read from its encoded form in the IR
+// stream, so only the variables of the first log event of each logtype are
+// decoded (for ExampleVars). The returned stats are sorted by descending
+// Count, with ties broken by descending Bytes. On error, the Reader is left
+// before the log event that could not be read. On error returns:
+//   - nil []LogtypeStats
+//   - stream type error: the IR stream is a key-value pair IR stream
+//   - [IrError] error: CLP failed to successfully deserialize
+//   - [*EventTooLargeError] error: a log event does not fit in the maximum
+//     buffer size (see [ReaderOptions])
+//   - error propagated from [io.Reader.Read]
+func (reader *Reader) LogtypeStats() ([]LogtypeStats, error) {
+	statsByLogtype := make(map[string]*LogtypeStats)
+	for {
+		var err error
+		switch deserializer := reader.Deserializer.(type) {
+		case KVDeserializer:
+			return nil, errKVStream
+		case *goDeserializer[EightByteEncoding]:
+			err = addLogtypeStats(reader, deserializer, statsByLogtype)
+		case *goDeserializer[FourByteEncoding]:
+			err = addLogtypeStats(reader, deserializer, statsByLogtype)
+		default:
+			err = addNativeLogtypeStats(reader, deserializer, statsByLogtype)
+		}
+		if EndOfIr != err {
+			return nil, err
+		}
+		if err = reader.nextStream(); EndOfIr == err {
+			break
+		} else if nil != err {
+			return nil, err
+		}
+	}
+
+	allStats := make([]LogtypeStats, 0, len(statsByLogtype))
+	for _, stats := range statsByLogtype {
+		allStats = append(allStats, *stats)
+	}
+	sort.Slice(allStats, func(i, j int) bool {
+		if allStats[i].Count != allStats[j].Count {
+			return allStats[i].Count > allStats[j].Count
+		}
+		if allStats[i].Bytes != allStats[j].Bytes {
+			return allStats[i].Bytes > allStats[j].Bytes
+		}
+		return allStats[i].Logtype < allStats[j].Logtype
+	})
+	return allStats, nil
+}
+
+// addNativeLogtypeStats is addLogtypeStats for reader's native deserializer,
+// which does not expose the logtype. The log events are read by a Go
+// deserializer starting from the native deserializer's state, which is then
+// updated to the Go deserializer's so that the Reader remains usable.
+func addNativeLogtypeStats(
+	reader *Reader,
+	deserializer Deserializer,
+	statsByLogtype map[string]*LogtypeStats,
+) error {
+	prevTimestamp, isFourByte := fourByteTimestamp(deserializer)
+	if isFourByte {
+		irs := &goDeserializer[FourByteEncoding]{
+			prevTimestamp: prevTimestamp,
+			utcOffset:     deserializer.UtcOffset(),
+		}
+		err := addLogtypeStats(reader, irs, statsByLogtype)
+		setFourByteTimestamp(deserializer, irs.prevTimestamp)
+		setUtcOffset(deserializer, irs.utcOffset)
+		return err
+	}
+	irs := &goDeserializer[EightByteEncoding]{utcOffset: deserializer.UtcOffset()}
+	err := addLogtypeStats(reader, irs, statsByLogtype)
+	setUtcOffset(deserializer, irs.utcOffset)
+	return err
+}
+
+// addLogtypeStats adds the log events of reader's current IR stream to
+// statsByLogtype, reading them with deserializer. Returns:
+//   - success: [EndOfIr]
+//   - error: error propagated from [readEncodedLogEvent] or
+//     [decodeVariables]
+func addLogtypeStats[T EightByteEncoding | FourByteEncoding](
+	reader *Reader,
+	deserializer *goDeserializer[T],
+	statsByLogtype map[string]*LogtypeStats,
+) error {
+	for {
+		msg, timestamp, err := readEncodedLogEvent(reader, deserializer)
+		if nil != err {
+			return err
+		}
+		stats, ok := statsByLogtype[msg.Logtype]
+		if false == ok {
+			vars, err := decodeVariables(msg)
+			if nil != err {
+				return err
+			}
+			// msg is only valid until the next call, so copy the logtype
+			stats = &LogtypeStats{
+				Logtype:        strings.Clone(msg.Logtype),
+				FirstTimestamp: timestamp,
+				ExampleVars:    vars,
+			}
+			statsByLogtype[stats.Logtype] = stats
+		}
+		stats.Count++
+		stats.Bytes += int(reader.Offset() - reader.eventOffset)
+		stats.LastTimestamp = timestamp
+	}
+}
@@ -0,0 +1,248 @@
+package ir
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+)
+
+// MergeOptions configures how [MergeWithOptions] merges IR streams.
+//   - SourceTags: if not nil, must contain a tag for each source stream. Each
+//     log message from srcs[i] is prefixed with SourceTags[i] (e.g. "[pod-1] ")
+//     in the merged stream, so the source of every log event is preserved.
+type MergeOptions struct {
+	SourceTags []string
+}
+
+// Merge is [MergeWithOptions] with the default options.
+func Merge(dst io.Writer, srcs ...io.Reader) error {
+	return MergeWithOptions(dst, MergeOptions{}, srcs...)
+}
+
+// MergeWithOptions reads every IR stream in srcs and writes the log events of
+// all of them to dst as a single IR stream ordered by timestamp. Log events
+// with equal timestamps are ordered by the position of their source in srcs,
+// and the order of log events from the same source is always preserved (if a
+// source is not sorted by timestamp, the merged stream won't be either). The
+// sources are reconciled as follows:
+//   - Encoding: the sources' encoding if they all share the same one, otherwise
+//     eight byte encoding, which can represent all their variables
+//   - TimestampInfo: each field is kept if every source agrees on it, and is
+//     otherwise left empty, as it does not describe every log event
+//   - User-defined metadata: each key is kept if every source has it with the
+//     same value
+//   - Reference timestamp (four byte encoding): the first log event's
+//     timestamp
+//   - UTC offset: each log event keeps the UTC offset it has in its source
+//
+// On error returns:
+//   - invalid options error: opts.SourceTags does not match srcs
+//   - key-value pair IR stream error: a source is not an unstructured IR stream
+//   - error propagated from [NewReader], [Reader.Read], the [Serializer], or
+//     [io.Writer.Write]
+func MergeWithOptions(dst io.Writer, opts MergeOptions, srcs ...io.Reader) error {
+	if nil != opts.SourceTags && len(opts.SourceTags) != len(srcs) {
+		return fmt.Errorf(
+			"invalid options: %v source tags for %v sources",
+			len(opts.SourceTags),
+			len(srcs),
+		)
+	}
+
+	readers := make([]*Reader, 0, len(srcs))
+	defer func() {
+		for _, reader := range readers {
+			reader.Close()
+		}
+	}()
+	for _, src := range srcs {
+		reader, err := NewReader(src)
+		if nil != err {
+			return err
+		}
+		readers = append(readers, reader)
+		if _, ok := reader.Deserializer.(KVDeserializer); ok {
+			return errKVStream
+		}
+	}
+
+	encoding := EncodingEightByte
+	var tsInfo TimestampInfo
+	var userMetadata map[string]any
+	for i, reader := range readers {
+		isFourByte := EncodingFourByte == reader.Encoding()
+		if 0 == i {
+			if isFourByte {
+				encoding = EncodingFourByte
+			}
+			tsInfo = reader.TimestampInfo()
+			userMetadata = reader.Metadata()
+			continue
+		}
+		if false == isFourByte {
+			encoding = EncodingEightByte
+		}
+		tsInfo = commonTimestampInfo(tsInfo, reader.TimestampInfo())
+		userMetadata = commonMetadata(userMetadata, reader.Metadata())
+	}
+
+	sources := make(mergeHeap, 0, len(readers))
+	for i, reader := range readers {
+		event, err := reader.Read()
+		if EndOfIr == err {
+			continue
+		}
+		if nil != err {
+			return err
+		}
+		sources = append(sources, mergeSource{event, i})
+	}
+	heap.Init(&sources)
+
+	bw := bufio.NewWriter(dst)
+	var serializer Serializer
+	var preamble BufView
+	var err error
+	var refTs ffi.EpochTimeMs
+	if 0 < len(sources) {
+		refTs = sources[0].event.Timestamp
+	}
+	if EncodingFourByte == encoding {
+		serializer, preamble, err = FourByteSerializerWithMetadata(
+			tsInfo.Pattern,
+			tsInfo.PatternSyntax,
+			tsInfo.TimeZoneId,
+			refTs,
+			userMetadata,
+		)
+	} else {
+		serializer, preamble, err = EightByteSerializerWithMetadata(
+			tsInfo.Pattern,
+			tsInfo.PatternSyntax,
+			tsInfo.TimeZoneId,
+			userMetadata,
+		)
+	}
+	if nil != err {
+		return err
+	}
+	defer serializer.Close()
+	if _, err = bw.Write(preamble); nil != err {
+		return err
+	}
+
+	var utcOffset time.Duration
+	for 0 < len(sources) {
+		source := &sources[0]
+		// The source's reader has not read past source.event, so its UTC offset
+		// is still that of source.event
+		if sourceUtcOffset := readers[source.idx].UtcOffset(); utcOffset != sourceUtcOffset {
+			offsetSerializer, ok := serializer.(UtcOffsetSerializer)
+			if false == ok {
+				return errNoUtcOffsetChanges
+			}
+			irView, err := offsetSerializer.SerializeUtcOffsetChange(sourceUtcOffset)
+			if nil != err {
+				return err
+			}
+			if _, err = bw.Write(irView); nil != err {
+				return err
+			}
+			utcOffset = sourceUtcOffset
+		}
+		event := ffi.LogEvent{
+			LogMessage: source.event.LogMessageView,
+			Timestamp:  source.event.Timestamp,
+		}
+		if nil != opts.SourceTags {
+			event.LogMessage = opts.SourceTags[source.idx] + event.LogMessage
+		}
+		irView, err := serializer.SerializeLogEvent(event)
+		if nil != err {
+			return err
+		}
+		if _, err = bw.Write(irView); nil != err {
+			return err
+		}
+
+		// The serialized event no longer needs the view, so the source can read
+		// its next event
+		source.event, err = readers[source.idx].Read()
+		if EndOfIr == err {
+			heap.Pop(&sources)
+			continue
+		}
+		if nil != err {
+			return err
+		}
+		heap.Fix(&sources, 0)
+	}
+	if err = bw.WriteByte(tagEof); nil != err {
+		return err
+	}
+	return bw.Flush()
+}
+
+// commonTimestampInfo returns the fields shared by a and b, leaving the fields
+// that differ empty.
+func commonTimestampInfo(a TimestampInfo, b TimestampInfo) TimestampInfo {
+	if a.Pattern != b.Pattern {
+		a.Pattern = ""
+	}
+	if a.PatternSyntax != b.PatternSyntax {
+		a.PatternSyntax = ""
+	}
+	if a.TimeZoneId != b.TimeZoneId {
+		a.TimeZoneId = ""
+	}
+	return a
+}
+
+// commonMetadata returns the keys of a with the same value in b, or nil if
+// there are none.
+func commonMetadata(a map[string]any, b map[string]any) map[string]any {
+	var common map[string]any
+	for key, value := range a {
+		if bValue, ok := b[key]; ok && reflect.DeepEqual(value, bValue) {
+			if nil == common {
+				common = map[string]any{}
+			}
+			common[key] = value
+		}
+	}
+	return common
+}
+
+// mergeSource is the next log event of the source stream at idx.
+type mergeSource struct {
+	event *ffi.LogEventView
+	idx   int
+}
+
+// mergeHeap implements [heap.Interface], ordering sources by the timestamp of
+// their next log event and then by their index.
+type mergeHeap []mergeSource
+
+func (h mergeHeap) Len() int      { return len(h) }
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h mergeHeap) Less(i, j int) bool {
+	if h[i].event.Timestamp != h[j].event.Timestamp {
+		return h[i].event.Timestamp < h[j].event.Timestamp
+	}
+	return h[i].idx < h[j].idx
+}
+
+func (h *mergeHeap) Push(x any) { *h = append(*h, x.(mergeSource)) }
+
+func (h *mergeHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,88 @@
+package ir
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+)
+
+func TestMerge(t *testing.T) {
+	sourceEvents := [][]ffi.LogEvent{
+		{
+			{LogMessage: "a0 took 12.5 ms", Timestamp: 1000},
+			{LogMessage: "a1 id=2147483648", Timestamp: 3000},
+			{LogMessage: "a2", Timestamp: 3000},
+		},
+		{},
+		{
+			{LogMessage: "c0", Timestamp: 999},
+			{LogMessage: "c1", Timestamp: 3000},
+			{LogMessage: "c2 3355.4432", Timestamp: 5000},
+		},
+	}
+	expectedIdxs := [][2]int{{2, 0}, {0, 0}, {0, 1}, {0, 2}, {2, 1}, {2, 2}}
+	tsInfos := []TimestampInfo{
+		{"%Y", "java", "UTC"},
+		{"%Y", "", "UTC"},
+		{"%Y", "java", "America/Toronto"},
+	}
+	expectedTsInfo := TimestampInfo{"%Y", "", ""}
+	tags := []string{"[a] ", "[b] ", "[c] "}
+
+	for _, encodings := range [][]Encoding{
+		{EncodingFourByte, EncodingFourByte, EncodingFourByte},
+		{EncodingFourByte, EncodingEightByte, EncodingFourByte},
+	} {
+		srcs := make([]io.Reader, len(sourceEvents))
+		for i, events := range sourceEvents {
+			irStream := serializeEncodedTestStream(t, encodings[i], tsInfos[i], events)
+			srcs[i] = bytes.NewReader(irStream)
+		}
+		var merged bytes.Buffer
+		if err := MergeWithOptions(&merged, MergeOptions{SourceTags: tags}, srcs...); nil != err {
+			t.Fatalf("MergeWithOptions failed: %v", err)
+		}
+
+		irreader, err := NewReader(&merged)
+		if nil != err {
+			t.Fatalf("NewReader failed: %v", err)
+		}
+		if expectedTsInfo != irreader.TimestampInfo() {
+			t.Fatalf(
+				"Merge wrong TimestampInfo: %v != %v",
+				irreader.TimestampInfo(),
+				expectedTsInfo,
+			)
+		}
+		if encodings[1] != irreader.Encoding() {
+			t.Fatalf("Merge wrong encoding for sources %v", encodings)
+		}
+		for _, idx := range expectedIdxs {
+			event := sourceEvents[idx[0]][idx[1]]
+			event.LogMessage = tags[idx[0]] + event.LogMessage
+			assertIrLogEvent(t, &merged, irreader, event)
+		}
+		assertEndOfIr(t, &merged, irreader)
+		irreader.Close()
+	}
+}
+
+func TestMergeNoEvents(t *testing.T) {
+	var merged bytes.Buffer
+	if err := Merge(&merged); nil != err {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	irreader, err := NewReader(&merged)
+	if nil != err {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer irreader.Close()
+	assertEndOfIr(t, &merged, irreader)
+
+	err = MergeWithOptions(&merged, MergeOptions{SourceTags: []string{"a"}})
+	if nil == err {
+		t.Fatalf("MergeWithOptions succeeded with too many source tags")
+	}
+}
@@ -0,0 +1,162 @@
+package ir
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+	"github.com/y-scope/clp-ffi-go/search"
+)
+
+var testUserMetadata = map[string]any{
+	"hostname": "host-1",
+	"pid":      1234,
+	"build":    map[string]any{"version": "1.2.3", "dirty": false, "ratio": 0.5},
+	"pods":     []string{"pod-1", "pod-2"},
+}
+
+var expectedTestUserMetadata = map[string]any{
+	"hostname": "host-1",
+	"pid":      int64(1234),
+	"build":    map[string]any{"version": "1.2.3", "dirty": false, "ratio": 0.5},
+	"pods":     []any{"pod-1", "pod-2"},
+}
+
+func TestUserMetadata(t *testing.T) {
+	event := ffi.LogEvent{LogMessage: "log with metadata", Timestamp: 1000}
+	var streams [][]byte
+
+	serializer, preamble, err := EightByteSerializerWithMetadata("", "", "UTC", testUserMetadata)
+	if nil != err {
+		t.Fatalf("EightByteSerializerWithMetadata failed: %v", err)
+	}
+	streams = append(streams, serializeTestStream(t, serializer, preamble, []ffi.LogEvent{event}))
+	serializer.Close()
+
+	serializer, preamble, err = FourByteSerializerWithMetadata("", "", "UTC", 0, testUserMetadata)
+	if nil != err {
+		t.Fatalf("FourByteSerializerWithMetadata failed: %v", err)
+	}
+	streams = append(streams, serializeTestStream(t, serializer, preamble, []ffi.LogEvent{event}))
+	serializer.Close()
+
+	writer, err := NewWriterSizeWithMetadata[FourByteEncoding](1024, "UTC", testUserMetadata)
+	if nil != err {
+		t.Fatalf("NewWriterSizeWithMetadata failed: %v", err)
+	}
+	if _, err = writer.Write(event); nil != err {
+		t.Fatalf("Writer.Write failed: %v", err)
+	}
+	writer.Close()
+	streams = append(streams, append([]byte{}, writer.Bytes()...))
+
+	for _, irStream := range streams {
+		irreader, err := NewReader(bytes.NewReader(irStream))
+		if nil != err {
+			t.Fatalf("NewReader failed: %v", err)
+		}
+		if false == reflect.DeepEqual(expectedTestUserMetadata, irreader.Metadata()) {
+			t.Fatalf("Wrong metadata: %v != %v", irreader.Metadata(), expectedTestUserMetadata)
+		}
+		assertIrLogEvent(t, bytes.NewReader(irStream), irreader, event)
+		irreader.Close()
+
+		for _, derived := range deriveTestStreams(t, irStream) {
+			irreader, err = NewReader(bytes.NewReader(derived))
+			if nil != err {
+				t.Fatalf("NewReader failed: %v", err)
+			}
+			if false == reflect.DeepEqual(expectedTestUserMetadata, irreader.Metadata()) {
+				t.Fatalf("Metadata not preserved: %v", irreader.Metadata())
+			}
+			irreader.Close()
+		}
+	}
+}
+
+func TestUserMetadataNil(t *testing.T) {
+	_, expectedPreamble, _ := FourByteSerializer("", "", "UTC", 1)
+	serializer, preamble, err := FourByteSerializerWithMetadata("", "", "UTC", 1, nil)
+	if nil != err {
+		t.Fatalf("FourByteSerializerWithMetadata failed: %v", err)
+	}
+	defer serializer.Close()
+	if false == bytes.Equal(expectedPreamble, preamble) {
+		t.Fatalf("Preamble with nil metadata differs:\n%x\n%x", preamble, expectedPreamble)
+	}
+	irreader, err := NewReader(bytes.NewReader(preamble))
+	if nil != err {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer irreader.Close()
+	if nil != irreader.Metadata() {
+		t.Fatalf("Metadata is not nil: %v", irreader.Metadata())
+	}
+}
+
+func TestUserMetadataMerge(t *testing.T) {
+	metadatas := []map[string]any{
+		{"service": "api", "pod": "pod-1"},
+		{"service": "api", "pod": "pod-2"},
+	}
+	srcs := make([]io.Reader, len(metadatas))
+	for i, metadata := range metadatas {
+		serializer, preamble, err := EightByteSerializerWithMetadata("", "", "", metadata)
+		if nil != err {
+			t.Fatalf("EightByteSerializerWithMetadata failed: %v", err)
+		}
+		srcs[i] = bytes.NewReader(serializeTestStream(t, serializer, preamble, nil))
+		serializer.Close()
+	}
+	var merged bytes.Buffer
+	if err := Merge(&merged, srcs...); nil != err {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	irreader, err := NewReader(&merged)
+	if nil != err {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer irreader.Close()
+	expected := map[string]any{"service": "api"}
+	if false == reflect.DeepEqual(expected, irreader.Metadata()) {
+		t.Fatalf("Merge wrong metadata: %v != %v", irreader.Metadata(), expected)
+	}
+}
+
+func TestKVUserMetadata(t *testing.T) {
+	serializer, preamble, err := FourByteKVSerializerWithMetadata(testUserMetadata)
+	if nil != err {
+		t.Fatalf("FourByteKVSerializerWithMetadata failed: %v", err)
+	}
+	defer serializer.Close()
+	irreader, err := NewReader(bytes.NewReader(append(preamble, tagEof)))
+	if nil != err {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer irreader.Close()
+	if false == reflect.DeepEqual(expectedTestUserMetadata, irreader.Metadata()) {
+		t.Fatalf("Wrong metadata: %v != %v", irreader.Metadata(), expectedTestUserMetadata)
+	}
+}
+
+// deriveTestStreams returns the IR streams created from irStream by
+// [Transcode] (into both encodings) and [Slice].
+func deriveTestStreams(t *testing.T, irStream []byte) [][]byte {
+	var derived [][]byte
+	for _, encoding := range []Encoding{EncodingEightByte, EncodingFourByte} {
+		var transcoded bytes.Buffer
+		if err := Transcode(bytes.NewReader(irStream), &transcoded, encoding); nil != err {
+			t.Fatalf("Transcode failed: %v", err)
+		}
+		derived = append(derived, transcoded.Bytes())
+	}
+	var sliced bytes.Buffer
+	interval := search.TimestampInterval{Lower: 0, Upper: math.MaxInt64}
+	if err := Slice(bytes.NewReader(irStream), &sliced, interval); nil != err {
+		t.Fatalf("Slice failed: %v", err)
+	}
+	return append(derived, sliced.Bytes())
+}
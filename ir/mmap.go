@@ -0,0 +1,63 @@
+package ir
+
+import (
+	"bytes"
+	"errors"
+	"os"
+)
+
+var errFileTooLarge = errors.New("IR file is too large to map into memory")
+
+// OpenFile is [OpenFileWithOptions] with the default [ReaderOptions].
+func OpenFile(path string) (*Reader, error) {
+	return OpenFileWithOptions(path, ReaderOptions{})
+}
+
+// OpenFileWithOptions creates a [Reader] of the uncompressed IR file at path.
+// Rather than copying the file into the Reader's buffer, the file is
+// memory-mapped and log events are deserialized directly from the mapping.
+// The Reader otherwise behaves the same as one created by
+// [NewReaderWithOptions], except that opts' BufferSize, MaxBufferSize,
+// BufferPool, and Prefetch are unused. The file must not be truncated while
+// the Reader is open. On platforms without mmap the file is read into memory
+// instead. Compressed IR files must be read with [OpenReader].
+// Returns:
+//   - success: valid [*Reader], nil
+//   - error: nil [*Reader], errFileTooLarge (the file does not fit in the
+//     address space), [*UnsupportedVersionError] (under
+//     [VersionPolicyReject]), or error propagated from [os.Open], mapping the
+//     file, or [DeserializePreamble]
+func OpenFileWithOptions(path string, opts ReaderOptions) (*Reader, error) {
+	file, err := os.Open(path)
+	if nil != err {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if nil != err {
+		return nil, err
+	}
+	if int64(int(info.Size())) != info.Size() {
+		return nil, errFileTooLarge
+	}
+	buf, unmap, err := mapFile(file, int(info.Size()))
+	if nil != err {
+		return nil, err
+	}
+
+	opts.BufferPool = nil
+	irr := &Reader{
+		ioReader:            bytes.NewReader(nil),
+		buf:                 buf,
+		end:                 len(buf),
+		opts:                opts,
+		deserializePreamble: DeserializePreamble,
+		eventOffset:         -1,
+		unmap:               unmap,
+	}
+	if err = irr.readPreamble(); nil != err {
+		return nil, err
+	}
+	irr.track()
+	return irr, nil
+}
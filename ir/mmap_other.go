@@ -0,0 +1,21 @@
+//go:build !unix
+
+package ir
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads the first size bytes of file into memory, as memory-mapping
+// is not supported on this platform.
+// Returns:
+//   - success: the file's bytes, a function that does nothing, nil
+//   - error: nil, nil, error propagated from [io.ReadFull]
+func mapFile(file *os.File, size int) ([]byte, func() error, error) {
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(file, buf); nil != err {
+		return nil, nil, err
+	}
+	return buf, func() error { return nil }, nil
+}
@@ -0,0 +1,103 @@
+package ir
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+)
+
+func TestOpenFile(t *testing.T) {
+	events := aggregateTestEvents()[:8]
+	irStream, offsets := serializeValidateTestStream(t, events)
+	path := filepath.Join(t.TempDir(), "test.clp")
+	if err := os.WriteFile(path, irStream, 0o644); nil != err {
+		t.Fatalf("os.WriteFile failed: %v", err)
+	}
+
+	irreader, err := OpenFile(path)
+	if nil != err {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	if "api" != irreader.Metadata()["service"] {
+		t.Fatalf("Wrong metadata: %v", irreader.Metadata())
+	}
+	for i, event := range events {
+		assertIrLogEvent(t, nil, irreader, event)
+		if offsets[i] != irreader.EventOffset() {
+			t.Fatalf("Wrong event offset: %v != %v", irreader.EventOffset(), offsets[i])
+		}
+	}
+	assertEndOfIr(t, nil, irreader)
+	if err = irreader.Close(); nil != err {
+		t.Fatalf("Reader.Close failed: %v", err)
+	}
+
+	// Searching reads the same log events as a Reader of an io.Reader
+	irreader, err = OpenFile(path)
+	if nil != err {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	defer irreader.Close()
+	expectedReader, err := NewReader(bytes.NewReader(irStream))
+	if nil != err {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer expectedReader.Close()
+	for {
+		expected, expectedErr := expectedReader.ReadToContains("ms")
+		event, err := irreader.ReadToContains("ms")
+		if expectedErr != err {
+			t.Fatalf("ReadToContains returned %v != %v", err, expectedErr)
+		}
+		if nil != err {
+			break
+		}
+		if expected.Timestamp != event.Timestamp ||
+			expected.LogMessageView != event.LogMessageView {
+			t.Fatalf("ReadToContains returned %v != %v", event, expected)
+		}
+	}
+}
+
+func TestOpenFileInvalid(t *testing.T) {
+	irStream, offsets := serializeValidateTestStream(
+		t,
+		[]ffi.LogEvent{{LogMessage: "started in 12 ms", Timestamp: 100}},
+	)
+	dir := t.TempDir()
+	if _, err := OpenFile(filepath.Join(dir, "missing.clp")); nil == err {
+		t.Fatalf("OpenFile of a missing file succeeded")
+	}
+	for i, invalid := range [][]byte{nil, irStream[:offsets[0]-1]} {
+		path := filepath.Join(dir, "invalid.clp")
+		if err := os.WriteFile(path, invalid, 0o644); nil != err {
+			t.Fatalf("os.WriteFile failed: %v", err)
+		}
+		if _, err := OpenFile(path); nil == err {
+			t.Fatalf("OpenFile of invalid file %v succeeded", i)
+		}
+	}
+
+	// A truncated log event is reported as by a Reader of an io.Reader
+	path := filepath.Join(dir, "truncated.clp")
+	if err := os.WriteFile(path, irStream[:len(irStream)-3], 0o644); nil != err {
+		t.Fatalf("os.WriteFile failed: %v", err)
+	}
+	irreader, err := OpenFile(path)
+	if nil != err {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	defer irreader.Close()
+	expectedReader, err := NewReader(bytes.NewReader(irStream[:len(irStream)-3]))
+	if nil != err {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer expectedReader.Close()
+	_, expectedErr := expectedReader.Read()
+	if _, err = irreader.Read(); nil == err || expectedErr != err {
+		t.Fatalf("Read of a truncated log event returned %v != %v", err, expectedErr)
+	}
+}
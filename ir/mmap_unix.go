@@ -0,0 +1,24 @@
+//go:build unix
+
+package ir
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the first size bytes of file into memory as read-only.
+// Returns:
+//   - success: the mapped bytes, a function unmapping them, nil
+//   - error: nil, nil, error propagated from [syscall.Mmap]
+func mapFile(file *os.File, size int) ([]byte, func() error, error) {
+	if 0 == size {
+		// mmap fails for an empty range
+		return nil, func() error { return nil }, nil
+	}
+	buf, err := syscall.Mmap(int(file.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+	if nil != err {
+		return nil, nil, err
+	}
+	return buf, func() error { return syscall.Munmap(buf) }, nil
+}
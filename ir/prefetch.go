@@ -0,0 +1,102 @@
+package ir
+
+import (
+	"io"
+)
+
+// prefetchChunkSize is the size of each buffer a prefetchReader reads into. It
+// is twice zstd's maximum block size (128KB), so a read from a zstd decoder
+// into a chunk can return at least one whole decompressed block, which keeps
+// the channel send and receive each chunk costs negligible. It is also small
+// enough that a ring of a few chunks is no larger than a Reader's default 1MB
+// buffer.
+const prefetchChunkSize = 256 * 1024
+
+// prefetchChunk is a buffer filled by a prefetchReader's goroutine with n
+// bytes and the error returned by the read.
+type prefetchChunk struct {
+	buf []byte
+	n   int
+	err error
+}
+
+// prefetchReader is an io.Reader that reads ahead from another io.Reader on a
+// background goroutine, so that reading (e.g. decompressing) the IR stream
+// overlaps with deserializing it. The goroutine fills a ring of buffers:
+// filled buffers are sent to the reader through chunks and returned to the
+// goroutine through free once consumed. cur is the chunk being consumed, with
+// pos the offset of its unconsumed bytes.
+type prefetchReader struct {
+	chunks  chan prefetchChunk
+	free    chan []byte
+	done    chan struct{}
+	stopped chan struct{}
+	cur     prefetchChunk
+	pos     int
+}
+
+// newPrefetchReader starts a goroutine reading from r into numChunks buffers
+// ahead of the returned prefetchReader. Close must be called to stop the
+// goroutine.
+func newPrefetchReader(r io.Reader, numChunks int) *prefetchReader {
+	pr := &prefetchReader{
+		chunks:  make(chan prefetchChunk, numChunks),
+		free:    make(chan []byte, numChunks),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	for range numChunks {
+		pr.free <- make([]byte, prefetchChunkSize)
+	}
+	go pr.prefetch(r)
+	return pr
+}
+
+// prefetch reads from r into free buffers until r returns an error or the
+// prefetchReader is closed.
+func (pr *prefetchReader) prefetch(r io.Reader) {
+	defer close(pr.stopped)
+	for {
+		var buf []byte
+		select {
+		case buf = <-pr.free:
+		case <-pr.done:
+			return
+		}
+		n, err := r.Read(buf)
+		select {
+		case pr.chunks <- prefetchChunk{buf, n, err}:
+		case <-pr.done:
+			return
+		}
+		if nil != err {
+			return
+		}
+	}
+}
+
+// Read copies prefetched bytes into p, waiting for the next chunk if none are
+// prefetched. Once the prefetched bytes are consumed, the error the goroutine's
+// io.Reader returned is returned by every call.
+func (pr *prefetchReader) Read(p []byte) (int, error) {
+	for pr.pos == pr.cur.n {
+		if nil != pr.cur.err {
+			return 0, pr.cur.err
+		}
+		if nil != pr.cur.buf {
+			pr.free <- pr.cur.buf
+		}
+		pr.cur = <-pr.chunks
+		pr.pos = 0
+	}
+	n := copy(p, pr.cur.buf[pr.pos:pr.cur.n])
+	pr.pos += n
+	return n, nil
+}
+
+// Close stops the goroutine, waiting for its read in progress (if any) to
+// return, so that the underlying io.Reader can be safely closed afterwards.
+func (pr *prefetchReader) Close() {
+	close(pr.done)
+	<-pr.stopped
+}
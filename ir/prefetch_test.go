@@ -0,0 +1,163 @@
+package ir
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+)
+
+func TestReaderPrefetch(t *testing.T) {
+	events := seekableTestEvents()
+	var irStream bytes.Buffer
+	writer, err := NewWriterSize[FourByteEncoding](1024, "America/New_York")
+	if nil != err {
+		t.Fatalf("NewWriterSize failed: %v", err)
+	}
+	for _, e := range events {
+		if _, err = writer.Write(e.event); nil != err {
+			t.Fatalf("Writer.Write failed: %v", err)
+		}
+	}
+	if _, err = writer.CloseTo(&irStream); nil != err {
+		t.Fatalf("Writer.CloseTo failed: %v", err)
+	}
+	var zstdBuf bytes.Buffer
+	zstdWriter, err := zstd.NewWriter(&zstdBuf)
+	if nil != err {
+		t.Fatalf("zstd.NewWriter failed: %v", err)
+	}
+	zstdWriter.Write(irStream.Bytes())
+	zstdWriter.Close()
+
+	for _, file := range [][]byte{irStream.Bytes(), zstdBuf.Bytes()} {
+		expected, err := OpenReader(bytes.NewReader(file))
+		if nil != err {
+			t.Fatalf("OpenReader failed: %v", err)
+		}
+		irreader, err := OpenReaderWithOptions(
+			iotest.OneByteReader(bytes.NewReader(file)),
+			ReaderOptions{BufferSize: 64, Prefetch: 2},
+		)
+		if nil != err {
+			t.Fatalf("OpenReaderWithOptions failed: %v", err)
+		}
+		for _, e := range events {
+			assertIrLogEvent(t, nil, expected, e.event)
+			assertIrLogEvent(t, nil, irreader, e.event)
+			if expected.EventOffset() != irreader.EventOffset() {
+				t.Fatalf("Wrong offset: %v != %v", irreader.EventOffset(), expected.EventOffset())
+			}
+		}
+		assertEndOfIr(t, nil, irreader)
+		expected.Close()
+		if err = irreader.Close(); nil != err {
+			t.Fatalf("Reader.Close failed: %v", err)
+		}
+
+		// Closing before the stream is read stops the prefetching goroutine
+		irreader, err = OpenReaderWithOptions(
+			bytes.NewReader(file),
+			ReaderOptions{BufferSize: 64, Prefetch: 1},
+		)
+		if nil != err {
+			t.Fatalf("OpenReaderWithOptions failed: %v", err)
+		}
+		assertIrLogEvent(t, nil, irreader, events[0].event)
+		if err = irreader.Close(); nil != err {
+			t.Fatalf("Reader.Close failed: %v", err)
+		}
+	}
+
+	// Errors of the io.Reader are returned once the prefetched IR is consumed
+	errTest := errors.New("test error")
+	truncated := irStream.Bytes()[:irStream.Len()/2]
+	irreader, err := NewReaderWithOptions(
+		io.MultiReader(bytes.NewReader(truncated), iotest.ErrReader(errTest)),
+		ReaderOptions{Prefetch: 4},
+	)
+	if nil != err {
+		t.Fatalf("NewReaderWithOptions failed: %v", err)
+	}
+	defer irreader.Close()
+	for {
+		if _, err = irreader.Read(); nil != err {
+			break
+		}
+	}
+	if errTest != err {
+		t.Fatalf("Read returned: %v", err)
+	}
+	if _, err = NewReaderWithOptions(
+		iotest.ErrReader(errTest),
+		ReaderOptions{Prefetch: 1},
+	); errTest != err {
+		t.Fatalf("NewReaderWithOptions returned: %v", err)
+	}
+}
+
+// BenchmarkReader reads a zstd compressed IR stream with and without
+// prefetching, which decompresses it concurrently with deserializing it.
+func BenchmarkReader(b *testing.B) {
+	var irStream bytes.Buffer
+	writer, err := NewWriterSize[FourByteEncoding](1024*1024, "UTC")
+	if nil != err {
+		b.Fatalf("NewWriterSize failed: %v", err)
+	}
+	for i := range 200_000 {
+		event := ffi.LogEvent{
+			LogMessage: fmt.Sprintf(
+				"request %v from 10.0.%v.%v took %v ms with status %v",
+				i,
+				i%256,
+				i%199,
+				float64(i%1000)/10,
+				200+i%5,
+			),
+			Timestamp: ffi.EpochTimeMs(1_700_000_000_000 + i),
+		}
+		if _, err = writer.Write(event); nil != err {
+			b.Fatalf("Writer.Write failed: %v", err)
+		}
+	}
+	if _, err = writer.CloseTo(&irStream); nil != err {
+		b.Fatalf("Writer.CloseTo failed: %v", err)
+	}
+	var zstdBuf bytes.Buffer
+	zstdWriter, err := zstd.NewWriter(&zstdBuf)
+	if nil != err {
+		b.Fatalf("zstd.NewWriter failed: %v", err)
+	}
+	zstdWriter.Write(irStream.Bytes())
+	zstdWriter.Close()
+
+	for _, prefetch := range []int{0, 4} {
+		b.Run(fmt.Sprintf("Prefetch=%v", prefetch), func(b *testing.B) {
+			b.SetBytes(int64(irStream.Len()))
+			for range b.N {
+				irreader, err := OpenReaderWithOptions(
+					bytes.NewReader(zstdBuf.Bytes()),
+					ReaderOptions{Prefetch: prefetch},
+				)
+				if nil != err {
+					b.Fatalf("OpenReaderWithOptions failed: %v", err)
+				}
+				for {
+					if _, err = irreader.Read(); nil != err {
+						break
+					}
+				}
+				if EndOfIr != err {
+					b.Fatalf("Reader.Read failed: %v", err)
+				}
+				irreader.Close()
+			}
+		})
+	}
+}
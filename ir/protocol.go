@@ -0,0 +1,85 @@
+package ir
+
+// Constants of the CLP IR stream format. Must match the c++ equivalents in
+// clp/ffi/ir_stream/protocol_constants.hpp.
+const (
+	metadataEncodingJson byte = 0x1
+
+	metadataVersionKey                     = "VERSION"
+	metadataVersionValue                   = "0.0.2"
+	metadataVariablesSchemaIdKey           = "VARIABLES_SCHEMA_ID"
+	metadataVariablesSchemaIdValue         = "com.yscope.clp.VariablesSchemaV2"
+	metadataVariableEncodingMethodsIdKey   = "VARIABLE_ENCODING_METHODS_ID"
+	metadataVariableEncodingMethodsIdValue = "com.yscope.clp.VariableEncodingMethodsV1"
+	metadataUserDefinedMetadataKey         = "USER_DEFINED_METADATA"
+)
+
+var (
+	eightByteEncodingMagicNumber = [...]byte{0xFD, 0x2F, 0xB5, 0x30}
+	fourByteEncodingMagicNumber  = [...]byte{0xFD, 0x2F, 0xB5, 0x29}
+)
+
+// Tags preceding each packet (component) of a serialized log event.
+const (
+	tagEof byte = 0x00
+
+	tagMetadataLenUByte  byte = 0x11
+	tagMetadataLenUShort byte = 0x12
+
+	tagVarStrLenUByte  byte = 0x11
+	tagVarStrLenUShort byte = 0x12
+	tagVarStrLenInt    byte = 0x13
+
+	tagVarFourByteEncoding  byte = 0x18
+	tagVarEightByteEncoding byte = 0x19
+
+	tagLogtypeStrLenUByte  byte = 0x21
+	tagLogtypeStrLenUShort byte = 0x22
+	tagLogtypeStrLenInt    byte = 0x23
+
+	tagTimestampVal        byte = 0x30
+	tagTimestampDeltaByte  byte = 0x31
+	tagTimestampDeltaShort byte = 0x32
+	tagTimestampDeltaInt   byte = 0x33
+	tagTimestampDeltaLong  byte = 0x34
+
+	tagUtcOffsetChange byte = 0x3F
+)
+
+// Version and tags specific to key-value pair IR streams, which store
+// structured log events as key-value pairs whose keys are nodes of a schema
+// tree.
+const (
+	kvMetadataVersionValue = "0.1.0"
+
+	tagStrLenUByte  byte = 0x41
+	tagStrLenUShort byte = 0x42
+	tagStrLenUInt   byte = 0x43
+
+	tagValueInt8                    byte = 0x51
+	tagValueInt16                   byte = 0x52
+	tagValueInt32                   byte = 0x53
+	tagValueInt64                   byte = 0x54
+	tagValueFloat                   byte = 0x56
+	tagValueTrue                    byte = 0x57
+	tagValueFalse                   byte = 0x58
+	tagValueFourByteEncodingClpStr  byte = 0x59
+	tagValueEightByteEncodingClpStr byte = 0x5A
+	tagValueEmpty                   byte = 0x5E
+	tagValueNull                    byte = 0x5F
+
+	tagSchemaTreeNodeParentIdByte  byte = 0x60
+	tagSchemaTreeNodeParentIdShort byte = 0x61
+	tagSchemaTreeNodeParentIdInt   byte = 0x62
+
+	tagSchemaTreeNodeIdByte  byte = 0x65
+	tagSchemaTreeNodeIdShort byte = 0x66
+	tagSchemaTreeNodeIdInt   byte = 0x67
+
+	tagSchemaTreeNodeInt               byte = 0x71
+	tagSchemaTreeNodeFloat             byte = 0x72
+	tagSchemaTreeNodeBool              byte = 0x73
+	tagSchemaTreeNodeStr               byte = 0x74
+	tagSchemaTreeNodeUnstructuredArray byte = 0x75
+	tagSchemaTreeNodeObj               byte = 0x76
+)
@@ -1,14 +1,19 @@
 package ir
 
 import (
+	"encoding/binary"
+	"fmt"
 	"io"
 	"math"
 	"strings"
+	"sync"
 
 	"github.com/y-scope/clp-ffi-go/ffi"
 	"github.com/y-scope/clp-ffi-go/search"
 )
 
+const defaultReaderBufferSize = 1024 * 1024
+
 // Reader abstracts maintenance of a buffer containing a [Deserializer]. It
 // keeps track of the range [start, end) in the buffer containing valid,
 // unconsumed CLP IR. [NewReader] will construct a Reader with the appropriate
@@ -22,57 +27,368 @@ type Reader struct {
 	buf      []byte
 	start    int
 	end      int
+	opts     ReaderOptions
+	// deserializePreamble creates the Deserializer of each IR stream read.
+	deserializePreamble func([]byte) (Deserializer, int, error)
+	// streamIdx is the index of the IR stream currently read, when reading
+	// concatenated IR streams (see [ReaderOptions]).
+	streamIdx int
+	// bufOffset is the byte offset of buf[0] in the io.Reader.
+	bufOffset int64
+	// eventOffset is the byte offset of the most recently read log event (-1
+	// if none was read, see [Reader.EventOffset]).
+	eventOffset int64
+	// checkedOffset is the byte offset up to which the log events in buf were
+	// checked to fit in the maximum buffer size (see [Reader.checkedEnd]).
+	checkedOffset int64
+	// decompressor (if not nil) is closed along with the Reader (see
+	// [OpenReader]).
+	decompressor io.Closer
+	// unmap (if not nil) unmaps buf, which then holds the entire memory-mapped
+	// IR file (see [OpenFile]).
+	unmap func() error
+	// prefetcher (if not nil) is the ioReader reading ahead from the io.Reader
+	// given (see [ReaderOptions]).
+	prefetcher *prefetchReader
+	// ref references the Reader's leak tracking (see [SetLeakTracking]).
+	ref leakRef
+}
+
+// ReaderOptions configures how [NewReaderWithOptions] creates a [Reader].
+//   - BufferSize: the initial size of the Reader's buffer that the io.Reader is
+//     read into (1MB if 0). This buffer will grow if it is too small to
+//     contain the preamble or next log event.
+//   - MaxBufferSize: if not 0, the size the buffer will not grow past (the
+//     initial size is capped to it). A preamble or log event that does not fit
+//     (e.g. due to a corrupted length) fails the read with an
+//     [*EventTooLargeError] rather than growing the buffer without bound. Log
+//     events are checked using the lengths they declare before they are
+//     deserialized, so a corrupted length is never allocated either.
+//   - BufferPool: if not nil, a pool of *[]byte the buffer is taken from (if a
+//     pooled buffer is large enough) and returned to when the Reader is
+//     closed, so that many short-lived Readers can reuse their buffers. A
+//     Reader must not be used after Close when it uses a BufferPool.
+//   - Prefetch: if not 0, the number of 256KB chunks a background goroutine
+//     reads ahead from the io.Reader, so that reading it (e.g. decompressing
+//     it, see [OpenReader]) runs concurrently with deserializing log events.
+//     The views returned by the Reader are unaffected. The io.Reader is read
+//     past the IR the Reader has consumed, and Close waits for the
+//     goroutine's read in progress (if any) to return. Two chunks are enough
+//     to overlap reading with deserializing (one is filled while the other is
+//     consumed); more absorb variations in how fast the io.Reader returns
+//     (e.g. over a network). Every chunk is allocated up front, so e.g. 4
+//     chunks use 1MB.
+//   - VersionPolicy: how an IR stream with an unsupported version (see
+//     [ValidateVersion]) is handled.
+//   - Warn: if not nil, called with each warning (e.g. an
+//     [*UnsupportedVersionError] under [VersionPolicyWarn]). Warnings are
+//     discarded if it is nil.
+//   - Concatenated: read concatenated IR streams (each with its own preamble
+//     and EOF tag) as one logical stream. When an IR stream ends and another
+//     one follows, the Reader switches to a new Deserializer (with the new
+//     stream's TimestampInfo, metadata, and encoding) instead of returning
+//     [EndOfIr]. Each stream's version is handled by VersionPolicy.
+//   - OnStreamBoundary: if not nil, called with the index of the new IR stream
+//     (starting from 1, as the first stream has index 0) each time a
+//     concatenated Reader switches to the next stream, before any of its log
+//     events are read.
+type ReaderOptions struct {
+	BufferSize       int
+	MaxBufferSize    int
+	BufferPool       *sync.Pool
+	Prefetch         int
+	VersionPolicy    VersionPolicy
+	Warn             func(error)
+	Concatenated     bool
+	OnStreamBoundary func(streamIdx int)
+}
+
+// newBuf returns a buffer for a Reader, as described by [ReaderOptions].
+func (opts ReaderOptions) newBuf() []byte {
+	size := opts.BufferSize
+	if 0 >= size {
+		size = defaultReaderBufferSize
+	}
+	if 0 < opts.MaxBufferSize && size > opts.MaxBufferSize {
+		size = opts.MaxBufferSize
+	}
+	if nil != opts.BufferPool {
+		if buf, ok := opts.BufferPool.Get().(*[]byte); ok {
+			if cap(*buf) < size {
+				// Leave the buffer for a Reader it is large enough for
+				opts.BufferPool.Put(buf)
+			} else if 0 < opts.MaxBufferSize && cap(*buf) > opts.MaxBufferSize {
+				return (*buf)[:opts.MaxBufferSize]
+			} else {
+				return (*buf)[:cap(*buf)]
+			}
+		}
+	}
+	return make([]byte, size)
+}
+
+// EventTooLargeError is returned when a [Reader] cannot read a preamble or log
+// event because it does not fit in the Reader's maximum buffer size (see
+// [ReaderOptions]). Offset is the byte offset in the io.Reader the preamble or
+// log event starts at.
+type EventTooLargeError struct {
+	Offset        int64
+	MaxBufferSize int
+}
+
+func (err *EventTooLargeError) Error() string {
+	return fmt.Sprintf(
+		"IR at offset %v does not fit in the maximum buffer size of %v bytes",
+		err.Offset,
+		err.MaxBufferSize,
+	)
+}
+
+// warn reports err as a warning, as described by [ReaderOptions].
+func (opts ReaderOptions) warn(err error) {
+	if nil != opts.Warn {
+		opts.Warn(err)
+	}
+}
+
+// checkVersion applies opts.VersionPolicy to an IR stream of version. Returns:
+//   - success: nil
+//   - error: [*UnsupportedVersionError] (under [VersionPolicyReject])
+func (opts ReaderOptions) checkVersion(version string) error {
+	if VersionPolicyIgnore == opts.VersionPolicy {
+		return nil
+	}
+	if err := ValidateVersion(version); nil != err {
+		if VersionPolicyReject == opts.VersionPolicy {
+			return err
+		}
+		opts.warn(err)
+	}
+	return nil
 }
 
 // NewReaderSize creates a new [Reader] and uses [DeserializePreamble] to read a
 // CLP IR preamble from the [io.Reader], r. size denotes the initial size to use
 // for the Reader's buffer that the io.Reader is read into. This buffer will
-// grow if it is too small to contain the preamble or next log event. Returns:
+// grow if it is too small to contain the preamble or next log event. IR
+// streams with an unsupported version are read with a warning (see
+// [VersionPolicy]). Returns:
 //   - success: valid [*Reader], nil
-//   - error: nil [*Reader], error propagated from [DeserializePreamble] or
-//     [io.Reader.Read]
+//   - error: nil [*Reader], error propagated from [NewReaderWithOptions]
 func NewReaderSize(r io.Reader, size int) (*Reader, error) {
-	irr := &Reader{nil, r, make([]byte, size), 0, 0}
-	var err error
-	if _, err = irr.read(); nil != err {
+	return NewReaderWithOptions(r, ReaderOptions{BufferSize: size})
+}
+
+// NewReaderWithOptions creates a new [Reader] configured by opts and uses
+// [DeserializePreamble] to read a CLP IR preamble from the [io.Reader], r.
+// Returns:
+//   - success: valid [*Reader], nil
+//   - error: nil [*Reader], [*UnsupportedVersionError] (under
+//     [VersionPolicyReject]), or error propagated from [DeserializePreamble]
+//     or [io.Reader.Read]
+func NewReaderWithOptions(r io.Reader, opts ReaderOptions) (*Reader, error) {
+	return newReader(r, opts, DeserializePreamble)
+}
+
+// newReader implements [NewReaderWithOptions], using deserializePreamble to
+// create the Reader's Deserializer.
+func newReader(
+	r io.Reader,
+	opts ReaderOptions,
+	deserializePreamble func([]byte) (Deserializer, int, error),
+) (*Reader, error) {
+	irr := &Reader{
+		ioReader:            r,
+		buf:                 opts.newBuf(),
+		opts:                opts,
+		deserializePreamble: deserializePreamble,
+		eventOffset:         -1,
+	}
+	irr.startPrefetch()
+	if _, err := irr.read(); nil != err {
+		irr.releaseBuf()
+		irr.stopPrefetch()
+		return nil, err
+	}
+	if err := irr.readPreamble(); nil != err {
 		return nil, err
 	}
+	irr.track()
+	return irr, nil
+}
+
+// readPreamble creates the Reader's Deserializer from the preamble at the start
+// of its buffer. On error, the Reader is closed (or its buffer released if it
+// has no Deserializer yet). Returns:
+//   - success: nil
+//   - error: [*UnsupportedVersionError] (under [VersionPolicyReject]), or error
+//     propagated from the Reader's deserializePreamble or [io.Reader.Read]
+func (reader *Reader) readPreamble() error {
+	var err error
 	for {
-		irr.Deserializer, irr.start, err = DeserializePreamble(irr.buf[irr.start:irr.end])
+		reader.Deserializer, reader.start, err = reader.deserializePreamble(
+			reader.buf[reader.start:reader.end],
+		)
 		if IncompleteIr != err {
 			break
 		}
-		if _, err = irr.fillBuf(); nil != err {
+		if _, err = reader.fillBuf(); nil != err {
 			break
 		}
 	}
 	if nil != err {
-		return nil, err
+		reader.releaseBuf()
+		reader.stopPrefetch()
+		return err
 	}
-	return irr, nil
+
+	if err = reader.opts.checkVersion(reader.Version()); nil != err {
+		reader.Close()
+		return err
+	}
+	return nil
 }
 
 // Returns [NewReaderSize] with a default buffer size of 1MB.
 func NewReader(r io.Reader) (*Reader, error) {
-	return NewReaderSize(r, 1024*1024)
+	return NewReaderSize(r, defaultReaderBufferSize)
 }
 
 // Close will delete the underlying C++ allocated memory used by the
-// deserializer. Failure to call Close will result in a memory leak.
+// deserializer, close the Reader's decompressor (if it has one), and return
+// its buffer to its BufferPool (or unmap it if it is memory-mapped), and stop
+// its prefetching goroutine (if it has one). Failure to call Close will result
+// in a memory leak.
 func (reader *Reader) Close() error {
-	return reader.Deserializer.Close()
+	reader.ref.untrack()
+	reader.stopPrefetch()
+	unmapErr := reader.releaseBuf()
+	err := reader.Deserializer.Close()
+	if nil == err {
+		err = unmapErr
+	}
+	if nil != reader.decompressor {
+		if closeErr := reader.decompressor.Close(); nil == err {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// StreamIndex returns the index of the IR stream currently read, which is
+// always 0 unless the Reader reads concatenated IR streams (see
+// [ReaderOptions]).
+func (reader *Reader) StreamIndex() int {
+	return reader.streamIdx
+}
+
+// Offset returns the byte offset in the io.Reader of the first byte the Reader
+// has not yet consumed. Before a log event is read this is the offset the log
+// event (including any UTC offset changes preceding it) starts at, and after
+// the last log event of an IR stream it is the offset of the EOF tag.
+func (reader *Reader) Offset() int64 {
+	return reader.bufOffset + int64(reader.start)
+}
+
+// EventOffset returns the byte offset in the io.Reader that the most recently
+// read (or matched, see [Reader.ReadToWildcardMatchWithTimeInterval] and
+// [Reader.Count]) log event starts at, including any UTC offset changes
+// preceding it. The offset is absolute in the io.Reader, so with concatenated
+// IR streams it counts the bytes of every previous stream. Returns:
+//   - success: the offset of the log event
+//   - no log event read: -1
+func (reader *Reader) EventOffset() int64 {
+	return reader.eventOffset
+}
+
+// nextStream is called when the Reader's current IR stream reaches its EOF
+// tag. If the Reader reads concatenated IR streams and another IR stream
+// follows the EOF tag, the Reader switches to it, replacing its Deserializer.
+// Otherwise, the Reader is left unchanged. Returns:
+//   - success: nil
+//   - no following IR stream: [EndOfIr]
+//   - error: [io.ErrUnexpectedEOF] (the following preamble is incomplete),
+//     [*UnsupportedVersionError] (under [VersionPolicyReject]), or error
+//     propagated from the Deserializer's preamble deserialization or
+//     [io.Reader.Read]
+func (reader *Reader) nextStream() error {
+	if false == reader.opts.Concatenated {
+		return EndOfIr
+	}
+	for reader.end-reader.start <= 1 {
+		_, err := reader.fillBuf()
+		if io.ErrUnexpectedEOF == err {
+			return EndOfIr
+		}
+		if nil != err {
+			return err
+		}
+	}
+	var deserializer Deserializer
+	var pos int
+	var err error
+	for {
+		deserializer, pos, err = reader.deserializePreamble(
+			reader.buf[reader.start+1 : reader.end],
+		)
+		if IncompleteIr != err {
+			break
+		}
+		if _, err = reader.fillBuf(); nil != err {
+			return err
+		}
+	}
+	if nil != err {
+		return err
+	}
+	if err = reader.opts.checkVersion(deserializer.Version()); nil != err {
+		deserializer.Close()
+		return err
+	}
+
+	reader.Deserializer.Close()
+	reader.Deserializer = deserializer
+	reader.start += 1 + pos
+	reader.streamIdx++
+	if nil != reader.opts.OnStreamBoundary {
+		reader.opts.OnStreamBoundary(reader.streamIdx)
+	}
+	return nil
 }
 
 // Read uses [Deserializer].DeserializeLogEvent to read from the CLP IR byte stream. The
 // underlying buffer will grow if it is too small to contain the next log event. On error returns:
 //   - nil [*ffi.LogEventView]
+//   - [io.ErrUnexpectedEOF] error: the IR stream ended before its EOF tag
 //   - error propagated from [Deserializer].DeserializeLogEvent or [io.Reader.Read]
 func (reader *Reader) Read() (*ffi.LogEventView, error) {
-	var event *ffi.LogEventView
+	event, _, err := reader.readLogEvent()
+	return event, err
+}
+
+// ReadKVEvent uses [KVDeserializer].DeserializeKVEvent to read the next
+// structured log event from a key-value pair IR stream. The underlying buffer
+// will grow if it is too small to contain the next log event. On error returns:
+//   - nil map
+//   - stream type error: the Reader is not reading a key-value pair IR stream
+//   - error propagated from [KVDeserializer].DeserializeKVEvent or
+//     [io.Reader.Read]
+func (reader *Reader) ReadKVEvent() (map[string]any, error) {
+	var event map[string]any
 	var pos int
 	var err error
 	for {
-		event, pos, err = reader.DeserializeLogEvent(reader.buf[reader.start:reader.end])
+		kvDeserializer, ok := reader.Deserializer.(KVDeserializer)
+		if false == ok {
+			return nil, errNotKVStream
+		}
+		event, pos, err = kvDeserializer.DeserializeKVEvent(reader.buf[reader.start:reader.end])
+		if EndOfIr == err {
+			if err = reader.nextStream(); nil == err {
+				continue
+			}
+			break
+		}
 		if IncompleteIr != err {
 			break
 		}
@@ -83,10 +399,44 @@ func (reader *Reader) Read() (*ffi.LogEventView, error) {
 	if nil != err {
 		return nil, err
 	}
+	reader.eventOffset = reader.Offset()
 	reader.start += pos
 	return event, nil
 }
 
+// readLogEvent implements [Reader.Read], additionally returning the number of
+// bytes the log event occupied in the IR stream.
+func (reader *Reader) readLogEvent() (*ffi.LogEventView, int, error) {
+	var event *ffi.LogEventView
+	var pos int
+	var err error
+	for {
+		var end int
+		if end, err = reader.checkedEnd(); nil != err {
+			break
+		}
+		event, pos, err = reader.DeserializeLogEvent(reader.buf[reader.start:end])
+		if EndOfIr == err {
+			if err = reader.nextStream(); nil == err {
+				continue
+			}
+			break
+		}
+		if IncompleteIr != err {
+			break
+		}
+		if _, err = reader.fillBuf(); nil != err {
+			break
+		}
+	}
+	if nil != err {
+		return nil, 0, err
+	}
+	reader.eventOffset = reader.Offset()
+	reader.start += pos
+	return event, pos, nil
+}
+
 // ReadToWildcardMatch wraps ReadToWildcardMatchWithTimeInterval, attempting to
 // read the next log event that matches any query in queries, within the entire
 // IR. It forwards the result of ReadToWildcardMatchWithTimeInterval.
@@ -111,14 +461,21 @@ func (reader *Reader) ReadToWildcardMatchWithTimeInterval(
 	queries []search.WildcardQuery,
 	timeInterval search.TimestampInterval,
 ) (*ffi.LogEventView, int, error) {
+	mergedQuery := search.MergeWildcardQueries(queries)
+	if reader.matchesByEvent() {
+		return reader.readToWildcardMatchByEvent(mergedQuery, timeInterval)
+	}
 	var event *ffi.LogEventView
 	var pos int
 	var matchingQuery int
 	var err error
-	mergedQuery := search.MergeWildcardQueries(queries)
 	for {
+		var end int
+		if end, err = reader.checkedEnd(); nil != err {
+			break
+		}
 		event, pos, matchingQuery, err = reader.DeserializeWildcardMatchWithTimeInterval(
-			reader.buf[reader.start:reader.end],
+			reader.buf[reader.start:end],
 			mergedQuery,
 			timeInterval,
 		)
@@ -132,10 +489,67 @@ func (reader *Reader) ReadToWildcardMatchWithTimeInterval(
 	if nil != err {
 		return nil, -1, err
 	}
+	reader.eventOffset = reader.matchOffset(reader.start + pos)
 	reader.start += pos
 	return event, matchingQuery, nil
 }
 
+// matchesByEvent returns whether wildcard matches are found by
+// readToWildcardMatchByEvent rather than the Deserializer: for concatenated IR
+// streams (see readToWildcardMatchByEvent) and key-value pair IR streams, whose
+// log events matchOffset cannot walk.
+func (reader *Reader) matchesByEvent() bool {
+	_, isKV := reader.Deserializer.(KVDeserializer)
+	return reader.opts.Concatenated || isKV
+}
+
+// matchOffset returns the byte offset of the log event matched by the
+// Deserializer that ends at end in buf. The Deserializer only reports where
+// the matched log event ends, so the log events from [Reader.start] are walked
+// using the lengths their packets declare until reaching it. Returns -1 if the
+// IR does not declare a log event ending at end.
+func (reader *Reader) matchOffset(end int) int64 {
+	pos := reader.start
+	for pos < end {
+		n, complete := declaredEventEnd(reader.buf[pos:end])
+		if false == complete {
+			break
+		}
+		if pos+n == end {
+			return reader.bufOffset + int64(pos)
+		}
+		pos += n
+	}
+	return -1
+}
+
+// readToWildcardMatchByEvent implements ReadToWildcardMatchWithTimeInterval by
+// reading one log event at a time with [Reader.Read]. The Deserializer's
+// wildcard matching can read past the end of an IR stream without consuming
+// it, so a Reader of concatenated IR streams could not switch to the next
+// stream. Returns the same values as ReadToWildcardMatchWithTimeInterval,
+// including a [QueryNotFound] error if a log event past timeInterval is found.
+func (reader *Reader) readToWildcardMatchByEvent(
+	mergedQuery search.MergedWildcardQuery,
+	timeInterval search.TimestampInterval,
+) (*ffi.LogEventView, int, error) {
+	for {
+		event, err := reader.Read()
+		if nil != err {
+			return nil, -1, err
+		}
+		if timeInterval.Upper <= event.Timestamp {
+			return nil, -1, QueryNotFound
+		}
+		if timeInterval.Lower > event.Timestamp {
+			continue
+		}
+		if idx, ok := mergedQuery.Match(event.LogMessageView); ok {
+			return event, idx, nil
+		}
+	}
+}
+
 // Read the CLP IR byte stream until f returns true for a [ffi.LogEventView].
 // The successful LogEvent is returned. Errors are propagated from [Reader.Read].
 func (reader *Reader) ReadToFunc(
@@ -190,34 +604,235 @@ func (reader *Reader) ReadToSuffix(suffix string) (*ffi.LogEventView, error) {
 	return reader.ReadToFunc(fn)
 }
 
+// releaseBuf returns the Reader's buffer to its BufferPool (if it has one) or
+// unmaps it (if it is memory-mapped). Returns:
+//   - success: nil
+//   - error: error propagated from unmapping the buffer
+func (reader *Reader) releaseBuf() error {
+	if nil != reader.unmap {
+		err := reader.unmap()
+		reader.unmap = nil
+		reader.buf = nil
+		return err
+	}
+	if nil != reader.opts.BufferPool && nil != reader.buf {
+		buf := reader.buf
+		reader.opts.BufferPool.Put(&buf)
+		reader.buf = nil
+	}
+	return nil
+}
+
+// track tracks the Reader for leaks if it holds a decompressor, memory-mapped
+// file, or prefetching goroutine, stopping the goroutine and releasing the
+// decompressor once it is garbage collected. Returned views never reference
+// either, unlike a memory-mapped file, which is left mapped. Any previous
+// tracking of the Reader is replaced.
+func (reader *Reader) track() {
+	reader.ref.untrack()
+	prefetcher, decompressor := reader.prefetcher, reader.decompressor
+	if nil == prefetcher && nil == decompressor && nil == reader.unmap {
+		return
+	}
+	var release func()
+	if nil != prefetcher || nil != decompressor {
+		release = func() {
+			// Stopping the prefetcher waits for its read in progress
+			go func() {
+				if nil != prefetcher {
+					prefetcher.Close()
+				}
+				if nil != decompressor {
+					decompressor.Close()
+				}
+			}()
+		}
+	}
+	reader.ref = trackLeaks(reader, "Reader", release)
+}
+
+// startPrefetch replaces the Reader's io.Reader with a prefetchReader reading
+// ahead from it, if prefetching is enabled (see [ReaderOptions]).
+func (reader *Reader) startPrefetch() {
+	if 0 < reader.opts.Prefetch {
+		reader.prefetcher = newPrefetchReader(reader.ioReader, reader.opts.Prefetch)
+		reader.ioReader = reader.prefetcher
+	}
+}
+
+// stopPrefetch stops the Reader's prefetchReader (if it has one).
+func (reader *Reader) stopPrefetch() {
+	if nil != reader.prefetcher {
+		reader.prefetcher.Close()
+		reader.prefetcher = nil
+	}
+}
+
 // fillBuf shifts the remaining valid IR in [Reader.buf] to the front and then
 // calls [io.Reader.Read] to fill the remainder with more IR. Before reading into
-// the buffer, it is doubled if more than half of it is unconsumed IR.
-// Forwards the return of [io.Reader.Read].
+// the buffer, it is doubled (up to the maximum buffer size) if more than half of
+// it is unconsumed IR. Returns:
+//   - success: forwards the return of [io.Reader.Read]
+//   - error: 0, [*EventTooLargeError] (the buffer is full of unconsumed IR and
+//     at its maximum size), 0, [io.ErrUnexpectedEOF] (the buffer is
+//     memory-mapped, so it already holds all the IR), or forwards the return
+//     of [io.Reader.Read]
 func (reader *Reader) fillBuf() (int, error) {
-	if (reader.end - reader.start) > len(reader.buf)/2 {
-		buf := make([]byte, len(reader.buf)*2)
+	if nil != reader.unmap {
+		return 0, io.ErrUnexpectedEOF
+	}
+	size := len(reader.buf)
+	if (reader.end - reader.start) > size/2 {
+		size *= 2
+		if maxSize := reader.opts.MaxBufferSize; 0 < maxSize && size > maxSize {
+			size = max(maxSize, len(reader.buf))
+		}
+	}
+	if reader.end-reader.start == size {
+		return 0, &EventTooLargeError{reader.Offset(), reader.opts.MaxBufferSize}
+	}
+	if size > len(reader.buf) {
+		buf := make([]byte, size)
 		copy(buf, reader.buf[reader.start:reader.end])
 		reader.buf = buf
 	} else {
 		copy(reader.buf, reader.buf[reader.start:reader.end])
 	}
+	reader.bufOffset += int64(reader.start)
 	reader.end -= reader.start
 	reader.start = 0
 	n, err := reader.read()
 	return n, err
 }
 
+// checkedEnd checks that the log events in [Reader.buf] that were not checked
+// yet fit in the maximum buffer size (see [ReaderOptions]), using only the
+// lengths their packets declare. Only the IR before the returned end may be
+// deserialized, as the native library allocates the storage for a packet from
+// its declared length before reading it. Otherwise, a corrupted length would
+// make it allocate up to 2GB for a log event the buffer can never hold.
+// Returns:
+//   - success: the end of the IR in buf before the first log event that does
+//     not fit (end if they all do), nil
+//   - error: 0, [*EventTooLargeError] (the next log event does not fit)
+func (reader *Reader) checkedEnd() (int, error) {
+	maxSize := reader.opts.MaxBufferSize
+	if 0 >= maxSize || nil != reader.unmap {
+		return reader.end, nil
+	}
+	pos := max(reader.start, int(reader.checkedOffset-reader.bufOffset))
+	for {
+		end, complete := declaredEventEnd(reader.buf[pos:reader.end])
+		if end > maxSize {
+			break
+		}
+		if false == complete {
+			reader.checkedOffset = reader.bufOffset + int64(pos)
+			return reader.end, nil
+		}
+		pos += end
+	}
+	reader.checkedOffset = reader.bufOffset + int64(pos)
+	if reader.start == pos {
+		return 0, &EventTooLargeError{reader.Offset(), maxSize}
+	}
+	return pos, nil
+}
+
+// declaredEventEnd walks the packets of the log event at the start of irBuf
+// (including any UTC offset changes preceding it) using only their tags and
+// declared lengths. Returns:
+//   - the position in irBuf the log event ends at (or extends to at least, if
+//     it is incomplete)
+//   - whether irBuf holds the complete log event. False is also returned if
+//     irBuf starts with an EOF tag or a packet that is not part of a log event
+//     (which is left for the Deserializer to report).
+func declaredEventEnd(irBuf []byte) (int, bool) {
+	pos := 0
+	for {
+		if len(irBuf) <= pos {
+			return pos, false
+		}
+		tag := irBuf[pos]
+		pos++
+		switch tag {
+		case tagUtcOffsetChange:
+			pos += 8
+		case tagVarFourByteEncoding:
+			pos += 4
+		case tagVarEightByteEncoding:
+			pos += 8
+		case tagVarStrLenUByte, tagVarStrLenUShort, tagVarStrLenInt,
+			tagLogtypeStrLenUByte, tagLogtypeStrLenUShort, tagLogtypeStrLenInt:
+			var length int
+			switch tag {
+			case tagVarStrLenUByte, tagLogtypeStrLenUByte:
+				if len(irBuf) < pos+1 {
+					return pos, false
+				}
+				length = 1 + int(irBuf[pos])
+			case tagVarStrLenUShort, tagLogtypeStrLenUShort:
+				if len(irBuf) < pos+2 {
+					return pos, false
+				}
+				length = 2 + int(binary.BigEndian.Uint16(irBuf[pos:]))
+			default:
+				if len(irBuf) < pos+4 {
+					return pos, false
+				}
+				length = 4 + int(int32(binary.BigEndian.Uint32(irBuf[pos:])))
+				if 4 > length {
+					return pos, false
+				}
+			}
+			if length > math.MaxInt-pos {
+				return math.MaxInt, false
+			}
+			pos += length
+			if tagLogtypeStrLenUByte <= tag {
+				return declaredTimestampEnd(irBuf, pos)
+			}
+		default:
+			return pos, false
+		}
+	}
+}
+
+// declaredTimestampEnd returns the position in irBuf the timestamp at pos ends
+// at, and whether irBuf holds it (see [declaredEventEnd]).
+func declaredTimestampEnd(irBuf []byte, pos int) (int, bool) {
+	if len(irBuf) <= pos {
+		return pos, false
+	}
+	switch irBuf[pos] {
+	case tagTimestampDeltaByte:
+		pos += 1 + 1
+	case tagTimestampDeltaShort:
+		pos += 1 + 2
+	case tagTimestampDeltaInt:
+		pos += 1 + 4
+	case tagTimestampVal, tagTimestampDeltaLong:
+		pos += 1 + 8
+	default:
+		return pos, false
+	}
+	return pos, len(irBuf) >= pos
+}
+
 // read is a wrapper around a io.Reader.Read call. It uses the correct range in
 // buf and adjusts the range accordingly. Always returns the number of bytes
 // read. On success nil is returned. On failure an error is forwarded from
 // [io.Reader], unless n > 0 and io.EOF == err as we have not yet consumed the
-// CLP IR.
+// CLP IR. read is only called when more IR is needed, so if the io.Reader has
+// no more IR, [io.ErrUnexpectedEOF] is returned.
 func (reader *Reader) read() (int, error) {
 	n, err := reader.ioReader.Read(reader.buf[reader.end:])
 	reader.end += n
-	if nil != err && io.EOF != err {
-		return n, err
+	if io.EOF == err {
+		if 0 == n {
+			return n, io.ErrUnexpectedEOF
+		}
+		return n, nil
 	}
-	return n, nil
+	return n, err
 }
@@ -1,9 +1,16 @@
 package ir
 
 import (
+	"bytes"
+	"io"
 	"math"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/klauspost/compress/zstd"
@@ -56,3 +63,614 @@ func TestIrReader(t *testing.T) {
 		t.Fatalf("Reader.Read failed: %v", err)
 	}
 }
+
+func TestReaderTruncated(t *testing.T) {
+	events := []ffi.LogEvent{{LogMessage: "truncated in 12 ms", Timestamp: 1}}
+	irStream := serializeEncodedTestStream(t, EncodingFourByte, TimestampInfo{}, events)
+	preamble := serializeEncodedTestStream(t, EncodingFourByte, TimestampInfo{}, nil)
+	preamble = preamble[:len(preamble)-1]
+
+	// The IR stream ends before its EOF tag
+	irreader, err := NewReader(bytes.NewReader(irStream[:len(irStream)-1]))
+	if nil != err {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	assertIrLogEvent(t, nil, irreader, events[0])
+	if _, err = irreader.Read(); io.ErrUnexpectedEOF != err {
+		t.Fatalf("Read past the end of a truncated IR stream returned: %v", err)
+	}
+	irreader.Close()
+
+	// The IR stream ends in the middle of a log event
+	irreader, err = NewReader(bytes.NewReader(irStream[:len(irStream)-2]))
+	if nil != err {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	if _, err = irreader.Read(); io.ErrUnexpectedEOF != err {
+		t.Fatalf("Read of a truncated log event returned: %v", err)
+	}
+	irreader.Close()
+
+	if _, err = NewReader(bytes.NewReader(preamble[:len(preamble)-1])); io.ErrUnexpectedEOF != err {
+		t.Fatalf("NewReader of a truncated preamble returned: %v", err)
+	}
+}
+
+func TestReaderCount(t *testing.T) {
+	events := aggregateTestEvents()
+	queries := []search.WildcardQuery{search.NewWildcardQuery("*error*", false)}
+	for _, args := range generateTestArgs(t, t.Name()) {
+		if noCompression != args.compression {
+			continue
+		}
+		irReader := openAggregateTestReader(t, args, events)
+		count, err := irReader.Count(
+			queries,
+			search.TimestampInterval{Lower: 1000, Upper: math.MaxInt64},
+		)
+		irReader.Close()
+		if nil != err {
+			t.Fatalf("Reader.Count failed: %v", err)
+		}
+		// Every third event is an error and the first error is below the lower bound
+		if expected := len(events)/3 - 1; expected != count {
+			t.Fatalf("%v: Reader.Count wrong count: %v != %v", args.name, count, expected)
+		}
+	}
+}
+
+func TestReaderHistogram(t *testing.T) {
+	events := aggregateTestEvents()
+	for _, args := range generateTestArgs(t, t.Name()) {
+		if noCompression != args.compression {
+			continue
+		}
+		irReader := openAggregateTestReader(t, args, events)
+		hist, err := irReader.Histogram(
+			[]search.WildcardQuery{search.NewWildcardQuery("*ERROR*", true)},
+			search.TimestampInterval{Lower: 0, Upper: 4 * 60 * 1000},
+			time.Minute,
+		)
+		irReader.Close()
+		if nil != err {
+			t.Fatalf("Reader.Histogram failed: %v", err)
+		}
+		if 0 != hist.Start {
+			t.Fatalf("%v: Reader.Histogram wrong start: %v", args.name, hist.Start)
+		}
+		expected := []int{20, 20, 20, 20}
+		if len(expected) != len(hist.Counts) {
+			t.Fatalf("%v: Reader.Histogram wrong counts: %v", args.name, hist.Counts)
+		}
+		for i := range expected {
+			if expected[i] != hist.Counts[i] {
+				t.Fatalf("%v: Reader.Histogram wrong counts: %v", args.name, hist.Counts)
+			}
+		}
+	}
+
+	// Buckets must be a positive whole number of milliseconds
+	for _, bucket := range []time.Duration{0, -time.Second, 1500 * time.Microsecond} {
+		irReader := openAggregateTestReader(t, generateTestArgs(t, t.Name())[0], events)
+		hist, err := irReader.Histogram(nil, search.TimestampInterval{Upper: 1000}, bucket)
+		irReader.Close()
+		if nil == err {
+			t.Fatalf("Reader.Histogram with bucket %v returned: %+v", bucket, hist)
+		}
+	}
+}
+
+func TestReaderCountBatches(t *testing.T) {
+	var events []ffi.LogEvent
+	for i := 0; i < 2*maxMatchesPerBatch+1; i++ {
+		events = append(events, ffi.LogEvent{
+			LogMessage: "processed request id=" + strconv.Itoa(i),
+			Timestamp:  ffi.EpochTimeMs(i),
+		})
+	}
+	for _, args := range generateTestArgs(t, t.Name()) {
+		if noCompression != args.compression {
+			continue
+		}
+		var buf bytes.Buffer
+		irWriter := openIrWriter(t, args, &buf)
+		for _, event := range events {
+			if _, err := irWriter.Write(event); nil != err {
+				t.Fatalf("ir.Writer.Write failed: %v", err)
+			}
+		}
+		if _, err := irWriter.CloseTo(&buf); nil != err {
+			t.Fatalf("ir.Writer.CloseTo failed: %v", err)
+		}
+		irReader, err := NewReaderSize(&buf, buf.Len())
+		if nil != err {
+			t.Fatalf("NewReaderSize failed: %v", err)
+		}
+		count, err := irReader.Count(nil, search.TimestampInterval{Lower: 0, Upper: math.MaxInt64})
+		irReader.Close()
+		if nil != err {
+			t.Fatalf("Reader.Count failed: %v", err)
+		}
+		if len(events) != count {
+			t.Fatalf("%v: Reader.Count wrong count: %v != %v", args.name, count, len(events))
+		}
+	}
+}
+
+func TestReaderLogtypeStats(t *testing.T) {
+	events := aggregateTestEvents()
+	for _, args := range generateTestArgs(t, t.Name()) {
+		if noCompression != args.compression {
+			continue
+		}
+		irReader := openAggregateTestReader(t, args, events)
+		stats, err := irReader.LogtypeStats()
+		irReader.Close()
+		if nil != err {
+			t.Fatalf("Reader.LogtypeStats failed: %v", err)
+		}
+		if 2 != len(stats) {
+			t.Fatalf("%v: Reader.LogtypeStats wrong number of logtypes: %v", args.name, stats)
+		}
+		expected := []LogtypeStats{
+			{
+				Count:          200,
+				FirstTimestamp: 1000,
+				LastTimestamp:  299000,
+				ExampleVars:    []string{"1", "bob"},
+			},
+			{
+				Count:          100,
+				FirstTimestamp: 0,
+				LastTimestamp:  297000,
+				ExampleVars:    []string{"0", "alice"},
+			},
+		}
+		templates := []string{
+			"INFO processed request id=<int> user=<dict>",
+			"ERROR processed request id=<int> user=<dict>",
+		}
+		for i := range expected {
+			if templates[i] != stats[i].Template() {
+				t.Fatalf("%v: wrong template: %q", args.name, stats[i].Template())
+			}
+			if expected[i].Count != stats[i].Count ||
+				expected[i].FirstTimestamp != stats[i].FirstTimestamp ||
+				expected[i].LastTimestamp != stats[i].LastTimestamp ||
+				0 >= stats[i].Bytes {
+				t.Fatalf("%v: wrong stats: %+v", args.name, stats[i])
+			}
+			exampleVars := strings.Join(stats[i].ExampleVars, ",")
+			if strings.Join(expected[i].ExampleVars, ",") != exampleVars {
+				t.Fatalf("%v: wrong example variables: %v", args.name, stats[i].ExampleVars)
+			}
+		}
+	}
+}
+
+func TestReaderLogtypeStatsResume(t *testing.T) {
+	events := aggregateTestEvents()[:40]
+	events[1].LogMessage = "INFO took 12.5 ms for user=bob"
+	irStream, offsets := serializeValidateTestStream(t, events)
+	for _, deserializePreamble := range []func([]byte) (Deserializer, int, error){
+		DeserializePreamble,
+		goDeserializePreamble,
+	} {
+		irreader, err := newReader(
+			bytes.NewReader(irStream),
+			ReaderOptions{},
+			deserializePreamble,
+		)
+		if nil != err {
+			t.Fatalf("newReader failed: %v", err)
+		}
+		stats, err := irreader.LogtypeStats()
+		irreader.Close()
+		if nil != err {
+			t.Fatalf("Reader.LogtypeStats failed: %v", err)
+		}
+		// Variables are decoded from their encoded form
+		idx := slices.IndexFunc(stats, func(s LogtypeStats) bool { return 1 == s.Count })
+		if -1 == idx || "12.5,bob" != strings.Join(stats[idx].ExampleVars, ",") {
+			t.Fatalf("Wrong example variables: %+v", stats)
+		}
+
+		// The Reader can still be read after LogtypeStats fails, as the state of
+		// its Deserializer is kept
+		irreader, err = newReader(
+			&timeoutOnceReader{bytes.NewReader(irStream), int(offsets[10]) + 4},
+			ReaderOptions{},
+			deserializePreamble,
+		)
+		if nil != err {
+			t.Fatalf("newReader failed: %v", err)
+		}
+		if _, err = irreader.LogtypeStats(); iotest.ErrTimeout != err {
+			t.Fatalf("Reader.LogtypeStats returned: %v", err)
+		}
+		if idx = slices.Index(offsets, irreader.Offset()); 10 != idx {
+			t.Fatalf("Reader.LogtypeStats stopped at offset %v", irreader.Offset())
+		}
+		for _, event := range events[idx:] {
+			assertIrLogEvent(t, nil, irreader, event)
+		}
+		assertEndOfIr(t, nil, irreader)
+		irreader.Close()
+	}
+}
+
+// timeoutOnceReader reads from r, failing once with [iotest.ErrTimeout] after
+// n bytes.
+type timeoutOnceReader struct {
+	r io.Reader
+	n int
+}
+
+func (reader *timeoutOnceReader) Read(p []byte) (int, error) {
+	if 0 > reader.n {
+		return reader.r.Read(p)
+	}
+	if 0 == reader.n {
+		reader.n = -1
+		return 0, iotest.ErrTimeout
+	}
+	n, err := reader.r.Read(p[:min(len(p), reader.n)])
+	reader.n -= n
+	return n, err
+}
+
+func TestReaderLogtypeStatsKV(t *testing.T) {
+	serializer, irStream := newTestKVSerializer(t, EncodingEightByte)
+	serializer.Close()
+	irReader, err := NewReader(bytes.NewReader(append(irStream, tagEof)))
+	if nil != err {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer irReader.Close()
+	if _, err = irReader.LogtypeStats(); errKVStream != err {
+		t.Fatalf("LogtypeStats of a key-value pair IR stream returned: %v", err)
+	}
+}
+
+// aggregateTestEvents returns log events one second apart, spanning 5 minutes,
+// with every third event being an error.
+func aggregateTestEvents() []ffi.LogEvent {
+	users := []string{"alice", "bob"}
+	var events []ffi.LogEvent
+	for i := 0; i < 5*60; i++ {
+		level := "INFO"
+		if 0 == i%3 {
+			level = "ERROR"
+		}
+		events = append(events, ffi.LogEvent{
+			LogMessage: level + " processed request id=" + strconv.Itoa(i) + " user=" + users[i%2],
+			Timestamp:  ffi.EpochTimeMs(i * 1000),
+		})
+	}
+	return events
+}
+
+// openAggregateTestReader serializes events into memory and returns a Reader
+// with a small buffer, so that it must be refilled during a search.
+func openAggregateTestReader(t *testing.T, args testArgs, events []ffi.LogEvent) *Reader {
+	var buf bytes.Buffer
+	irWriter := openIrWriter(t, args, &buf)
+	for _, event := range events {
+		if _, err := irWriter.Write(event); nil != err {
+			t.Fatalf("ir.Writer.Write failed: %v", err)
+		}
+	}
+	if _, err := irWriter.CloseTo(&buf); nil != err {
+		t.Fatalf("ir.Writer.CloseTo failed: %v", err)
+	}
+	irReader, err := NewReaderSize(&buf, 512)
+	if nil != err {
+		t.Fatalf("NewReaderSize failed: %v", err)
+	}
+	return irReader
+}
+
+func TestReaderOffset(t *testing.T) {
+	events := aggregateTestEvents()[:8]
+	irStream, offsets := serializeValidateTestStream(t, events)
+	offsets = append(offsets, int64(len(irStream)-1))
+	for _, deserializePreamble := range []func([]byte) (Deserializer, int, error){
+		DeserializePreamble,
+		goDeserializePreamble,
+	} {
+		irreader, err := newReader(
+			iotest.OneByteReader(bytes.NewReader(irStream)),
+			ReaderOptions{BufferSize: 16},
+			deserializePreamble,
+		)
+		if nil != err {
+			t.Fatalf("newReader failed: %v", err)
+		}
+		if -1 != irreader.EventOffset() || offsets[0] != irreader.Offset() {
+			t.Fatalf("Wrong offsets: %v, %v", irreader.EventOffset(), irreader.Offset())
+		}
+		for i, event := range events {
+			assertIrLogEvent(t, nil, irreader, event)
+			if offsets[i] != irreader.EventOffset() {
+				t.Fatalf("Wrong event offset: %v != %v", irreader.EventOffset(), offsets[i])
+			}
+			if offsets[i+1] != irreader.Offset() {
+				t.Fatalf("Wrong offset: %v != %v", irreader.Offset(), offsets[i+1])
+			}
+		}
+		assertEndOfIr(t, nil, irreader)
+		irreader.Close()
+	}
+
+	// Offsets are absolute across concatenated IR streams
+	irreader, err := NewReaderWithOptions(
+		iotest.OneByteReader(bytes.NewReader(concatenateTestStreams(t))),
+		ReaderOptions{BufferSize: 8, Concatenated: true},
+	)
+	if nil != err {
+		t.Fatalf("NewReaderWithOptions failed: %v", err)
+	}
+	defer irreader.Close()
+	var streamOffset int64
+	for _, stream := range concatenatedTestStreams {
+		empty := serializeEncodedTestStream(t, stream.encoding, stream.tsInfo, nil)
+		if 0 < len(stream.events) {
+			assertIrLogEvent(t, nil, irreader, stream.events[0])
+			expected := streamOffset + int64(len(empty)-1)
+			if expected != irreader.EventOffset() {
+				t.Fatalf("Wrong event offset: %v != %v", irreader.EventOffset(), expected)
+			}
+			for _, event := range stream.events[1:] {
+				assertIrLogEvent(t, nil, irreader, event)
+			}
+		}
+		streamOffset += int64(len(
+			serializeEncodedTestStream(t, stream.encoding, stream.tsInfo, stream.events),
+		))
+	}
+	assertEndOfIr(t, nil, irreader)
+	if streamOffset-1 != irreader.Offset() {
+		t.Fatalf("Wrong offset of the last EOF tag: %v != %v", irreader.Offset(), streamOffset-1)
+	}
+}
+
+func TestReaderMatchOffset(t *testing.T) {
+	events := aggregateTestEvents()[:30]
+	serializer, preamble, err := EightByteSerializer("", "", "UTC")
+	if nil != err {
+		t.Fatalf("EightByteSerializer failed: %v", err)
+	}
+	defer serializer.Close()
+	irStream := append([]byte{}, preamble...)
+	offsets := make([]int64, len(events))
+	for i, event := range events {
+		offsets[i] = int64(len(irStream))
+		if 3 == i {
+			// The offset of a match includes the UTC offset change preceding it
+			irView, err := serializer.(UtcOffsetSerializer).SerializeUtcOffsetChange(time.Hour)
+			if nil != err {
+				t.Fatalf("SerializeUtcOffsetChange failed: %v", err)
+			}
+			irStream = append(irStream, irView...)
+		}
+		irView, err := serializer.SerializeLogEvent(event)
+		if nil != err {
+			t.Fatalf("SerializeLogEvent failed: %v", err)
+		}
+		irStream = append(irStream, irView...)
+	}
+	irStream = append(irStream, tagEof)
+
+	queries := []search.WildcardQuery{search.NewWildcardQuery("*ERROR*", true)}
+	timeInterval := search.TimestampInterval{Lower: 0, Upper: math.MaxInt64}
+	for _, deserializePreamble := range []func([]byte) (Deserializer, int, error){
+		DeserializePreamble,
+		goDeserializePreamble,
+	} {
+		irreader, err := newReader(
+			iotest.OneByteReader(bytes.NewReader(irStream)),
+			ReaderOptions{BufferSize: 16},
+			deserializePreamble,
+		)
+		if nil != err {
+			t.Fatalf("newReader failed: %v", err)
+		}
+		for i := 0; i < len(events); i += 3 {
+			_, _, err = irreader.ReadToWildcardMatchWithTimeInterval(queries, timeInterval)
+			if nil != err {
+				t.Fatalf("ReadToWildcardMatchWithTimeInterval failed: %v", err)
+			}
+			if offsets[i] != irreader.EventOffset() {
+				t.Fatalf("Wrong match offset: %v != %v", irreader.EventOffset(), offsets[i])
+			}
+
+			// Reading resumed at the match's offset starts with the match
+			resumed, err := NewReader(bytes.NewReader(
+				append(append([]byte{}, irStream[:offsets[0]]...), irStream[offsets[i]:]...),
+			))
+			if nil != err {
+				t.Fatalf("NewReader failed: %v", err)
+			}
+			assertIrLogEvent(t, nil, resumed, events[i])
+			if 3 == i && time.Hour != resumed.UtcOffset() {
+				t.Fatalf("Wrong UTC offset of the resumed match: %v", resumed.UtcOffset())
+			}
+			resumed.Close()
+		}
+		irreader.Close()
+
+		// Count leaves the offset of the last match
+		irreader, err = newReader(bytes.NewReader(irStream), ReaderOptions{}, deserializePreamble)
+		if nil != err {
+			t.Fatalf("newReader failed: %v", err)
+		}
+		if _, err = irreader.Count(queries, timeInterval); nil != err {
+			t.Fatalf("Reader.Count failed: %v", err)
+		}
+		if offsets[len(events)-3] != irreader.EventOffset() {
+			t.Fatalf("Wrong last match offset: %v", irreader.EventOffset())
+		}
+		irreader.Close()
+	}
+}
+
+func TestReaderMaxBufferSize(t *testing.T) {
+	events := []ffi.LogEvent{
+		{LogMessage: "started in 12 ms", Timestamp: 100},
+		{LogMessage: strings.Repeat("large ", 1000), Timestamp: 200},
+		{LogMessage: "stopped in 3 ms", Timestamp: 300},
+	}
+	irStream, offsets := serializeValidateTestStream(t, events)
+	for _, deserializePreamble := range []func([]byte) (Deserializer, int, error){
+		DeserializePreamble,
+		goDeserializePreamble,
+	} {
+		opts := ReaderOptions{BufferSize: 64, MaxBufferSize: 1024}
+		irreader, err := newReader(bytes.NewReader(irStream), opts, deserializePreamble)
+		if nil != err {
+			t.Fatalf("newReader failed: %v", err)
+		}
+		assertIrLogEvent(t, nil, irreader, events[0])
+		_, err = irreader.Read()
+		tooLarge, ok := err.(*EventTooLargeError)
+		if false == ok || offsets[1] != tooLarge.Offset || 1024 != tooLarge.MaxBufferSize {
+			t.Fatalf("Read of a log event larger than the buffer returned: %v", err)
+		}
+		irreader.Close()
+
+		opts.MaxBufferSize = 8192
+		irreader, err = newReader(bytes.NewReader(irStream), opts, deserializePreamble)
+		if nil != err {
+			t.Fatalf("newReader failed: %v", err)
+		}
+		for _, event := range events {
+			assertIrLogEvent(t, nil, irreader, event)
+		}
+		assertEndOfIr(t, nil, irreader)
+		irreader.Close()
+	}
+
+	// A corrupted length fails the read before the buffer grows without bound
+	// or the Deserializer is given the log event (and allocates its length)
+	corrupted, _ := serializeValidateTestStream(t, events[:1])
+	corruptedOffset := int64(len(corrupted) - 1)
+	corrupted = append(corrupted[:corruptedOffset], tagLogtypeStrLenInt, 0x7F, 0xFF, 0xFF, 0xFF)
+	corrupted = append(corrupted, make([]byte, 1<<20)...)
+	for _, deserializePreamble := range []func([]byte) (Deserializer, int, error){
+		DeserializePreamble,
+		goDeserializePreamble,
+	} {
+		for _, wildcardMatch := range []bool{false, true} {
+			opts := ReaderOptions{BufferSize: 64, MaxBufferSize: 4096}
+			irreader, err := newReader(bytes.NewReader(corrupted), opts, deserializePreamble)
+			if nil != err {
+				t.Fatalf("newReader failed: %v", err)
+			}
+			irreader.Deserializer = &boundedDeserializer{
+				irreader.Deserializer,
+				t,
+				irreader,
+				corruptedOffset,
+			}
+			if wildcardMatch {
+				_, _, err = irreader.ReadToWildcardMatch(
+					[]search.WildcardQuery{search.NewWildcardQuery("*stopped*", true)},
+				)
+			} else {
+				assertIrLogEvent(t, nil, irreader, events[0])
+				_, err = irreader.Read()
+			}
+			tooLarge, ok := err.(*EventTooLargeError)
+			if false == ok || (false == wildcardMatch && corruptedOffset != tooLarge.Offset) {
+				t.Fatalf("Read of a corrupted log event returned: %v", err)
+			}
+			if 4096 < len(irreader.buf) {
+				t.Fatalf("Buffer grew past its maximum size: %v", len(irreader.buf))
+			}
+			irreader.Close()
+		}
+	}
+}
+
+// boundedDeserializer wraps the Deserializer of reader, failing the test if
+// it is given IR past limit (an offset in reader's io.Reader).
+type boundedDeserializer struct {
+	Deserializer
+	t      *testing.T
+	reader *Reader
+	limit  int64
+}
+
+func (deserializer *boundedDeserializer) DeserializeLogEvent(
+	irBuf []byte,
+) (*ffi.LogEventView, int, error) {
+	deserializer.checkLimit(irBuf)
+	return deserializer.Deserializer.DeserializeLogEvent(irBuf)
+}
+
+func (deserializer *boundedDeserializer) DeserializeWildcardMatchWithTimeInterval(
+	irBuf []byte,
+	mergedQuery search.MergedWildcardQuery,
+	timeInterval search.TimestampInterval,
+) (*ffi.LogEventView, int, int, error) {
+	deserializer.checkLimit(irBuf)
+	return deserializer.Deserializer.DeserializeWildcardMatchWithTimeInterval(
+		irBuf,
+		mergedQuery,
+		timeInterval,
+	)
+}
+
+func (deserializer *boundedDeserializer) checkLimit(irBuf []byte) {
+	if end := deserializer.reader.Offset() + int64(len(irBuf)); deserializer.limit < end {
+		deserializer.t.Fatalf("Deserializer given IR up to %v past %v", end, deserializer.limit)
+	}
+}
+
+func TestReaderBufferPool(t *testing.T) {
+	events := aggregateTestEvents()[:8]
+	irStream, _ := serializeValidateTestStream(t, events)
+	pool := &sync.Pool{}
+	small := make([]byte, 16)
+	pool.Put(&small)
+	for range 4 {
+		irreader, err := NewReaderWithOptions(
+			bytes.NewReader(irStream),
+			ReaderOptions{BufferSize: 64, BufferPool: pool},
+		)
+		if nil != err {
+			t.Fatalf("NewReaderWithOptions failed: %v", err)
+		}
+		if 64 > len(irreader.buf) {
+			t.Fatalf("Pooled buffer is too small: %v", len(irreader.buf))
+		}
+		for _, event := range events {
+			assertIrLogEvent(t, nil, irreader, event)
+		}
+		assertEndOfIr(t, nil, irreader)
+		irreader.Close()
+		if nil != irreader.buf {
+			t.Fatalf("Buffer not released by Close")
+		}
+	}
+	// The pool may drop its buffers at any time, so only check what is in it
+	if buf, ok := pool.Get().(*[]byte); ok && &small != buf && 64 > len(*buf) {
+		t.Fatalf("Wrong buffer in pool: %v", len(*buf))
+	}
+
+	// A pooled buffer too small for the Reader is left in the pool. Under the
+	// race detector sync.Pool drops random buffers, so try more than once.
+	kept := false
+	for range 20 {
+		pool = &sync.Pool{}
+		pool.Put(&small)
+		ReaderOptions{BufferSize: 64, BufferPool: pool}.newBuf()
+		if buf, ok := pool.Get().(*[]byte); ok && &small == buf {
+			kept = true
+			break
+		}
+	}
+	if false == kept {
+		t.Fatalf("Pooled buffer too small for the Reader was dropped")
+	}
+}
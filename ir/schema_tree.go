@@ -0,0 +1,127 @@
+package ir
+
+// SchemaTreeNodeType is the type of the values stored under a [SchemaTree]
+// node. Obj nodes are the parents of nested keys, but may also store a null
+// value or an empty object. Unstructured arrays are stored as JSON.
+//
+//go:generate stringer -type=SchemaTreeNodeType
+type SchemaTreeNodeType int
+
+const (
+	SchemaTreeNodeInt SchemaTreeNodeType = iota
+	SchemaTreeNodeFloat
+	SchemaTreeNodeBool
+	SchemaTreeNodeStr
+	SchemaTreeNodeUnstructuredArray
+	SchemaTreeNodeObj
+)
+
+// SchemaTreeRootId is the id of the root of every [SchemaTree]. The root is an
+// Obj node with an empty key, and has no parent.
+const SchemaTreeRootId = 0
+
+// SchemaTreeNode is a key in a [SchemaTree]. Id is the node's index in the
+// tree, and ParentId is the id of the Obj node the key is nested under (-1 for
+// the root).
+type SchemaTreeNode struct {
+	Id       int
+	ParentId int
+	Key      string
+	Type     SchemaTreeNodeType
+}
+
+// SchemaTree contains every key (and the type of its values) seen in a
+// key-value pair IR stream, so that each log event only needs to store the id
+// of its keys. A key nested in an object is a child of the key of that object.
+// The same key may appear under a parent multiple times with different types.
+// Nodes are never removed, and are numbered in the order they were added.
+type SchemaTree struct {
+	nodes []SchemaTreeNode
+	ids   map[schemaTreeNodeLocator]int
+}
+
+// schemaTreeNodeLocator uniquely identifies a node within a SchemaTree.
+type schemaTreeNodeLocator struct {
+	parentId int
+	key      string
+	nodeType SchemaTreeNodeType
+}
+
+// newSchemaTree returns a SchemaTree containing only the root.
+func newSchemaTree() *SchemaTree {
+	return &SchemaTree{
+		nodes: []SchemaTreeNode{{SchemaTreeRootId, -1, "", SchemaTreeNodeObj}},
+		ids:   map[schemaTreeNodeLocator]int{},
+	}
+}
+
+// Len returns the number of nodes in the tree, including the root.
+func (tree *SchemaTree) Len() int {
+	return len(tree.nodes)
+}
+
+// Node returns the node with the given id. Returns:
+//   - success: the node, true
+//   - failure: 0 value SchemaTreeNode, false if the tree has no such node
+func (tree *SchemaTree) Node(id int) (SchemaTreeNode, bool) {
+	if 0 > id || id >= len(tree.nodes) {
+		return SchemaTreeNode{}, false
+	}
+	return tree.nodes[id], true
+}
+
+// Find returns the id of the node with key and nodeType under the parent node
+// parentId. Returns:
+//   - success: the node's id, true
+//   - failure: -1, false if the tree has no such node
+func (tree *SchemaTree) Find(parentId int, key string, nodeType SchemaTreeNodeType) (int, bool) {
+	id, ok := tree.ids[schemaTreeNodeLocator{parentId, key, nodeType}]
+	if false == ok {
+		return -1, false
+	}
+	return id, true
+}
+
+// Path returns the keys from the root (exclusive) to the node with the given
+// id (inclusive), or nil if the tree has no such node.
+func (tree *SchemaTree) Path(id int) []string {
+	if 0 > id || id >= len(tree.nodes) {
+		return nil
+	}
+	var path []string
+	for ; SchemaTreeRootId != id; id = tree.nodes[id].ParentId {
+		path = append(path, tree.nodes[id].Key)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// insert adds a node under the Obj node parentId, which must exist, and
+// returns its id. Returns:
+//   - success: the new node's id, true
+//   - failure: the existing node's id, false if the node is already in the tree
+func (tree *SchemaTree) insert(
+	parentId int,
+	key string,
+	nodeType SchemaTreeNodeType,
+) (int, bool) {
+	locator := schemaTreeNodeLocator{parentId, key, nodeType}
+	if id, ok := tree.ids[locator]; ok {
+		return id, false
+	}
+	id := len(tree.nodes)
+	tree.nodes = append(tree.nodes, SchemaTreeNode{id, parentId, key, nodeType})
+	tree.ids[locator] = id
+	return id, true
+}
+
+// truncate removes every node with an id of at least length, undoing the
+// inserts made since the tree had length nodes.
+func (tree *SchemaTree) truncate(length int) {
+	for _, node := range tree.nodes[length:] {
+		delete(tree.ids, schemaTreeNodeLocator{node.ParentId, node.Key, node.Type})
+	}
+	tree.nodes = tree.nodes[:length]
+}
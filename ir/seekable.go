@@ -0,0 +1,542 @@
+package ir
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+	"github.com/y-scope/clp-ffi-go/search"
+)
+
+// Seekable zstd files store an IR stream in independently decompressible zstd
+// frames, followed by a seek table as described by the seekable zstd format
+// (https://github.com/facebook/zstd/blob/dev/contrib/seekable_format). The
+// first frame only contains the IR stream's preamble and the last frame ends
+// with its EOF tag. Every other frame contains whole log events, so it can be
+// deserialized on its own given the state of the IR stream at its start. This
+// state and the timestamp range of each frame are stored in a timestamp index:
+// a skippable frame placed between the last frame and the seek table, that is
+// ignored by other zstd decompressors. Each of its entries stores a frame's
+// previous timestamp, minimum and maximum timestamp, and UTC offset (in
+// seconds) as int64, followed by its number of log events as uint32. All
+// integers are little-endian, as in the seek table.
+const (
+	seekTableMagicNumber       uint32 = 0x184D2A5E
+	seekTableFooterMagicNumber uint32 = 0x8F92EAB1
+	seekTableChecksumFlag      byte   = 0x80
+	seekTableReservedBits      byte   = 0x7C
+	seekTableEntrySize                = 8
+	seekTableChecksumEntrySize        = 12
+	seekTableFooterSize               = 9
+	seekableIndexMagicNumber   uint32 = 0x184D2A5D
+	seekableIndexEntrySize            = 36
+	skippableFrameHeaderSize          = 8
+	defaultSeekableFrameSize          = 1024 * 1024
+	maxSeekableFrameSize              = math.MaxUint32
+)
+
+var errNotSeekable = errors.New("not a seekable zstd IR file")
+
+// A SeekableFrame describes a zstd frame of a seekable zstd IR file.
+//   - Offset, Size: the byte range of the compressed frame in the file
+//   - DecompressedOffset, DecompressedSize: the byte range of the frame's IR in
+//     the IR stream
+//   - NumEvents: the number of log events in the frame
+//   - MinTimestamp, MaxTimestamp: the range of the frame's log events'
+//     timestamps (0 if there are no log events)
+//
+// prevTimestamp and utcOffset store the state of the IR stream at the start of
+// the frame, as in a [Checkpoint].
+type SeekableFrame struct {
+	Offset             int64
+	Size               int64
+	DecompressedOffset int64
+	DecompressedSize   int64
+	NumEvents          int
+	MinTimestamp       ffi.EpochTimeMs
+	MaxTimestamp       ffi.EpochTimeMs
+	prevTimestamp      ffi.EpochTimeMs
+	utcOffset          time.Duration
+}
+
+// SeekableWriter writes an IR stream to an io.Writer as a seekable zstd file,
+// using a [Writer] to serialize the log events. A frame is compressed and
+// written out once the Writer's buffer holds at least frameSize bytes of IR.
+// frames holds the SeekableFrame of each frame written and frame the frame
+// being built. prevTimestamp stores the timestamp of the previous log event
+// (initially the reference timestamp). Close must be called to write the last
+// frame and the seek table, and to free the underlying memory.
+type SeekableWriter struct {
+	writer        *Writer
+	w             io.Writer
+	encoder       *zstd.Encoder
+	frameSize     int
+	frames        []SeekableFrame
+	frame         SeekableFrame
+	prevTimestamp ffi.EpochTimeMs
+	compressed    []byte
+}
+
+// NewSeekableWriter creates a new [SeekableWriter] with a [Serializer] based on
+// T, writing a seekable zstd file to w. frameSize denotes the amount of IR
+// (before compression) each frame contains (1MB if 0), trading compression
+// ratio for the amount decompressed to read any log event. timeZoneId denotes
+// the time zone of the source producing the log events, as in [NewWriterSize].
+// The first frame, containing the preamble, is written immediately. Returns:
+//   - success: valid [*SeekableWriter], nil
+//   - error: nil [*SeekableWriter], invalid type error or an error propagated
+//     from [FourByteSerializer], [EightByteSerializer], [zstd.NewWriter], or
+//     [io.Writer.Write]
+func NewSeekableWriter[T EightByteEncoding | FourByteEncoding](
+	w io.Writer,
+	frameSize int,
+	timeZoneId string,
+) (*SeekableWriter, error) {
+	if 0 >= frameSize {
+		frameSize = defaultSeekableFrameSize
+	}
+	sw := SeekableWriter{writer: &Writer{}, w: w, frameSize: frameSize}
+
+	var preamble BufView
+	var err error
+	var t T
+	switch any(t).(type) {
+	case EightByteEncoding:
+		sw.writer.Serializer, preamble, err = EightByteSerializer("", "", timeZoneId)
+	case FourByteEncoding:
+		sw.prevTimestamp = ffi.EpochTimeMs(time.Now().UnixMilli())
+		sw.writer.Serializer, preamble, err = FourByteSerializer(
+			"",
+			"",
+			timeZoneId,
+			sw.prevTimestamp,
+		)
+	default:
+		err = fmt.Errorf("invalid type: %T", t)
+	}
+	if nil != err {
+		return nil, err
+	}
+	sw.encoder, err = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	if nil != err {
+		sw.writer.Serializer.Close()
+		return nil, err
+	}
+	sw.writer.buf.Grow(frameSize)
+	sw.writer.buf.Write(preamble)
+	if err = sw.flushFrame(); nil != err {
+		sw.writer.Serializer.Close()
+		sw.encoder.Close()
+		return nil, err
+	}
+	return &sw, nil
+}
+
+// TrackUtcOffset forwards to [Writer.TrackUtcOffset].
+func (sw *SeekableWriter) TrackUtcOffset(location *time.Location) {
+	sw.writer.TrackUtcOffset(location)
+}
+
+// WriteUtcOffset forwards to [Writer.WriteUtcOffset].
+func (sw *SeekableWriter) WriteUtcOffset(utcOffset time.Duration) (int, error) {
+	return sw.writer.WriteUtcOffset(utcOffset)
+}
+
+// Write uses [Writer.Write] to serialize the provided log event into the
+// current frame, and writes the frame out if it is full. Returns:
+//   - success: number of bytes of IR written, nil
+//   - error: number of bytes of IR written (can be 0), error propagated from
+//     [Writer.Write] or [io.Writer.Write]
+func (sw *SeekableWriter) Write(event ffi.LogEvent) (int, error) {
+	n, err := sw.writer.Write(event)
+	if nil != err {
+		return n, err
+	}
+	if 0 == sw.frame.NumEvents || event.Timestamp < sw.frame.MinTimestamp {
+		sw.frame.MinTimestamp = event.Timestamp
+	}
+	if 0 == sw.frame.NumEvents || event.Timestamp > sw.frame.MaxTimestamp {
+		sw.frame.MaxTimestamp = event.Timestamp
+	}
+	sw.frame.NumEvents++
+	sw.prevTimestamp = event.Timestamp
+	if sw.writer.buf.Len() >= sw.frameSize {
+		return n, sw.flushFrame()
+	}
+	return n, nil
+}
+
+// Close writes the EOF tag and the last frame, followed by the timestamp index
+// and seek table. The underlying memory is freed even if writing fails, so the
+// SeekableWriter cannot be used after Close. Returns:
+//   - success: nil
+//   - error: error propagated from [SeekableWriter.flushFrame] or
+//     [io.Writer.Write]
+func (sw *SeekableWriter) Close() error {
+	defer sw.encoder.Close()
+	sw.writer.Close()
+	if err := sw.flushFrame(); nil != err {
+		return err
+	}
+
+	indexSize := len(sw.frames) * seekableIndexEntrySize
+	seekTableSize := len(sw.frames)*seekTableEntrySize + seekTableFooterSize
+	buf := make([]byte, 0, 2*skippableFrameHeaderSize+indexSize+seekTableSize)
+	buf = binary.LittleEndian.AppendUint32(buf, seekableIndexMagicNumber)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(indexSize))
+	for _, frame := range sw.frames {
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(frame.prevTimestamp))
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(frame.MinTimestamp))
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(frame.MaxTimestamp))
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(frame.utcOffset/time.Second))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(frame.NumEvents))
+	}
+	buf = binary.LittleEndian.AppendUint32(buf, seekTableMagicNumber)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(seekTableSize))
+	for _, frame := range sw.frames {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(frame.Size))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(frame.DecompressedSize))
+	}
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(sw.frames)))
+	buf = append(buf, 0)
+	buf = binary.LittleEndian.AppendUint32(buf, seekTableFooterMagicNumber)
+	_, err := sw.w.Write(buf)
+	return err
+}
+
+// flushFrame compresses the IR in the Writer's buffer into a frame, writes it
+// out, and starts the next frame. On error returns:
+//   - [CorruptedIr] error: the frame is too large for the seek table
+//   - error propagated from [io.Writer.Write]
+func (sw *SeekableWriter) flushFrame() error {
+	irBuf := sw.writer.Bytes()
+	sw.compressed = sw.encoder.EncodeAll(irBuf, sw.compressed[:0])
+	if int64(len(irBuf)) > maxSeekableFrameSize ||
+		int64(len(sw.compressed)) > maxSeekableFrameSize {
+		return CorruptedIr
+	}
+	if _, err := sw.w.Write(sw.compressed); nil != err {
+		return err
+	}
+	frame := sw.frame
+	frame.Size = int64(len(sw.compressed))
+	frame.DecompressedSize = int64(len(irBuf))
+	sw.frames = append(sw.frames, frame)
+	sw.writer.Reset()
+	sw.frame = SeekableFrame{
+		Offset:             frame.Offset + frame.Size,
+		DecompressedOffset: frame.DecompressedOffset + frame.DecompressedSize,
+		prevTimestamp:      sw.prevTimestamp,
+		utcOffset:          sw.writer.utcOffset,
+	}
+	return nil
+}
+
+// SeekableReader reads a seekable zstd IR file (written by [SeekableWriter])
+// from an io.ReaderAt, only decompressing the frames that are read. preamble
+// is a Checkpoint holding the IR stream's preamble, from which the Reader of
+// any frame is created. Close must be called to free the underlying memory.
+type SeekableReader struct {
+	r        io.ReaderAt
+	frames   []SeekableFrame
+	preamble Checkpoint
+	decoder  *zstd.Decoder
+}
+
+// NewSeekableReader creates a new [SeekableReader] reading the seekable zstd
+// IR file of size bytes in r. The seek table, timestamp index, and first frame
+// (containing the preamble) are read immediately. IR streams with an
+// unsupported version are read with a warning (see [VersionPolicy]). Returns:
+//   - success: valid [*SeekableReader], nil
+//   - error: nil [*SeekableReader], seekable format error (r is not a seekable
+//     zstd IR file), or error propagated from [io.ReaderAt.ReadAt],
+//     [zstd.Decoder.DecodeAll], or [DeserializePreamble]
+func NewSeekableReader(r io.ReaderAt, size int64) (*SeekableReader, error) {
+	frames, err := readSeekTable(r, size)
+	if nil != err {
+		return nil, err
+	}
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+	if nil != err {
+		return nil, err
+	}
+	sr := &SeekableReader{r: r, frames: frames, decoder: decoder}
+
+	irBuf, err := sr.decodeFrame(0, nil)
+	if nil != err {
+		sr.Close()
+		return nil, err
+	}
+	deserializer, _, err := DeserializePreamble(irBuf)
+	if nil != err {
+		sr.Close()
+		return nil, err
+	}
+	defer deserializer.Close()
+	if _, ok := deserializer.(KVDeserializer); ok {
+		sr.Close()
+		return nil, errKVStream
+	}
+	if err = (ReaderOptions{}).checkVersion(deserializer.Version()); nil != err {
+		sr.Close()
+		return nil, err
+	}
+	sr.preamble = Checkpoint{
+		Encoding:      deserializer.Encoding(),
+		Version:       deserializer.Version(),
+		TimestampInfo: deserializer.TimestampInfo(),
+		Metadata:      deserializer.Metadata(),
+	}
+	return sr, nil
+}
+
+// Close frees the memory used to decompress frames.
+func (sr *SeekableReader) Close() error {
+	sr.decoder.Close()
+	return nil
+}
+
+// Frames returns the SeekableFrame of each frame in the file, which must not be
+// modified. The first frame only contains the IR stream's preamble.
+func (sr *SeekableReader) Frames() []SeekableFrame {
+	return sr.frames
+}
+
+// TimestampInfo returns the TimestampInfo of the IR stream.
+func (sr *SeekableReader) TimestampInfo() TimestampInfo {
+	return sr.preamble.TimestampInfo
+}
+
+// Metadata returns the user-defined metadata of the IR stream (nil if there is
+// none), which must not be modified.
+func (sr *SeekableReader) Metadata() map[string]any {
+	return sr.preamble.Metadata
+}
+
+// Encoding returns the encoding of the IR stream.
+func (sr *SeekableReader) Encoding() Encoding {
+	return sr.preamble.Encoding
+}
+
+// NewFrameReader creates a new [Reader] that reads the IR stream from the
+// start of the frame at frameIdx to the end of the file, decompressing one
+// frame at a time. Frames before frameIdx are never decompressed. The
+// Reader's offsets (see [Reader.Offset]) are offsets in the IR stream, so they
+// can be mapped to a frame through its DecompressedOffset. Returns:
+//   - success: valid [*Reader], nil
+//   - error: nil [*Reader], invalid frame index error, or error propagated from
+//     [NewReaderWithOptions]
+func (sr *SeekableReader) NewFrameReader(frameIdx int) (*Reader, error) {
+	return sr.newFrameReader(frameIdx, len(sr.frames), ReaderOptions{})
+}
+
+// ForEachWildcardMatch calls f for each log event that matches any query in
+// queries (with the index of the matched query), within timeInterval. If
+// queries is empty every log event within timeInterval is matched. Only the
+// frames whose timestamp range overlaps timeInterval are decompressed, and
+// every log event in them is checked, as timestamps are not guaranteed to be
+// monotonic. f must not retain the view it is passed. On error returns:
+//   - [IrError] error: CLP failed to successfully deserialize
+//   - error propagated from [SeekableReader.NewFrameReader] or [Reader.Read]
+func (sr *SeekableReader) ForEachWildcardMatch(
+	queries []search.WildcardQuery,
+	timeInterval search.TimestampInterval,
+	f func(event *ffi.LogEventView, queryIdx int),
+) error {
+	mergedQuery := search.MergeWildcardQueries(queries)
+	for i, frame := range sr.frames {
+		if 0 == frame.NumEvents ||
+			frame.MinTimestamp >= timeInterval.Upper ||
+			frame.MaxTimestamp < timeInterval.Lower {
+			continue
+		}
+		opts := ReaderOptions{BufferSize: int(frame.DecompressedSize)}
+		reader, err := sr.newFrameReader(i, i+1, opts)
+		if nil != err {
+			return err
+		}
+		for range frame.NumEvents {
+			var event *ffi.LogEventView
+			event, err = reader.Read()
+			if nil != err {
+				break
+			}
+			if timeInterval.Lower > event.Timestamp || timeInterval.Upper <= event.Timestamp {
+				continue
+			}
+			if idx, ok := mergedQuery.Match(event.LogMessageView); ok {
+				f(event, idx)
+			}
+		}
+		reader.Close()
+		if nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// newFrameReader creates a Reader configured by opts that reads the frames in
+// [frameIdx, endIdx). Returns the same values as
+// [SeekableReader.NewFrameReader].
+func (sr *SeekableReader) newFrameReader(
+	frameIdx int,
+	endIdx int,
+	opts ReaderOptions,
+) (*Reader, error) {
+	if 0 > frameIdx || len(sr.frames) <= frameIdx {
+		return nil, fmt.Errorf("invalid frame index: %v", frameIdx)
+	}
+	r := &seekableFrameReader{sr: sr, next: frameIdx, end: endIdx}
+	if 0 == frameIdx {
+		return NewReaderWithOptions(r, opts)
+	}
+	frame := sr.frames[frameIdx]
+	cp := sr.preamble
+	cp.Offset = frame.DecompressedOffset
+	cp.PrevTimestamp = frame.prevTimestamp
+	cp.UtcOffset = frame.utcOffset
+	return newCheckpointReader(r, cp, opts, DeserializePreamble)
+}
+
+// decodeFrame reads and decompresses the frame at frameIdx, appending its IR
+// to irBuf. On error returns:
+//   - irBuf
+//   - seekable format error: the frame's size does not match the seek table
+//   - error propagated from [readAt] or [zstd.Decoder.DecodeAll]
+func (sr *SeekableReader) decodeFrame(frameIdx int, irBuf []byte) ([]byte, error) {
+	frame := sr.frames[frameIdx]
+	compressed := make([]byte, frame.Size)
+	if err := readAt(sr.r, compressed, frame.Offset); nil != err {
+		return irBuf, err
+	}
+	decompressed, err := sr.decoder.DecodeAll(compressed, irBuf)
+	if nil != err {
+		return irBuf, err
+	}
+	if int64(len(decompressed)-len(irBuf)) != frame.DecompressedSize {
+		return irBuf, errNotSeekable
+	}
+	return decompressed, nil
+}
+
+// seekableFrameReader is an io.Reader of the IR in the frames [next, end) of a
+// SeekableReader, decompressing each frame once the IR of the previous one has
+// been read. buf holds the IR of the current frame, of which pos bytes have
+// been read.
+type seekableFrameReader struct {
+	sr   *SeekableReader
+	next int
+	end  int
+	buf  []byte
+	pos  int
+}
+
+// Read implements [io.Reader], returning io.EOF after the last frame.
+func (fr *seekableFrameReader) Read(p []byte) (int, error) {
+	for len(fr.buf) == fr.pos {
+		if fr.end <= fr.next {
+			return 0, io.EOF
+		}
+		var err error
+		fr.buf, err = fr.sr.decodeFrame(fr.next, fr.buf[:0])
+		if nil != err {
+			return 0, err
+		}
+		fr.next++
+		fr.pos = 0
+	}
+	n := copy(p, fr.buf[fr.pos:])
+	fr.pos += n
+	return n, nil
+}
+
+// readSeekTable reads the seek table and timestamp index at the end of the
+// file of size bytes in r, returning the SeekableFrame of each frame. On error
+// returns:
+//   - nil frames
+//   - seekable format error: r does not end with a valid seek table and
+//     timestamp index
+//   - error propagated from [io.ReaderAt.ReadAt]
+func readSeekTable(r io.ReaderAt, size int64) ([]SeekableFrame, error) {
+	footer := make([]byte, seekTableFooterSize)
+	if err := readAt(r, footer, size-seekTableFooterSize); nil != err {
+		return nil, err
+	}
+	if seekTableFooterMagicNumber != binary.LittleEndian.Uint32(footer[5:]) ||
+		0 != footer[4]&seekTableReservedBits {
+		return nil, errNotSeekable
+	}
+	numFrames := int64(binary.LittleEndian.Uint32(footer))
+	entrySize := int64(seekTableEntrySize)
+	if 0 != footer[4]&seekTableChecksumFlag {
+		entrySize = seekTableChecksumEntrySize
+	}
+	seekTableSize := numFrames*entrySize + seekTableFooterSize
+	indexSize := numFrames * seekableIndexEntrySize
+	indexOffset := size - seekTableSize - indexSize - 2*skippableFrameHeaderSize
+	if 0 == numFrames || 0 > indexOffset {
+		return nil, errNotSeekable
+	}
+
+	buf := make([]byte, size-indexOffset)
+	if err := readAt(r, buf, indexOffset); nil != err {
+		return nil, err
+	}
+	index := buf[:skippableFrameHeaderSize+indexSize]
+	seekTable := buf[len(index) : len(buf)-seekTableFooterSize]
+	if seekableIndexMagicNumber != binary.LittleEndian.Uint32(index) ||
+		uint32(indexSize) != binary.LittleEndian.Uint32(index[4:]) ||
+		seekTableMagicNumber != binary.LittleEndian.Uint32(seekTable) ||
+		uint32(seekTableSize) != binary.LittleEndian.Uint32(seekTable[4:]) {
+		return nil, errNotSeekable
+	}
+	index = index[skippableFrameHeaderSize:]
+	seekTable = seekTable[skippableFrameHeaderSize:]
+
+	frames := make([]SeekableFrame, numFrames)
+	var offset int64
+	var decompressedOffset int64
+	for i := range frames {
+		entry := seekTable[int64(i)*entrySize:]
+		indexEntry := index[i*seekableIndexEntrySize:]
+		utcOffsetSec := int64(binary.LittleEndian.Uint64(indexEntry[24:]))
+		frames[i] = SeekableFrame{
+			Offset:             offset,
+			Size:               int64(binary.LittleEndian.Uint32(entry)),
+			DecompressedOffset: decompressedOffset,
+			DecompressedSize:   int64(binary.LittleEndian.Uint32(entry[4:])),
+			NumEvents:          int(binary.LittleEndian.Uint32(indexEntry[32:])),
+			MinTimestamp:       ffi.EpochTimeMs(binary.LittleEndian.Uint64(indexEntry[8:])),
+			MaxTimestamp:       ffi.EpochTimeMs(binary.LittleEndian.Uint64(indexEntry[16:])),
+			prevTimestamp:      ffi.EpochTimeMs(binary.LittleEndian.Uint64(indexEntry)),
+			utcOffset:          time.Duration(utcOffsetSec) * time.Second,
+		}
+		offset += frames[i].Size
+		decompressedOffset += frames[i].DecompressedSize
+	}
+	if offset != indexOffset {
+		return nil, errNotSeekable
+	}
+	return frames, nil
+}
+
+// readAt reads len(buf) bytes at offset from r. On error returns:
+//   - seekable format error: offset is negative
+//   - error propagated from [io.ReaderAt.ReadAt]
+func readAt(r io.ReaderAt, buf []byte, offset int64) error {
+	if 0 > offset {
+		return errNotSeekable
+	}
+	n, err := r.ReadAt(buf, offset)
+	if len(buf) == n {
+		return nil
+	}
+	return err
+}
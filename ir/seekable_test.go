@@ -0,0 +1,224 @@
+package ir
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+	"github.com/y-scope/clp-ffi-go/search"
+)
+
+// seekableTestEvents returns log events a minute apart spanning the daylight
+// saving time transition of America/New_York on 2023-03-12 at 07:00 UTC.
+func seekableTestEvents() []utcOffsetTestEvent {
+	transition := time.Date(2023, 3, 12, 7, 0, 0, 0, time.UTC)
+	events := make([]utcOffsetTestEvent, 0, 200)
+	for i := range cap(events) {
+		timestamp := transition.Add(time.Duration(i-100) * time.Minute)
+		utcOffset := -5 * time.Hour
+		if false == timestamp.Before(transition) {
+			utcOffset = -4 * time.Hour
+		}
+		events = append(events, utcOffsetTestEvent{
+			ffi.LogEvent{
+				LogMessage: fmt.Sprintf("request %v took %v ms", i, i%17),
+				Timestamp:  ffi.EpochTimeMs(timestamp.UnixMilli()),
+			},
+			utcOffset,
+		})
+	}
+	return events
+}
+
+// writeSeekableTestFile returns a seekable zstd IR file of events, written
+// with small frames.
+func writeSeekableTestFile(t *testing.T, encoding Encoding, events []utcOffsetTestEvent) []byte {
+	location, err := time.LoadLocation("America/New_York")
+	if nil != err {
+		t.Fatalf("time.LoadLocation failed: %v", err)
+	}
+	var buf bytes.Buffer
+	var sw *SeekableWriter
+	if EncodingFourByte == encoding {
+		sw, err = NewSeekableWriter[FourByteEncoding](&buf, 256, location.String())
+	} else {
+		sw, err = NewSeekableWriter[EightByteEncoding](&buf, 256, location.String())
+	}
+	if nil != err {
+		t.Fatalf("NewSeekableWriter failed: %v", err)
+	}
+	sw.TrackUtcOffset(location)
+	for _, e := range events {
+		if _, err = sw.Write(e.event); nil != err {
+			t.Fatalf("SeekableWriter.Write failed: %v", err)
+		}
+	}
+	if err = sw.Close(); nil != err {
+		t.Fatalf("SeekableWriter.Close failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestSeekable(t *testing.T) {
+	events := seekableTestEvents()
+	for _, encoding := range []Encoding{EncodingEightByte, EncodingFourByte} {
+		file := writeSeekableTestFile(t, encoding, events)
+
+		// Any zstd decompressor can read the entire IR stream
+		zstdReader, err := zstd.NewReader(bytes.NewReader(file))
+		if nil != err {
+			t.Fatalf("zstd.NewReader failed: %v", err)
+		}
+		irreader, err := NewReader(zstdReader)
+		if nil != err {
+			t.Fatalf("NewReader failed: %v", err)
+		}
+		for _, e := range events {
+			assertIrLogEvent(t, nil, irreader, e.event)
+		}
+		assertEndOfIr(t, nil, irreader)
+		irreader.Close()
+		zstdReader.Close()
+
+		sr, err := NewSeekableReader(bytes.NewReader(file), int64(len(file)))
+		if nil != err {
+			t.Fatalf("NewSeekableReader failed: %v", err)
+		}
+		if encoding != sr.Encoding() || "America/New_York" != sr.TimestampInfo().TimeZoneId {
+			t.Fatalf("Wrong preamble: %v, %v", sr.Encoding(), sr.TimestampInfo())
+		}
+		frames := sr.Frames()
+		if len(frames) < 4 || 0 != frames[0].NumEvents {
+			t.Fatalf("Wrong frames: %+v", frames)
+		}
+		eventIdx := 0
+		for frameIdx, frame := range frames {
+			assertSeekableFrameReader(t, sr, frameIdx, events[eventIdx:])
+			for _, e := range events[eventIdx : eventIdx+frame.NumEvents] {
+				timestamp := e.event.Timestamp
+				if frame.MinTimestamp > timestamp || frame.MaxTimestamp < timestamp {
+					t.Fatalf("Log event outside of frame %v: %v", frameIdx, e.event)
+				}
+			}
+			eventIdx += frame.NumEvents
+		}
+		if len(events) != eventIdx {
+			t.Fatalf("Wrong number of log events in frames: %v", eventIdx)
+		}
+		sr.Close()
+	}
+}
+
+// assertSeekableFrameReader asserts that reading from the frame at frameIdx
+// reads events with their UTC offsets.
+func assertSeekableFrameReader(
+	t *testing.T,
+	sr *SeekableReader,
+	frameIdx int,
+	events []utcOffsetTestEvent,
+) {
+	irreader, err := sr.NewFrameReader(frameIdx)
+	if nil != err {
+		t.Fatalf("NewFrameReader failed: %v", err)
+	}
+	defer irreader.Close()
+	if sr.Frames()[frameIdx].DecompressedOffset > irreader.Offset() {
+		t.Fatalf("Wrong offset for frame %v: %v", frameIdx, irreader.Offset())
+	}
+	for _, e := range events {
+		assertIrLogEvent(t, nil, irreader, e.event)
+		if e.utcOffset != irreader.UtcOffset() {
+			t.Fatalf("Wrong UTC offset: %v != %v", irreader.UtcOffset(), e.utcOffset)
+		}
+	}
+	assertEndOfIr(t, nil, irreader)
+}
+
+// countingReaderAt is an io.ReaderAt recording the offset of each read.
+type countingReaderAt struct {
+	*bytes.Reader
+	offsets map[int64]bool
+}
+
+func (r *countingReaderAt) ReadAt(p []byte, offset int64) (int, error) {
+	r.offsets[offset] = true
+	return r.Reader.ReadAt(p, offset)
+}
+
+func TestSeekableWildcardMatch(t *testing.T) {
+	events := seekableTestEvents()
+	file := writeSeekableTestFile(t, EncodingFourByte, events)
+	r := &countingReaderAt{bytes.NewReader(file), map[int64]bool{}}
+	sr, err := NewSeekableReader(r, int64(len(file)))
+	if nil != err {
+		t.Fatalf("NewSeekableReader failed: %v", err)
+	}
+	defer sr.Close()
+
+	interval := search.TimestampInterval{
+		Lower: events[90].event.Timestamp,
+		Upper: events[110].event.Timestamp,
+	}
+	queries := []search.WildcardQuery{search.NewWildcardQuery("*took 3 ms", true)}
+	var expected []ffi.LogEvent
+	for _, e := range events[90:110] {
+		if _, ok := search.MergeWildcardQueries(queries).Match(e.event.LogMessage); ok {
+			expected = append(expected, e.event)
+		}
+	}
+	var matches []ffi.LogEvent
+	clear(r.offsets)
+	err = sr.ForEachWildcardMatch(queries, interval, func(event *ffi.LogEventView, idx int) {
+		matches = append(matches, ffi.LogEvent{
+			LogMessage: strings.Clone(event.LogMessageView),
+			Timestamp:  event.Timestamp,
+		})
+	})
+	if nil != err {
+		t.Fatalf("ForEachWildcardMatch failed: %v", err)
+	}
+	if fmt.Sprint(expected) != fmt.Sprint(matches) || 0 == len(matches) {
+		t.Fatalf("Wrong matches: %v != %v", matches, expected)
+	}
+
+	// Only the frames overlapping the interval are read
+	for _, frame := range sr.Frames() {
+		overlaps := 0 != frame.NumEvents &&
+			frame.MinTimestamp < interval.Upper &&
+			frame.MaxTimestamp >= interval.Lower
+		if overlaps != r.offsets[frame.Offset] {
+			t.Fatalf("Frame %+v read: %v", frame, r.offsets[frame.Offset])
+		}
+	}
+	if len(r.offsets) >= len(sr.Frames())/2 {
+		t.Fatalf("Too many frames read: %v", len(r.offsets))
+	}
+}
+
+func TestSeekableInvalid(t *testing.T) {
+	file := writeSeekableTestFile(t, EncodingEightByte, seekableTestEvents()[:1])
+	for _, invalid := range [][]byte{
+		nil,
+		file[:len(file)-1],
+		append(append([]byte{}, file[:len(file)-1]...), 0),
+		serializeEncodedTestStream(t, EncodingEightByte, TimestampInfo{}, nil),
+	} {
+		_, err := NewSeekableReader(bytes.NewReader(invalid), int64(len(invalid)))
+		if errNotSeekable != err {
+			t.Fatalf("NewSeekableReader of an invalid file returned: %v", err)
+		}
+	}
+	sr, err := NewSeekableReader(bytes.NewReader(file), int64(len(file)))
+	if nil != err {
+		t.Fatalf("NewSeekableReader failed: %v", err)
+	}
+	defer sr.Close()
+	if _, err = sr.NewFrameReader(len(sr.Frames())); nil == err {
+		t.Fatalf("NewFrameReader of an invalid frame index succeeded")
+	}
+}
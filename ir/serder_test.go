@@ -148,17 +148,89 @@ func assertIrPreamble(
 		)
 	}
 	if fourByteEncoding == args.encoding {
-		deserializer, ok := irreader.Deserializer.(*fourByteDeserializer)
+		prevTimestamp, ok := fourByteTimestamp(irreader.Deserializer)
 		if false == ok {
-			t.Fatalf("casting Deserializer to *fourByteDeserializer failed for fourByteEncoding.")
+			t.Fatalf("NewReader did not create a four byte Deserializer for fourByteEncoding.")
 		}
-		if deserializer.prevTimestamp != preamble.prevTimestamp {
+		if prevTimestamp != preamble.prevTimestamp {
 			t.Fatalf(
 				"NewReader wrong reference timestamp: '%v' != '%v'",
-				deserializer.prevTimestamp,
+				prevTimestamp,
 				preamble.prevTimestamp,
 			)
 		}
 	}
 	return irreader
 }
+
+func TestDeserializeLogEvents(t *testing.T) {
+	events := aggregateTestEvents()[:8]
+	irStream, offsets := serializeValidateTestStream(t, events)
+	for _, deserializePreamble := range []func([]byte) (Deserializer, int, error){
+		DeserializePreamble,
+		goDeserializePreamble,
+	} {
+		deserializer, pos, err := deserializePreamble(irStream)
+		if nil != err {
+			t.Fatalf("deserializePreamble failed: %v", err)
+		}
+		batchDeserializer, ok := deserializer.(BatchDeserializer)
+		if false == ok {
+			t.Fatalf("%T is not a BatchDeserializer", deserializer)
+		}
+		var views []ffi.LogEventView
+		numEvents := 0
+		for {
+			batch, n, err := batchDeserializer.DeserializeLogEvents(irStream[pos:], 3)
+			if EndOfIr == err {
+				break
+			}
+			if nil != err || 0 == len(batch) || 3 < len(batch) {
+				t.Fatalf("DeserializeLogEvents returned %v events: %v", len(batch), err)
+			}
+			views = append(views, batch...)
+			pos += n
+			numEvents += len(batch)
+			if int64(pos) != append(offsets, int64(len(irStream)-1))[numEvents] {
+				t.Fatalf("Wrong position after %v log events: %v", numEvents, pos)
+			}
+		}
+		if len(events) != numEvents {
+			t.Fatalf("DeserializeLogEvents returned %v log events", numEvents)
+		}
+		deserializer.Close()
+		// The views remain valid after later calls and after closing
+		assertLogEventViews(t, views, events)
+
+		// A truncated log event ends the batch and is reported by the next call
+		deserializer, pos, err = deserializePreamble(irStream)
+		if nil != err {
+			t.Fatalf("deserializePreamble failed: %v", err)
+		}
+		batchDeserializer = deserializer.(BatchDeserializer)
+		truncated := irStream[pos : offsets[4]+2]
+		batch, n, err := batchDeserializer.DeserializeLogEvents(truncated, 0)
+		if nil != err || int(offsets[4])-pos != n {
+			t.Fatalf("DeserializeLogEvents failed: %v, %v", n, err)
+		}
+		assertLogEventViews(t, batch, events[:4])
+		if _, _, err = batchDeserializer.DeserializeLogEvents(
+			truncated[n:],
+			0,
+		); IncompleteIr != err {
+			t.Fatalf("DeserializeLogEvents of a truncated log event returned: %v", err)
+		}
+		deserializer.Close()
+	}
+}
+
+func assertLogEventViews(t *testing.T, views []ffi.LogEventView, events []ffi.LogEvent) {
+	if len(events) != len(views) {
+		t.Fatalf("Wrong number of log events: %v != %v", len(views), len(events))
+	}
+	for i, event := range events {
+		if event.Timestamp != views[i].Timestamp || event.LogMessage != views[i].LogMessageView {
+			t.Fatalf("Wrong log event: %v != %v", views[i], event)
+		}
+	}
+}
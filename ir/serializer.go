@@ -1,13 +1,8 @@
 package ir
 
-/*
-#include <ffi_go/defs.h>
-#include <ffi_go/ir/serializer.h>
-*/
-import "C"
-
 import (
-	"unsafe"
+	"errors"
+	"time"
 
 	"github.com/y-scope/clp-ffi-go/ffi"
 )
@@ -25,148 +20,91 @@ type Serializer interface {
 	Close() error
 }
 
-// EightByteSerializer creates and returns a new Serializer that writes eight
-// byte encoded CLP IR and serializes a IR preamble into a BufView using it. On
-// error returns:
+// A UtcOffsetSerializer is a [Serializer] that can also serialize UTC offset
+// changes, which every Serializer of this package implements. It is separate
+// from Serializer so that implementations outside this package remain
+// Serializers. SerializeUtcOffsetChange serializes a change of the UTC offset
+// (truncated to whole seconds) that applies to the log events serialized after
+// it (e.g. after a daylight saving time transition).
+type UtcOffsetSerializer interface {
+	Serializer
+	SerializeUtcOffsetChange(utcOffset time.Duration) (BufView, error)
+}
+
+// errNoUtcOffsetChanges is returned when a UTC offset change must be
+// serialized by a [Serializer] that is not a [UtcOffsetSerializer].
+var errNoUtcOffsetChanges = errors.New("serializer cannot serialize UTC offset changes")
+
+// EightByteSerializerWithMetadata is [EightByteSerializer], additionally
+// storing userMetadata in the preamble's metadata. userMetadata may contain
+// any value that can be marshalled as JSON (e.g. hostname, service, or build
+// version), and is exposed when reading the stream by [Deserializer].Metadata.
+// On error returns:
 //   - nil Serializer
 //   - nil BufView
 //   - [IrError] error: CLP failed to successfully serialize
-func EightByteSerializer(
+//   - [encoding/json] error: marshalling userMetadata failed
+func EightByteSerializerWithMetadata(
 	tsPattern string,
 	tsPatternSyntax string,
 	timeZoneId string,
+	userMetadata map[string]any,
 ) (Serializer, BufView, error) {
-	var irView C.ByteSpan
-	irs := eightByteSerializer{
-		commonSerializer{TimestampInfo{tsPattern, tsPatternSyntax, timeZoneId}, nil},
+	serializer, preamble, err := EightByteSerializer(tsPattern, tsPatternSyntax, timeZoneId)
+	if nil != err || nil == userMetadata {
+		return serializer, preamble, err
 	}
-	if err := IrError(C.ir_serializer_new_eight_byte_serializer_with_preamble(
-		newCStringView(tsPattern),
-		newCStringView(tsPatternSyntax),
-		newCStringView(timeZoneId),
-		&irs.cptr,
-		&irView,
-	)); Success != err {
-		return nil, nil, err
-	}
-	return &irs, unsafe.Slice((*byte)(irView.m_data), irView.m_size), nil
+	return withPreambleMetadata(serializer, false, 0, userMetadata)
 }
 
-// FourByteSerializer creates and returns a new Serializer that writes four byte
-// encoded CLP IR and serializes a IR preamble into a BufView using it. On error
+// FourByteSerializerWithMetadata is [FourByteSerializer], additionally storing
+// userMetadata in the preamble's metadata. userMetadata may contain any value
+// that can be marshalled as JSON (e.g. hostname, service, or build version),
+// and is exposed when reading the stream by [Deserializer].Metadata. On error
 // returns:
 //   - nil Serializer
 //   - nil BufView
 //   - [IrError] error: CLP failed to successfully serialize
-func FourByteSerializer(
+//   - [encoding/json] error: marshalling userMetadata failed
+func FourByteSerializerWithMetadata(
 	tsPattern string,
 	tsPatternSyntax string,
 	timeZoneId string,
 	referenceTs ffi.EpochTimeMs,
+	userMetadata map[string]any,
 ) (Serializer, BufView, error) {
-	var irView C.ByteSpan
-	irs := fourByteSerializer{
-		commonSerializer{TimestampInfo{tsPattern, tsPatternSyntax, timeZoneId}, nil},
+	serializer, preamble, err := FourByteSerializer(
+		tsPattern,
+		tsPatternSyntax,
+		timeZoneId,
 		referenceTs,
+	)
+	if nil != err || nil == userMetadata {
+		return serializer, preamble, err
 	}
-	if err := IrError(C.ir_serializer_new_four_byte_serializer_with_preamble(
-		newCStringView(tsPattern),
-		newCStringView(tsPatternSyntax),
-		newCStringView(timeZoneId),
-		C.int64_t(referenceTs),
-		&irs.cptr,
-		&irView,
-	)); Success != err {
-		return nil, nil, err
-	}
-	return &irs, unsafe.Slice((*byte)(irView.m_data), irView.m_size), nil
-}
-
-// commonSerializer contains fields common to all types of CLP IR encoding.
-// TimestampInfo stores information common to all timestamps found in the IR.
-// cptr holds a reference to the underlying C++ objected used as backing storage
-// for the Views returned by the serializer. Close must be called to free this
-// underlying memory and failure to do so will result in a memory leak.
-type commonSerializer struct {
-	tsInfo TimestampInfo
-	cptr   unsafe.Pointer
-}
-
-// Closes the serializer by releasing the underlying C++ allocated memory.
-// Failure to call Close will result in a memory leak.
-func (serializer *commonSerializer) Close() error {
-	if nil != serializer.cptr {
-		C.ir_serializer_close(serializer.cptr)
-		serializer.cptr = nil
-	}
-	return nil
-}
-
-// Returns the TimestampInfo of the Serializer.
-func (serializer commonSerializer) TimestampInfo() TimestampInfo {
-	return serializer.tsInfo
+	return withPreambleMetadata(serializer, true, referenceTs, userMetadata)
 }
 
-type eightByteSerializer struct {
-	commonSerializer
-}
-
-// SerializeLogEvent attempts to serialize the log event, event, into an eight
-// byte encoded CLP IR byte stream. On error returns:
-//   - a nil BufView
-//   - [IrError] based on the failure of the Cgo call
-func (serializer *eightByteSerializer) SerializeLogEvent(
-	event ffi.LogEvent,
-) (BufView, error) {
-	return serializeLogEvent(serializer, event)
-}
-
-// fourByteSerializer contains both a common CLP IR serializer and stores the
-// previously seen log event's timestamp. The previous timestamp is necessary to
-// calculate the current timestamp as four byte encoding only encodes the
-// timestamp delta between the current log event and the previous.
-type fourByteSerializer struct {
-	commonSerializer
-	prevTimestamp ffi.EpochTimeMs
-}
-
-// SerializeLogEvent attempts to serialize the log event, event, into a four
-// byte encoded CLP IR byte stream. On error returns:
-//   - nil BufView
-//   - [IrError] based on the failure of the Cgo call
-func (serializer *fourByteSerializer) SerializeLogEvent(
-	event ffi.LogEvent,
-) (BufView, error) {
-	return serializeLogEvent(serializer, event)
-}
-
-func serializeLogEvent(
+// withPreambleMetadata returns serializer with a new preamble containing
+// userMetadata, replacing the preamble serializer was created with. The native
+// library cannot serialize additional metadata, so the preamble is always
+// serialized in Go. On error, serializer is closed.
+func withPreambleMetadata(
 	serializer Serializer,
-	event ffi.LogEvent,
-) (BufView, error) {
-	var irView C.ByteSpan
-	var err error
-	switch irs := serializer.(type) {
-	case *eightByteSerializer:
-		err = IrError(C.ir_serializer_serialize_eight_byte_log_event(
-			newCStringView(event.LogMessage),
-			C.int64_t(event.Timestamp),
-			irs.cptr,
-			&irView,
-		))
-	case *fourByteSerializer:
-		err = IrError(C.ir_serializer_serialize_four_byte_log_event(
-			newCStringView(event.LogMessage),
-			C.int64_t(event.Timestamp-irs.prevTimestamp),
-			irs.cptr,
-			&irView,
-		))
-		if Success == err {
-			irs.prevTimestamp = event.Timestamp
-		}
-	}
-	if Success != err {
-		return nil, err
+	isFourByte bool,
+	referenceTs ffi.EpochTimeMs,
+	userMetadata map[string]any,
+) (Serializer, BufView, error) {
+	preamble, err := appendPreamble(
+		nil,
+		isFourByte,
+		serializer.TimestampInfo(),
+		referenceTs,
+		userMetadata,
+	)
+	if nil != err {
+		serializer.Close()
+		return nil, nil, err
 	}
-	return unsafe.Slice((*byte)(irView.m_data), irView.m_size), nil
+	return serializer, preamble, nil
 }
@@ -0,0 +1,189 @@
+//go:build cgo && !purego
+
+package ir
+
+/*
+#include <ffi_go/defs.h>
+#include <ffi_go/ir/serializer.h>
+*/
+import "C"
+
+import (
+	"runtime"
+	"time"
+	"unsafe"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+)
+
+// EightByteSerializer creates and returns a new Serializer that writes eight
+// byte encoded CLP IR and serializes a IR preamble into a BufView using it. On
+// error returns:
+//   - nil Serializer
+//   - nil BufView
+//   - [IrError] error: CLP failed to successfully serialize
+func EightByteSerializer(
+	tsPattern string,
+	tsPatternSyntax string,
+	timeZoneId string,
+) (Serializer, BufView, error) {
+	var irView C.ByteSpan
+	irs := eightByteSerializer{
+		commonSerializer{tsInfo: TimestampInfo{tsPattern, tsPatternSyntax, timeZoneId}},
+	}
+	if err := IrError(C.ir_serializer_new_eight_byte_serializer_with_preamble(
+		newCStringView(tsPattern),
+		newCStringView(tsPatternSyntax),
+		newCStringView(timeZoneId),
+		&irs.cptr,
+		&irView,
+	)); Success != err {
+		return nil, nil, err
+	}
+	irs.track()
+	return &irs, unsafe.Slice((*byte)(irView.m_data), irView.m_size), nil
+}
+
+// FourByteSerializer creates and returns a new Serializer that writes four byte
+// encoded CLP IR and serializes a IR preamble into a BufView using it. On error
+// returns:
+//   - nil Serializer
+//   - nil BufView
+//   - [IrError] error: CLP failed to successfully serialize
+func FourByteSerializer(
+	tsPattern string,
+	tsPatternSyntax string,
+	timeZoneId string,
+	referenceTs ffi.EpochTimeMs,
+) (Serializer, BufView, error) {
+	var irView C.ByteSpan
+	irs := fourByteSerializer{
+		commonSerializer{tsInfo: TimestampInfo{tsPattern, tsPatternSyntax, timeZoneId}},
+		referenceTs,
+	}
+	if err := IrError(C.ir_serializer_new_four_byte_serializer_with_preamble(
+		newCStringView(tsPattern),
+		newCStringView(tsPatternSyntax),
+		newCStringView(timeZoneId),
+		C.int64_t(referenceTs),
+		&irs.cptr,
+		&irView,
+	)); Success != err {
+		return nil, nil, err
+	}
+	irs.track()
+	return &irs, unsafe.Slice((*byte)(irView.m_data), irView.m_size), nil
+}
+
+// commonSerializer contains fields common to all types of CLP IR encoding.
+// TimestampInfo stores information common to all timestamps found in the IR.
+// cptr holds a reference to the underlying C++ objected used as backing storage
+// for the Views returned by the serializer. Close must be called to free this
+// underlying memory and failure to do so will result in a memory leak. The
+// native library does not support UTC offset changes, so they are serialized
+// in Go into utcOffsetBuf. ref references the serializer's leak tracking (see
+// [SetLeakTracking]).
+type commonSerializer struct {
+	tsInfo       TimestampInfo
+	cptr         unsafe.Pointer
+	utcOffsetBuf []byte
+	ref          leakRef
+}
+
+// track tracks the serializer for leaks. Its C++ serializer is not deleted once
+// it is garbage collected, as returned views may still reference it.
+func (serializer *commonSerializer) track() {
+	serializer.ref = trackLeaks(serializer, "Serializer", nil)
+}
+
+// Closes the serializer by releasing the underlying C++ allocated memory.
+// Failure to call Close will result in a memory leak.
+func (serializer *commonSerializer) Close() error {
+	if nil != serializer.cptr {
+		serializer.ref.untrack()
+		C.ir_serializer_close(serializer.cptr)
+		serializer.cptr = nil
+	}
+	serializer.utcOffsetBuf = nil
+	return nil
+}
+
+// Returns the TimestampInfo of the Serializer.
+func (serializer commonSerializer) TimestampInfo() TimestampInfo {
+	return serializer.tsInfo
+}
+
+// SerializeUtcOffsetChange serializes a change of the UTC offset to utcOffset
+// (truncated to whole seconds), applying to the log events serialized after
+// it. Always returns a nil error.
+func (serializer *commonSerializer) SerializeUtcOffsetChange(
+	utcOffset time.Duration,
+) (BufView, error) {
+	serializer.utcOffsetBuf = appendUtcOffsetChange(serializer.utcOffsetBuf[:0], utcOffset)
+	return serializer.utcOffsetBuf, nil
+}
+
+type eightByteSerializer struct {
+	commonSerializer
+}
+
+// SerializeLogEvent attempts to serialize the log event, event, into an eight
+// byte encoded CLP IR byte stream. On error returns:
+//   - a nil BufView
+//   - [IrError] based on the failure of the Cgo call
+func (serializer *eightByteSerializer) SerializeLogEvent(
+	event ffi.LogEvent,
+) (BufView, error) {
+	return serializeLogEvent(serializer, event)
+}
+
+// fourByteSerializer contains both a common CLP IR serializer and stores the
+// previously seen log event's timestamp. The previous timestamp is necessary to
+// calculate the current timestamp as four byte encoding only encodes the
+// timestamp delta between the current log event and the previous.
+type fourByteSerializer struct {
+	commonSerializer
+	prevTimestamp ffi.EpochTimeMs
+}
+
+// SerializeLogEvent attempts to serialize the log event, event, into a four
+// byte encoded CLP IR byte stream. On error returns:
+//   - nil BufView
+//   - [IrError] based on the failure of the Cgo call
+func (serializer *fourByteSerializer) SerializeLogEvent(
+	event ffi.LogEvent,
+) (BufView, error) {
+	return serializeLogEvent(serializer, event)
+}
+
+func serializeLogEvent(
+	serializer Serializer,
+	event ffi.LogEvent,
+) (BufView, error) {
+	var irView C.ByteSpan
+	var err error
+	switch irs := serializer.(type) {
+	case *eightByteSerializer:
+		err = IrError(C.ir_serializer_serialize_eight_byte_log_event(
+			newCStringView(event.LogMessage),
+			C.int64_t(event.Timestamp),
+			irs.cptr,
+			&irView,
+		))
+	case *fourByteSerializer:
+		err = IrError(C.ir_serializer_serialize_four_byte_log_event(
+			newCStringView(event.LogMessage),
+			C.int64_t(event.Timestamp-irs.prevTimestamp),
+			irs.cptr,
+			&irView,
+		))
+		if Success == err {
+			irs.prevTimestamp = event.Timestamp
+		}
+	}
+	runtime.KeepAlive(serializer)
+	if Success != err {
+		return nil, err
+	}
+	return unsafe.Slice((*byte)(irView.m_data), irView.m_size), nil
+}
@@ -0,0 +1,36 @@
+//go:build !cgo || purego
+
+package ir
+
+import (
+	"github.com/y-scope/clp-ffi-go/ffi"
+)
+
+// EightByteSerializer creates and returns a new Serializer that writes eight
+// byte encoded CLP IR and serializes a IR preamble into a BufView using it. On
+// error returns:
+//   - nil Serializer
+//   - nil BufView
+//   - [IrError] error: CLP failed to successfully serialize
+func EightByteSerializer(
+	tsPattern string,
+	tsPatternSyntax string,
+	timeZoneId string,
+) (Serializer, BufView, error) {
+	return goEightByteSerializer(tsPattern, tsPatternSyntax, timeZoneId)
+}
+
+// FourByteSerializer creates and returns a new Serializer that writes four byte
+// encoded CLP IR and serializes a IR preamble into a BufView using it. On error
+// returns:
+//   - nil Serializer
+//   - nil BufView
+//   - [IrError] error: CLP failed to successfully serialize
+func FourByteSerializer(
+	tsPattern string,
+	tsPatternSyntax string,
+	timeZoneId string,
+	referenceTs ffi.EpochTimeMs,
+) (Serializer, BufView, error) {
+	return goFourByteSerializer(tsPattern, tsPatternSyntax, timeZoneId, referenceTs)
+}
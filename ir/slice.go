@@ -0,0 +1,113 @@
+package ir
+
+import (
+	"bufio"
+	"io"
+	"time"
+
+	"github.com/y-scope/clp-ffi-go/search"
+)
+
+// Slice reads the IR stream from r and writes the log events within
+// timeInterval to w as a new, standalone IR stream. Log events are copied in
+// their encoded form, without decoding their log messages. Reading stops as
+// soon as a log event with a timestamp past timeInterval is found, so log
+// events after it are never copied (even if they are within timeInterval). The
+// new stream uses the same encoding, [TimestampInfo], and user-defined metadata
+// as the original, and each copied log event keeps its UTC offset. For four
+// byte encoded IR, its reference timestamp is the timestamp of the first copied
+// log event (or the original reference timestamp if none are copied).
+// On error returns:
+//   - [IrError] error: deserializing or serializing failed
+//   - key-value pair IR stream error: r is not an unstructured IR stream
+//   - [io.ErrUnexpectedEOF] error: r ended before the IR stream EOF tag
+//   - error propagated from [io.Reader.Read] or [io.Writer.Write]
+func Slice(r io.Reader, w io.Writer, timeInterval search.TimestampInterval) error {
+	reader, err := newReader(r, ReaderOptions{}, goDeserializePreamble)
+	if nil != err {
+		return err
+	}
+	defer reader.Close()
+	bw := bufio.NewWriter(w)
+
+	switch deserializer := reader.Deserializer.(type) {
+	case *goDeserializer[EightByteEncoding]:
+		err = slice(reader, deserializer, bw, timeInterval)
+	case *goDeserializer[FourByteEncoding]:
+		err = slice(reader, deserializer, bw, timeInterval)
+	default:
+		err = errKVStream
+	}
+	if nil != err {
+		return err
+	}
+	return bw.Flush()
+}
+
+// slice implements [Slice] for an encoding of T. The preamble is only written
+// once the first log event to copy (or the end of the slice) is found, as it
+// may need the first timestamp.
+func slice[T EightByteEncoding | FourByteEncoding](
+	reader *Reader,
+	deserializer *goDeserializer[T],
+	bw *bufio.Writer,
+	timeInterval search.TimestampInterval,
+) error {
+	serializer := goSerializer[T]{tsInfo: deserializer.TimestampInfo()}
+	refTs, isFourByte := fourByteTimestamp(deserializer)
+	writePreamble := func() error {
+		serializer.prevTimestamp = refTs
+		preamble, err := appendPreamble(
+			nil,
+			isFourByte,
+			serializer.tsInfo,
+			refTs,
+			deserializer.Metadata(),
+		)
+		if nil != err {
+			return err
+		}
+		_, err = bw.Write(preamble)
+		return err
+	}
+
+	var utcOffset time.Duration
+	for numEvents := 0; ; {
+		msg, timestamp, err := readEncodedLogEvent(reader, deserializer)
+		if EndOfIr == err || (nil == err && timeInterval.Upper <= timestamp) {
+			if 0 == numEvents {
+				if err = writePreamble(); nil != err {
+					return err
+				}
+			}
+			return bw.WriteByte(tagEof)
+		}
+		if nil != err {
+			return err
+		}
+		if timeInterval.Lower > timestamp {
+			continue
+		}
+
+		if 0 == numEvents {
+			refTs = timestamp
+			if err = writePreamble(); nil != err {
+				return err
+			}
+		}
+		if utcOffset != deserializer.UtcOffset() {
+			utcOffset = deserializer.UtcOffset()
+			if _, err = bw.Write(appendUtcOffsetChange(nil, utcOffset)); nil != err {
+				return err
+			}
+		}
+		irView, err := serializer.serializeEncodedLogEvent(msg, timestamp)
+		if nil != err {
+			return err
+		}
+		if _, err = bw.Write(irView); nil != err {
+			return err
+		}
+		numEvents++
+	}
+}
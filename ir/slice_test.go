@@ -0,0 +1,68 @@
+package ir
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+	"github.com/y-scope/clp-ffi-go/search"
+)
+
+func TestSlice(t *testing.T) {
+	events := []ffi.LogEvent{
+		{LogMessage: "before 1", Timestamp: 1000},
+		{LogMessage: "first id=2147483648", Timestamp: 2000},
+		{LogMessage: "out of order", Timestamp: 1500},
+		{LogMessage: "second 12.5", Timestamp: 2500},
+		{LogMessage: "last", Timestamp: 3999},
+		{LogMessage: "upper bound", Timestamp: 4000},
+		{LogMessage: "after upper bound", Timestamp: 3000},
+	}
+	expected := []ffi.LogEvent{events[1], events[3], events[4]}
+	interval := search.TimestampInterval{Lower: 2000, Upper: 4000}
+	tsInfo := TimestampInfo{"%Y", "java", "UTC"}
+
+	for _, encoding := range []Encoding{EncodingEightByte, EncodingFourByte} {
+		sourceIr := serializeEncodedTestStream(t, encoding, tsInfo, events)
+		var sliceIr bytes.Buffer
+		if err := Slice(bytes.NewReader(sourceIr), &sliceIr, interval); nil != err {
+			t.Fatalf("Slice failed: %v", err)
+		}
+
+		irreader, err := NewReader(&sliceIr)
+		if nil != err {
+			t.Fatalf("NewReader failed: %v", err)
+		}
+		if tsInfo != irreader.TimestampInfo() {
+			t.Fatalf("Slice wrong TimestampInfo: %v != %v", irreader.TimestampInfo(), tsInfo)
+		}
+		refTs, isFourByte := fourByteTimestamp(irreader.Deserializer)
+		if (EncodingFourByte == encoding) != isFourByte {
+			t.Fatalf("Slice wrong encoding: %v", encoding)
+		}
+		if isFourByte && expected[0].Timestamp != refTs {
+			t.Fatalf("Slice wrong reference timestamp: %v != %v", refTs, expected[0].Timestamp)
+		}
+		for _, event := range expected {
+			assertIrLogEvent(t, &sliceIr, irreader, event)
+		}
+		assertEndOfIr(t, &sliceIr, irreader)
+		irreader.Close()
+	}
+}
+
+func TestSliceNoEvents(t *testing.T) {
+	events := []ffi.LogEvent{{LogMessage: "outside", Timestamp: 1000}}
+	interval := search.TimestampInterval{Lower: 2000, Upper: 4000}
+	sourceIr := serializeEncodedTestStream(t, EncodingFourByte, TimestampInfo{}, events)
+	var sliceIr bytes.Buffer
+	if err := Slice(bytes.NewReader(sourceIr), &sliceIr, interval); nil != err {
+		t.Fatalf("Slice failed: %v", err)
+	}
+	irreader, err := NewReader(&sliceIr)
+	if nil != err {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer irreader.Close()
+	assertEndOfIr(t, &sliceIr, irreader)
+}
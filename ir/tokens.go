@@ -0,0 +1,123 @@
+package ir
+
+import (
+	"strconv"
+	"strings"
+)
+
+// TokenKind is the type of a [Token] in an encoded log message.
+//
+//go:generate stringer -type=TokenKind
+type TokenKind int
+
+const (
+	StaticText TokenKind = iota
+	IntegerVar
+	FloatVar
+	DictionaryVar
+)
+
+// A Token is a single component of an encoded log message: either a run of
+// static text from the logtype or one of the message's variables. Text always
+// contains the token's text as it appears in the decoded log message. For an
+// IntegerVar, Int contains its value and for a FloatVar, Float contains its
+// value. A DictionaryVar's value is its Text.
+type Token struct {
+	Kind  TokenKind
+	Text  string
+	Int   int64
+	Float float64
+}
+
+// Tokens splits the log message into an ordered sequence of tokens, such that
+// concatenating the Text of every token produces the decoded log message.
+// Consecutive static text (including escaped characters) is returned as a
+// single token. Tokens of static text and dictionary variables may reference
+// the memory of msg, so if msg is a view the tokens are only valid as long as
+// the view is. On error returns:
+//   - nil []Token
+//   - [DecodeError] error: the logtype's placeholders are inconsistent with
+//     the message's variables
+func (msg LogMessage[T]) Tokens() ([]Token, error) {
+	var tokens []Token
+	var static strings.Builder
+	staticBegin := 0
+	varIdx := 0
+	dictVarIdx := 0
+	dictVarBegin := int32(0)
+
+	// appendStatic adds the static text preceding logtype[end] as a token,
+	// only allocating if the static text contained escaped characters.
+	appendStatic := func(end int) {
+		text := msg.Logtype[staticBegin:end]
+		if 0 < static.Len() {
+			static.WriteString(text)
+			text = static.String()
+			static.Reset()
+		}
+		if 0 < len(text) {
+			tokens = append(tokens, Token{Kind: StaticText, Text: text})
+		}
+	}
+
+	for i := 0; i < len(msg.Logtype); i++ {
+		switch msg.Logtype[i] {
+		case placeholderInteger:
+			if varIdx >= len(msg.Vars) {
+				return nil, DecodeError
+			}
+			appendStatic(i)
+			value := int64(msg.Vars[varIdx])
+			tokens = append(tokens, Token{
+				Kind: IntegerVar,
+				Text: decodeIntegerVar(msg.Vars[varIdx]),
+				Int:  value,
+			})
+			varIdx++
+		case placeholderFloat:
+			if varIdx >= len(msg.Vars) {
+				return nil, DecodeError
+			}
+			appendStatic(i)
+			text, err := decodeFloatVar(msg.Vars[varIdx])
+			if nil != err {
+				return nil, err
+			}
+			value, err := strconv.ParseFloat(text, 64)
+			if nil != err {
+				return nil, DecodeError
+			}
+			tokens = append(tokens, Token{Kind: FloatVar, Text: text, Float: value})
+			varIdx++
+		case placeholderDictionary:
+			if dictVarIdx >= len(msg.DictVarEndOffsets) {
+				return nil, DecodeError
+			}
+			dictVarEnd := msg.DictVarEndOffsets[dictVarIdx]
+			if dictVarEnd < dictVarBegin || int(dictVarEnd) > len(msg.DictVars) {
+				return nil, DecodeError
+			}
+			appendStatic(i)
+			tokens = append(tokens, Token{
+				Kind: DictionaryVar,
+				Text: msg.DictVars[dictVarBegin:dictVarEnd],
+			})
+			dictVarBegin = dictVarEnd
+			dictVarIdx++
+		case placeholderEscape:
+			if i == len(msg.Logtype)-1 {
+				return nil, DecodeError
+			}
+			// Drop the escape character and skip over the escaped character
+			static.WriteString(msg.Logtype[staticBegin:i])
+			i++
+			staticBegin = i
+			continue
+		default:
+			continue
+		}
+		staticBegin = i + 1
+	}
+	appendStatic(len(msg.Logtype))
+	return tokens, nil
+}
@@ -0,0 +1,272 @@
+package ir
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"time"
+	"unsafe"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+)
+
+// Transcode reads the IR stream from r and writes it to w using the target
+// encoding. Each log event is converted from its encoded form: encoded
+// variables are converted directly between encodings (becoming dictionary
+// variables if they do not fit in the target encoding) and dictionary
+// variables are encoded if the target encoding can represent them. The output
+// is identical to serializing each decoded log event with the target encoding.
+// The preamble's [TimestampInfo], user-defined metadata, and the UTC offset of
+// each log event are carried over unchanged. When transcoding into four byte
+// encoded IR, the reference timestamp is kept if the source is four byte
+// encoded, and otherwise is the timestamp of the first log event. Transcoding
+// into the source's encoding rewrites the stream unchanged. On error returns:
+//   - invalid encoding error: target is not a valid [Encoding]
+//   - [IrError] error: deserializing or serializing failed
+//   - key-value pair IR stream error: r is not an unstructured IR stream
+//   - [io.ErrUnexpectedEOF] error: r ended before the IR stream EOF tag
+//   - error propagated from [io.Reader.Read] or [io.Writer.Write]
+func Transcode(r io.Reader, w io.Writer, target Encoding) error {
+	if EncodingEightByte != target && EncodingFourByte != target {
+		return fmt.Errorf("invalid encoding: %v", target)
+	}
+	reader, err := newReader(r, ReaderOptions{}, goDeserializePreamble)
+	if nil != err {
+		return err
+	}
+	defer reader.Close()
+	bw := bufio.NewWriter(w)
+
+	switch deserializer := reader.Deserializer.(type) {
+	case *goDeserializer[EightByteEncoding]:
+		if EncodingFourByte == target {
+			err = transcode(reader, deserializer, &goSerializer[FourByteEncoding]{}, bw)
+		} else {
+			err = transcode(reader, deserializer, &goSerializer[EightByteEncoding]{}, bw)
+		}
+	case *goDeserializer[FourByteEncoding]:
+		if EncodingFourByte == target {
+			err = transcode(reader, deserializer, &goSerializer[FourByteEncoding]{}, bw)
+		} else {
+			err = transcode(reader, deserializer, &goSerializer[EightByteEncoding]{}, bw)
+		}
+	default:
+		err = errKVStream
+	}
+	if nil != err {
+		return err
+	}
+	return bw.Flush()
+}
+
+// transcode implements [Transcode] for a source encoding of S and a target
+// encoding of D. The preamble is only written once the first log event (or the
+// end of the stream) is read, as it may need the first timestamp.
+func transcode[
+	S EightByteEncoding | FourByteEncoding,
+	D EightByteEncoding | FourByteEncoding,
+](
+	reader *Reader,
+	deserializer *goDeserializer[S],
+	serializer *goSerializer[D],
+	bw *bufio.Writer,
+) error {
+	var d D
+	_, isTargetFourByte := any(d).(FourByteEncoding)
+	serializer.tsInfo = deserializer.TimestampInfo()
+	refTs, isSourceFourByte := fourByteTimestamp(deserializer)
+
+	var logtype []byte
+	var vars []D
+	var dictVars []byte
+	var dictVarEndOffsets []int32
+	var utcOffset time.Duration
+	for numEvents := 0; ; numEvents++ {
+		msg, timestamp, err := readEncodedLogEvent(reader, deserializer)
+		if 0 == numEvents {
+			if nil == err && false == isSourceFourByte {
+				refTs = timestamp
+			}
+			serializer.prevTimestamp = refTs
+			preamble, err := appendPreamble(
+				nil,
+				isTargetFourByte,
+				serializer.tsInfo,
+				refTs,
+				deserializer.Metadata(),
+			)
+			if nil != err {
+				return err
+			}
+			if _, err = bw.Write(preamble); nil != err {
+				return err
+			}
+		}
+		if EndOfIr == err {
+			return bw.WriteByte(tagEof)
+		}
+		if nil != err {
+			return err
+		}
+		if utcOffset != deserializer.UtcOffset() {
+			utcOffset = deserializer.UtcOffset()
+			if _, err = bw.Write(appendUtcOffsetChange(nil, utcOffset)); nil != err {
+				return err
+			}
+		}
+
+		logtype, vars, dictVars, dictVarEndOffsets, err = transcodeLogMessage(
+			msg,
+			logtype[:0],
+			vars[:0],
+			dictVars[:0],
+			dictVarEndOffsets[:0],
+		)
+		if nil != err {
+			return err
+		}
+		irView, err := serializer.serializeEncodedLogEvent(LogMessage[D]{
+			Logtype:           unsafe.String(unsafe.SliceData(logtype), len(logtype)),
+			Vars:              vars,
+			DictVars:          unsafe.String(unsafe.SliceData(dictVars), len(dictVars)),
+			DictVarEndOffsets: dictVarEndOffsets,
+		}, timestamp)
+		if nil != err {
+			return err
+		}
+		if _, err = bw.Write(irView); nil != err {
+			return err
+		}
+	}
+}
+
+// readEncodedLogEvent reads the next log event from reader's IR stream without
+// decoding its log message, using deserializer (the Reader's Deserializer, or
+// one in the same state). The underlying buffer will grow if it is too small
+// to contain the next log event. On error returns:
+//   - 0 value LogMessage, 0 timestamp
+//   - [IrError] error: CLP failed to successfully deserialize
+//   - [EndOfIr] error: CLP found the IR stream EOF tag
+//   - [*EventTooLargeError] error: the next log event does not fit in the
+//     maximum buffer size (see [ReaderOptions])
+//   - [io.ErrUnexpectedEOF] error: the IR stream ended before its EOF tag
+//   - error propagated from [io.Reader.Read]
+func readEncodedLogEvent[T EightByteEncoding | FourByteEncoding](
+	reader *Reader,
+	deserializer *goDeserializer[T],
+) (LogMessage[T], ffi.EpochTimeMs, error) {
+	for {
+		end, err := reader.checkedEnd()
+		if nil != err {
+			return LogMessage[T]{}, 0, err
+		}
+		msg, timestamp, pos, err := deserializer.deserializeEncodedLogEvent(
+			reader.buf[reader.start:end],
+		)
+		if IncompleteIr != err {
+			if nil != err {
+				return LogMessage[T]{}, 0, err
+			}
+			reader.eventOffset = reader.Offset()
+			reader.start += pos
+			return msg, timestamp, nil
+		}
+		if _, err := reader.fillBuf(); nil != err {
+			return LogMessage[T]{}, 0, err
+		}
+	}
+}
+
+// transcodeLogMessage converts msg from encoding S into encoding D, appending
+// its logtype, encoded variables, dictionary variables, and dictionary
+// variable end offsets to the given buffers, and returns the extended buffers.
+// The result is identical to encoding the decoded log message with D: integer
+// and float variables too large for D become dictionary variables, and
+// dictionary variables that D can represent are encoded. On error returns:
+//   - undefined buffers
+//   - [DecodeError] error: the logtype's placeholders are inconsistent with
+//     the message's variables
+func transcodeLogMessage[
+	S EightByteEncoding | FourByteEncoding,
+	D EightByteEncoding | FourByteEncoding,
+](
+	msg LogMessage[S],
+	logtype []byte,
+	vars []D,
+	dictVars []byte,
+	dictVarEndOffsets []int32,
+) ([]byte, []D, []byte, []int32, error) {
+	appendDictVar := func(dictVar string) {
+		logtype = append(logtype, placeholderDictionary)
+		dictVars = append(dictVars, dictVar...)
+		dictVarEndOffsets = append(dictVarEndOffsets, int32(len(dictVars)))
+	}
+	varIdx := 0
+	dictVarIdx := 0
+	dictVarBegin := int32(0)
+	for i := 0; i < len(msg.Logtype); i++ {
+		switch c := msg.Logtype[i]; c {
+		case placeholderInteger:
+			if varIdx >= len(msg.Vars) {
+				return logtype, vars, dictVars, dictVarEndOffsets, DecodeError
+			}
+			value := int64(msg.Vars[varIdx])
+			varIdx++
+			if int64(D(value)) == value {
+				logtype = append(logtype, placeholderInteger)
+				vars = append(vars, D(value))
+			} else {
+				appendDictVar(decodeIntegerVar(value))
+			}
+		case placeholderFloat:
+			if varIdx >= len(msg.Vars) {
+				return logtype, vars, dictVars, dictVarEndOffsets, DecodeError
+			}
+			encodedVar := msg.Vars[varIdx]
+			varIdx++
+			isNegative, digits, numDigits, decimalPointPos := unpackFloatVar(encodedVar)
+			if numDigits < decimalPointPos {
+				return logtype, vars, dictVars, dictVarEndOffsets, DecodeError
+			}
+			if v, ok := packFloatVar[D](isNegative, digits, numDigits, decimalPointPos); ok {
+				logtype = append(logtype, placeholderFloat)
+				vars = append(vars, v)
+			} else {
+				text, err := decodeFloatVar(encodedVar)
+				if nil != err {
+					return logtype, vars, dictVars, dictVarEndOffsets, err
+				}
+				appendDictVar(text)
+			}
+		case placeholderDictionary:
+			if dictVarIdx >= len(msg.DictVarEndOffsets) {
+				return logtype, vars, dictVars, dictVarEndOffsets, DecodeError
+			}
+			dictVarEnd := msg.DictVarEndOffsets[dictVarIdx]
+			if dictVarEnd < dictVarBegin || int(dictVarEnd) > len(msg.DictVars) {
+				return logtype, vars, dictVars, dictVarEndOffsets, DecodeError
+			}
+			dictVar := msg.DictVars[dictVarBegin:dictVarEnd]
+			dictVarBegin = dictVarEnd
+			dictVarIdx++
+			if v, ok := encodeFloatVar[D](dictVar); ok {
+				logtype = append(logtype, placeholderFloat)
+				vars = append(vars, v)
+			} else if v, ok := encodeIntegerVar[D](dictVar); ok {
+				logtype = append(logtype, placeholderInteger)
+				vars = append(vars, v)
+			} else {
+				appendDictVar(dictVar)
+			}
+		case placeholderEscape:
+			if i == len(msg.Logtype)-1 {
+				return logtype, vars, dictVars, dictVarEndOffsets, DecodeError
+			}
+			i++
+			logtype = append(logtype, c, msg.Logtype[i])
+		default:
+			logtype = append(logtype, c)
+		}
+	}
+	return logtype, vars, dictVars, dictVarEndOffsets, nil
+}
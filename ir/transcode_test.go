@@ -0,0 +1,182 @@
+package ir
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+)
+
+// Messages with variables that can only be encoded by eight byte encoding, so
+// they must change between encoded and dictionary variables when transcoding.
+var transcodeTestMessages = []ffi.LogMessage{
+	"int32 bounds 2147483647 -2147483648 exceeded by 2147483648 -2147483649",
+	"float digits 3355.4431 1234567.8 exceeded by 3355.4432 12345678.9 -9.007199254740993",
+	"escaped \x11 \x12 \x13 \\ placeholders with 4294967296 vars",
+	"",
+}
+
+var transcodeTestTimestampInfo = TimestampInfo{"%Y-%m-%d %H:%M:%S", "java", "America/Toronto"}
+
+func TestTranscode(t *testing.T) {
+	timestamps := []ffi.EpochTimeMs{1700000000000, 1700000000001, 1699999999000, 1700000100000}
+	var events []ffi.LogEvent
+	for i, msg := range append(transcodeTestMessages, encoderTestMessages...) {
+		events = append(events, ffi.LogEvent{
+			LogMessage: msg,
+			Timestamp:  timestamps[i%len(timestamps)] + ffi.EpochTimeMs(i)<<33,
+		})
+	}
+	for _, source := range []Encoding{EncodingEightByte, EncodingFourByte} {
+		for _, target := range []Encoding{EncodingEightByte, EncodingFourByte} {
+			t.Run(source.String()+"To"+target.String(), func(t *testing.T) {
+				testTranscode(t, source, target, events)
+			})
+			t.Run(source.String()+"To"+target.String()+"Empty", func(t *testing.T) {
+				testTranscode(t, source, target, nil)
+			})
+		}
+	}
+}
+
+func testTranscode(t *testing.T, source Encoding, target Encoding, events []ffi.LogEvent) {
+	const sourceRefTs = 1600000000000
+	tsInfo := transcodeTestTimestampInfo
+	var serializer Serializer
+	var preamble BufView
+	if EncodingFourByte == source {
+		serializer, preamble, _ = FourByteSerializer(
+			tsInfo.Pattern,
+			tsInfo.PatternSyntax,
+			tsInfo.TimeZoneId,
+			sourceRefTs,
+		)
+	} else {
+		serializer, preamble, _ = EightByteSerializer(
+			tsInfo.Pattern,
+			tsInfo.PatternSyntax,
+			tsInfo.TimeZoneId,
+		)
+	}
+	sourceIr := serializeTestStream(t, serializer, preamble, events)
+	serializer.Close()
+
+	// Transcoding should be identical to serializing with the target encoding
+	if EncodingFourByte == target {
+		refTs := ffi.EpochTimeMs(sourceRefTs)
+		if EncodingEightByte == source {
+			refTs = 0
+			if 0 < len(events) {
+				refTs = events[0].Timestamp
+			}
+		}
+		serializer, preamble, _ = goFourByteSerializer(
+			tsInfo.Pattern,
+			tsInfo.PatternSyntax,
+			tsInfo.TimeZoneId,
+			refTs,
+		)
+	} else {
+		serializer, preamble, _ = goEightByteSerializer(
+			tsInfo.Pattern,
+			tsInfo.PatternSyntax,
+			tsInfo.TimeZoneId,
+		)
+	}
+	expectedIr := serializeTestStream(t, serializer, preamble, events)
+
+	var targetIr bytes.Buffer
+	if err := Transcode(bytes.NewReader(sourceIr), &targetIr, target); nil != err {
+		t.Fatalf("Transcode failed: %v", err)
+	}
+	if false == bytes.Equal(expectedIr, targetIr.Bytes()) {
+		t.Fatalf("Transcode wrong IR:\n%x\n%x", targetIr.Bytes(), expectedIr)
+	}
+
+	irreader, err := NewReader(&targetIr)
+	if nil != err {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer irreader.Close()
+	if tsInfo != irreader.TimestampInfo() {
+		t.Fatalf("Transcode wrong TimestampInfo: %v != %v", irreader.TimestampInfo(), tsInfo)
+	}
+	for _, event := range events {
+		assertIrLogEvent(t, &targetIr, irreader, event)
+	}
+	assertEndOfIr(t, &targetIr, irreader)
+}
+
+func TestTranscodeErrors(t *testing.T) {
+	if err := Transcode(bytes.NewReader(nil), io.Discard, Encoding(2)); nil == err {
+		t.Fatalf("Transcode succeeded with an invalid encoding")
+	}
+
+	serializer, preamble, _ := FourByteSerializer("", "", "", 0)
+	defer serializer.Close()
+	irStream := serializeTestStream(
+		t,
+		serializer,
+		preamble,
+		[]ffi.LogEvent{{LogMessage: "truncated", Timestamp: 1}},
+	)
+	// Remove the EOF tag and part of the log event
+	irStream = irStream[:len(irStream)-3]
+	err := Transcode(bytes.NewReader(irStream), io.Discard, EncodingEightByte)
+	if false == errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("Transcode of a truncated stream returned: %v", err)
+	}
+}
+
+// serializeTestStream serializes events into a complete IR stream using the
+// given serializer (and its preamble).
+func serializeTestStream(
+	t *testing.T,
+	serializer Serializer,
+	preamble BufView,
+	events []ffi.LogEvent,
+) []byte {
+	irStream := append([]byte{}, preamble...)
+	for _, event := range events {
+		irView, err := serializer.SerializeLogEvent(event)
+		if nil != err {
+			t.Fatalf("SerializeLogEvent failed: %v", err)
+		}
+		irStream = append(irStream, irView...)
+	}
+	return append(irStream, tagEof)
+}
+
+// serializeEncodedTestStream serializes events into a complete IR stream using
+// a new Serializer for encoding, with a reference timestamp of 0.
+func serializeEncodedTestStream(
+	t *testing.T,
+	encoding Encoding,
+	tsInfo TimestampInfo,
+	events []ffi.LogEvent,
+) []byte {
+	var serializer Serializer
+	var preamble BufView
+	var err error
+	if EncodingFourByte == encoding {
+		serializer, preamble, err = FourByteSerializer(
+			tsInfo.Pattern,
+			tsInfo.PatternSyntax,
+			tsInfo.TimeZoneId,
+			0,
+		)
+	} else {
+		serializer, preamble, err = EightByteSerializer(
+			tsInfo.Pattern,
+			tsInfo.PatternSyntax,
+			tsInfo.TimeZoneId,
+		)
+	}
+	if nil != err {
+		t.Fatalf("Serializer construction failed: %v", err)
+	}
+	defer serializer.Close()
+	return serializeTestStream(t, serializer, preamble, events)
+}
@@ -0,0 +1,46 @@
+package ir
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// A UTC offset change packet records the UTC offset of the log events following
+// it in an IR stream, so a stream can continue across changes to its time
+// zone's offset (e.g. daylight saving time transitions). The offset is stored
+// in seconds as an int64. Log events preceding the first packet have a UTC
+// offset of 0.
+const (
+	utcOffsetChangeSize = 1 + 8
+	maxUtcOffsetSeconds = math.MaxInt64 / int64(time.Second)
+)
+
+// appendUtcOffsetChange appends a UTC offset change packet to irBuf and returns
+// the extended buffer. utcOffset is truncated to whole seconds.
+func appendUtcOffsetChange(irBuf []byte, utcOffset time.Duration) []byte {
+	irBuf = append(irBuf, tagUtcOffsetChange)
+	return binary.BigEndian.AppendUint64(irBuf, uint64(int64(utcOffset/time.Second)))
+}
+
+// readUtcOffsetChanges reads the UTC offset change packets (if any) at the
+// start of irBuf. utcOffset is the UTC offset in effect before irBuf. Returns:
+//   - success: the UTC offset in effect after the packets, the position read
+//     to in irBuf, nil
+//   - error: utcOffset, 0, [IncompleteIr] or [CorruptedIr] error (the offset
+//     cannot be represented as a [time.Duration])
+func readUtcOffsetChanges(irBuf []byte, utcOffset time.Duration) (time.Duration, int, error) {
+	pos := 0
+	for pos < len(irBuf) && tagUtcOffsetChange == irBuf[pos] {
+		if len(irBuf) < pos+utcOffsetChangeSize {
+			return utcOffset, 0, IncompleteIr
+		}
+		seconds := int64(binary.BigEndian.Uint64(irBuf[pos+1:]))
+		pos += utcOffsetChangeSize
+		if -maxUtcOffsetSeconds > seconds || seconds > maxUtcOffsetSeconds {
+			return utcOffset, 0, CorruptedIr
+		}
+		utcOffset = time.Duration(seconds) * time.Second
+	}
+	return utcOffset, pos, nil
+}
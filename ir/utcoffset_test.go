@@ -0,0 +1,284 @@
+package ir
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"testing/iotest"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+	"github.com/y-scope/clp-ffi-go/search"
+)
+
+// utcOffsetTestEvent is a log event and the UTC offset it is expected to be
+// read with.
+type utcOffsetTestEvent struct {
+	event     ffi.LogEvent
+	utcOffset time.Duration
+}
+
+// utcOffsetTestEvents returns log events spanning the daylight saving time
+// transition of America/New_York on 2023-03-12 at 07:00 UTC.
+func utcOffsetTestEvents() []utcOffsetTestEvent {
+	transition := time.Date(2023, 3, 12, 7, 0, 0, 0, time.UTC)
+	event := func(msg string, d time.Duration, utcOffset time.Duration) utcOffsetTestEvent {
+		timestamp := ffi.EpochTimeMs(transition.Add(d).UnixMilli())
+		return utcOffsetTestEvent{ffi.LogEvent{LogMessage: msg, Timestamp: timestamp}, utcOffset}
+	}
+	return []utcOffsetTestEvent{
+		event("request 1 took 12 ms", -time.Hour, -5*time.Hour),
+		event("request 2 took 7 ms", -time.Second, -5*time.Hour),
+		event("request 3 took 9 ms", 0, -4*time.Hour),
+		event("request 4 took 3 ms", time.Hour, -4*time.Hour),
+	}
+}
+
+func TestUtcOffsetWriter(t *testing.T) {
+	location, err := time.LoadLocation("America/New_York")
+	if nil != err {
+		t.Fatalf("time.LoadLocation failed: %v", err)
+	}
+	events := utcOffsetTestEvents()
+	for _, encoding := range []Encoding{EncodingEightByte, EncodingFourByte} {
+		var writer *Writer
+		if EncodingFourByte == encoding {
+			writer, err = NewWriterSize[FourByteEncoding](1024, location.String())
+		} else {
+			writer, err = NewWriterSize[EightByteEncoding](1024, location.String())
+		}
+		if nil != err {
+			t.Fatalf("NewWriterSize failed: %v", err)
+		}
+		writer.TrackUtcOffset(location)
+		for _, e := range events {
+			if _, err = writer.Write(e.event); nil != err {
+				t.Fatalf("Writer.Write failed: %v", err)
+			}
+		}
+		writer.Close()
+		irStream := append([]byte{}, writer.Bytes()...)
+		if n := bytes.Count(irStream, []byte{tagUtcOffsetChange, 0xFF, 0xFF}); 2 != n {
+			t.Fatalf("Wrong number of UTC offset changes: %v", n)
+		}
+
+		for _, irStream := range append([][]byte{irStream}, deriveTestStreams(t, irStream)...) {
+			for _, deserializePreamble := range []func([]byte) (Deserializer, int, error){
+				DeserializePreamble,
+				goDeserializePreamble,
+			} {
+				irreader, err := newReader(
+					iotest.OneByteReader(bytes.NewReader(irStream)),
+					ReaderOptions{BufferSize: 8},
+					deserializePreamble,
+				)
+				if nil != err {
+					t.Fatalf("newReader failed: %v", err)
+				}
+				for _, e := range events {
+					assertIrLogEvent(t, nil, irreader, e.event)
+					if e.utcOffset != irreader.UtcOffset() {
+						t.Fatalf("Wrong UTC offset: %v != %v", irreader.UtcOffset(), e.utcOffset)
+					}
+				}
+				assertEndOfIr(t, nil, irreader)
+				irreader.Close()
+
+				assertUtcOffsetWildcardMatch(t, irStream, deserializePreamble, events)
+				assertUtcOffsetLogEvents(t, irStream, deserializePreamble, events)
+			}
+		}
+	}
+}
+
+// assertUtcOffsetWildcardMatch asserts that searching irStream for the last of
+// events reads it with the correct UTC offset.
+func assertUtcOffsetWildcardMatch(
+	t *testing.T,
+	irStream []byte,
+	deserializePreamble func([]byte) (Deserializer, int, error),
+	events []utcOffsetTestEvent,
+) {
+	last := events[len(events)-1]
+	irreader, err := newReader(
+		iotest.OneByteReader(bytes.NewReader(irStream)),
+		ReaderOptions{BufferSize: 8},
+		deserializePreamble,
+	)
+	if nil != err {
+		t.Fatalf("newReader failed: %v", err)
+	}
+	defer irreader.Close()
+	event, _, err := irreader.ReadToWildcardMatch(
+		[]search.WildcardQuery{search.NewWildcardQuery("*4 took*", true)},
+	)
+	if nil != err {
+		t.Fatalf("ReadToWildcardMatch failed: %v", err)
+	}
+	if last.event.Timestamp != event.Timestamp || last.event.LogMessage != event.LogMessageView {
+		t.Fatalf("ReadToWildcardMatch wrong event: %v", event)
+	}
+	if last.utcOffset != irreader.UtcOffset() {
+		t.Fatalf("Wrong UTC offset: %v != %v", irreader.UtcOffset(), last.utcOffset)
+	}
+}
+
+// assertUtcOffsetLogEvents asserts that deserializing irStream in batches reads
+// events, with the UTC offset of the last log event of each batch.
+func assertUtcOffsetLogEvents(
+	t *testing.T,
+	irStream []byte,
+	deserializePreamble func([]byte) (Deserializer, int, error),
+	events []utcOffsetTestEvent,
+) {
+	deserializer, pos, err := deserializePreamble(irStream)
+	if nil != err {
+		t.Fatalf("deserializePreamble failed: %v", err)
+	}
+	defer deserializer.Close()
+	numEvents := 0
+	for {
+		batch, n, err := deserializer.(BatchDeserializer).DeserializeLogEvents(irStream[pos:], 0)
+		if EndOfIr == err {
+			break
+		}
+		if nil != err || len(events) < numEvents+len(batch) {
+			t.Fatalf("DeserializeLogEvents returned %v events: %v", len(batch), err)
+		}
+		expected := make([]ffi.LogEvent, 0, len(batch))
+		for _, e := range events[numEvents : numEvents+len(batch)] {
+			expected = append(expected, e.event)
+		}
+		assertLogEventViews(t, batch, expected)
+		numEvents += len(batch)
+		if utcOffset := events[numEvents-1].utcOffset; utcOffset != deserializer.UtcOffset() {
+			t.Fatalf("Wrong UTC offset: %v != %v", deserializer.UtcOffset(), utcOffset)
+		}
+		pos += n
+	}
+	if len(events) != numEvents {
+		t.Fatalf("DeserializeLogEvents returned %v log events", numEvents)
+	}
+}
+
+func TestUtcOffsetSerializer(t *testing.T) {
+	seconds := int64(-4 * 60 * 60)
+	expected := binary.BigEndian.AppendUint64([]byte{tagUtcOffsetChange}, uint64(seconds))
+	serializers := make([]Serializer, 0, 4)
+	for _, newSerializer := range []func() (Serializer, BufView, error){
+		func() (Serializer, BufView, error) { return EightByteSerializer("", "", "") },
+		func() (Serializer, BufView, error) { return FourByteSerializer("", "", "", 0) },
+		func() (Serializer, BufView, error) { return goEightByteSerializer("", "", "") },
+		func() (Serializer, BufView, error) { return goFourByteSerializer("", "", "", 0) },
+	} {
+		serializer, _, err := newSerializer()
+		if nil != err {
+			t.Fatalf("Serializer construction failed: %v", err)
+		}
+		defer serializer.Close()
+		serializers = append(serializers, serializer)
+	}
+	for _, serializer := range serializers {
+		offsetSerializer, ok := serializer.(UtcOffsetSerializer)
+		if false == ok {
+			t.Fatalf("%T is not a UtcOffsetSerializer", serializer)
+		}
+		irView, err := offsetSerializer.SerializeUtcOffsetChange(-4*time.Hour - 500*time.Millisecond)
+		if nil != err {
+			t.Fatalf("SerializeUtcOffsetChange failed: %v", err)
+		}
+		if false == bytes.Equal(expected, irView) {
+			t.Fatalf("Wrong UTC offset change IR: %x != %x", irView, expected)
+		}
+	}
+
+	// A Writer's Serializer implemented outside the package may not support
+	// UTC offset changes
+	writer := Writer{Serializer: struct{ Serializer }{serializers[0]}}
+	if _, err := writer.WriteUtcOffset(time.Hour); errNoUtcOffsetChanges != err {
+		t.Fatalf("WriteUtcOffset without a UtcOffsetSerializer returned: %v", err)
+	}
+
+	// A UTC offset too large to be represented as a time.Duration
+	irStream := serializeEncodedTestStream(t, EncodingEightByte, TimestampInfo{}, nil)
+	irStream = append(irStream[:len(irStream)-1], tagUtcOffsetChange, 0x7F)
+	irStream = append(irStream, bytes.Repeat([]byte{0xFF}, 7)...)
+	irreader, err := NewReader(bytes.NewReader(append(irStream, tagEof)))
+	if nil != err {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer irreader.Close()
+	if _, err = irreader.Read(); CorruptedIr != err {
+		t.Fatalf("Read of out of range UTC offset returned: %v", err)
+	}
+}
+
+func TestUtcOffsetMerge(t *testing.T) {
+	offsets := []time.Duration{time.Hour, -2 * time.Hour}
+	srcs := make([]io.Reader, len(offsets))
+	for i, utcOffset := range offsets {
+		writer, err := NewWriterSize[EightByteEncoding](1024, "")
+		if nil != err {
+			t.Fatalf("NewWriterSize failed: %v", err)
+		}
+		if _, err = writer.WriteUtcOffset(utcOffset); nil != err {
+			t.Fatalf("Writer.WriteUtcOffset failed: %v", err)
+		}
+		for ts := i; ts < 4; ts += len(offsets) {
+			event := ffi.LogEvent{LogMessage: "event", Timestamp: ffi.EpochTimeMs(ts)}
+			if _, err = writer.Write(event); nil != err {
+				t.Fatalf("Writer.Write failed: %v", err)
+			}
+		}
+		writer.Close()
+		srcs[i] = bytes.NewReader(append([]byte{}, writer.Bytes()...))
+	}
+	var merged bytes.Buffer
+	if err := Merge(&merged, srcs...); nil != err {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	irreader, err := NewReader(&merged)
+	if nil != err {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	defer irreader.Close()
+	for ts := 0; ts < 4; ts++ {
+		event := ffi.LogEvent{LogMessage: "event", Timestamp: ffi.EpochTimeMs(ts)}
+		assertIrLogEvent(t, nil, irreader, event)
+		if offsets[ts%len(offsets)] != irreader.UtcOffset() {
+			t.Fatalf("Wrong UTC offset for %v: %v", ts, irreader.UtcOffset())
+		}
+	}
+	assertEndOfIr(t, nil, irreader)
+}
+
+func TestKVUtcOffset(t *testing.T) {
+	serializer, irStream := newTestKVSerializer(t, EncodingFourByte)
+	defer serializer.Close()
+	for i, utcOffset := range []time.Duration{0, 2 * time.Hour} {
+		irStream = appendUtcOffsetChange(irStream, utcOffset)
+		irView, err := serializer.SerializeKVEvent(map[string]any{"i": i})
+		if nil != err {
+			t.Fatalf("SerializeKVEvent failed: %v", err)
+		}
+		irStream = append(irStream, irView...)
+	}
+	irStream = append(irStream, tagEof)
+
+	irreader, err := NewReaderSize(iotest.OneByteReader(bytes.NewReader(irStream)), 8)
+	if nil != err {
+		t.Fatalf("NewReaderSize failed: %v", err)
+	}
+	defer irreader.Close()
+	for i, utcOffset := range []time.Duration{0, 2 * time.Hour} {
+		event, err := irreader.ReadKVEvent()
+		if nil != err {
+			t.Fatalf("ReadKVEvent failed: %v", err)
+		}
+		if int64(i) != event["i"] || utcOffset != irreader.UtcOffset() {
+			t.Fatalf("Wrong event or UTC offset: %v, %v", event, irreader.UtcOffset())
+		}
+	}
+}
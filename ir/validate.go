@@ -0,0 +1,133 @@
+package ir
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+)
+
+// A Report describes the integrity of an IR stream, as found by [Validate].
+//   - Encoding, Version, TimestampInfo, Metadata: the IR stream's preamble (0
+//     value if the preamble is corrupted)
+//   - NumEvents: the number of log events read before the end of the stream
+//     (or the first corruption)
+//   - MinTimestamp, MaxTimestamp: the range of the log events' timestamps (0
+//     if there are no log events)
+//   - NonMonotonicTimestamps: the number of log events with a timestamp
+//     smaller than the previous log event's
+//   - HasEof: whether the IR stream ends with its EOF tag
+//   - TrailingBytes: the number of bytes following the EOF tag
+//   - CorruptionOffset: the byte offset in the IR stream of the preamble or log
+//     event that could not be read (-1 if there is none)
+//   - Corruption: the [IrError] describing why the IR stream could not be read
+//     at CorruptionOffset ([Success] if there is no corruption). A truncated IR
+//     stream is reported as [IncompleteIr].
+type Report struct {
+	Encoding               Encoding
+	Version                string
+	TimestampInfo          TimestampInfo
+	Metadata               map[string]any
+	NumEvents              int
+	MinTimestamp           ffi.EpochTimeMs
+	MaxTimestamp           ffi.EpochTimeMs
+	NonMonotonicTimestamps int
+	HasEof                 bool
+	TrailingBytes          int64
+	CorruptionOffset       int64
+	Corruption             IrError
+}
+
+// Valid reports whether the IR stream is complete and free of corruption: it
+// has an EOF tag, no trailing bytes, and its version is supported (see
+// [ValidateVersion]). Non-monotonic timestamps do not make a stream invalid.
+func (report Report) Valid() bool {
+	return Success == report.Corruption &&
+		report.HasEof &&
+		0 == report.TrailingBytes &&
+		nil == ValidateVersion(report.Version)
+}
+
+// Validate reads the entire IR stream from r and reports its integrity. As
+// with [OpenReader], an IR stream compressed with zstd or gzip is detected
+// from its magic number and decompressed, and offsets are offsets in the
+// decompressed IR stream. The stream is read in Go and each log event is fully
+// deserialized, so any corruption (including a truncated stream) is found and
+// reported in the Report, along with its byte offset. Reading stops at the
+// first corruption. On error returns:
+//   - the Report of the IR stream read before the error
+//   - error propagated from [io.Reader.Read] or the decompressor
+func Validate(r io.Reader) (Report, error) {
+	report := Report{CorruptionOffset: -1}
+	opts := ReaderOptions{VersionPolicy: VersionPolicyIgnore}
+	reader, err := openReader(r, opts, goDeserializePreamble)
+	if nil != err {
+		if corruption, ok := asCorruption(err); ok {
+			report.CorruptionOffset = 0
+			report.Corruption = corruption
+			return report, nil
+		}
+		return report, err
+	}
+	defer reader.Close()
+	report.Encoding = reader.Encoding()
+	report.Version = reader.Version()
+	report.TimestampInfo = reader.TimestampInfo()
+	report.Metadata = reader.Metadata()
+
+	prevTimestamp := ffi.EpochTimeMs(math.MinInt64)
+	for {
+		event, err := reader.Read()
+		if EndOfIr == err {
+			break
+		}
+		if nil != err {
+			if corruption, ok := asCorruption(err); ok {
+				report.CorruptionOffset = reader.Offset()
+				report.Corruption = corruption
+				return report, nil
+			}
+			return report, err
+		}
+
+		if 0 == report.NumEvents || event.Timestamp < report.MinTimestamp {
+			report.MinTimestamp = event.Timestamp
+		}
+		if 0 == report.NumEvents || event.Timestamp > report.MaxTimestamp {
+			report.MaxTimestamp = event.Timestamp
+		}
+		if event.Timestamp < prevTimestamp {
+			report.NonMonotonicTimestamps++
+		}
+		prevTimestamp = event.Timestamp
+		report.NumEvents++
+	}
+
+	report.HasEof = true
+	report.TrailingBytes = int64(reader.end - reader.start - 1)
+	n, err := io.Copy(io.Discard, reader.ioReader)
+	report.TrailingBytes += n
+	return report, err
+}
+
+// asCorruption returns the [IrError] describing err, if err was caused by the
+// IR stream being corrupted or truncated rather than by reading it. Returns:
+//   - corruption: the IrError, true
+//   - otherwise: [Success], false
+func asCorruption(err error) (IrError, bool) {
+	var irErr IrError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &irErr):
+		return irErr, true
+	case io.ErrUnexpectedEOF == err:
+		return IncompleteIr, true
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		// The preamble's metadata is not valid JSON
+		return CorruptedIr, true
+	}
+	return Success, false
+}
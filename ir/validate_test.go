@@ -0,0 +1,125 @@
+package ir
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+)
+
+// serializeValidateTestStream returns an IR stream of events along with the
+// byte offset of each log event in it.
+func serializeValidateTestStream(t *testing.T, events []ffi.LogEvent) ([]byte, []int64) {
+	serializer, preamble, err := FourByteSerializerWithMetadata(
+		"",
+		"",
+		"UTC",
+		100,
+		map[string]any{"service": "api"},
+	)
+	if nil != err {
+		t.Fatalf("FourByteSerializerWithMetadata failed: %v", err)
+	}
+	defer serializer.Close()
+	irStream := append([]byte{}, preamble...)
+	offsets := make([]int64, 0, len(events))
+	for _, event := range events {
+		offsets = append(offsets, int64(len(irStream)))
+		irView, err := serializer.SerializeLogEvent(event)
+		if nil != err {
+			t.Fatalf("SerializeLogEvent failed: %v", err)
+		}
+		irStream = append(irStream, irView...)
+	}
+	return append(irStream, tagEof), offsets
+}
+
+func TestValidate(t *testing.T) {
+	events := []ffi.LogEvent{
+		{LogMessage: "started in 12 ms", Timestamp: 100},
+		{LogMessage: "late event", Timestamp: 50},
+		{LogMessage: "request id=42 done", Timestamp: 300},
+		{LogMessage: "earlier event", Timestamp: 200},
+	}
+	irStream, offsets := serializeValidateTestStream(t, events)
+
+	expected := Report{
+		Encoding:               EncodingFourByte,
+		Version:                metadataVersionValue,
+		TimestampInfo:          TimestampInfo{TimeZoneId: "UTC"},
+		Metadata:               map[string]any{"service": "api"},
+		NumEvents:              len(events),
+		MinTimestamp:           50,
+		MaxTimestamp:           300,
+		NonMonotonicTimestamps: 2,
+		HasEof:                 true,
+		CorruptionOffset:       -1,
+	}
+	assertReport(t, irStream, expected, true)
+
+	var zstdBuf bytes.Buffer
+	zstdWriter, err := zstd.NewWriter(&zstdBuf)
+	if nil != err {
+		t.Fatalf("zstd.NewWriter failed: %v", err)
+	}
+	zstdWriter.Write(irStream)
+	zstdWriter.Close()
+	assertReport(t, zstdBuf.Bytes(), expected, true)
+
+	var gzipBuf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&gzipBuf)
+	gzipWriter.Write(irStream)
+	gzipWriter.Close()
+	assertReport(t, gzipBuf.Bytes(), expected, true)
+
+	trailing := expected
+	trailing.TrailingBytes = 3
+	assertReport(t, append(irStream[:len(irStream):len(irStream)], 1, 2, 3), trailing, false)
+
+	truncated := expected
+	truncated.NumEvents = 2
+	truncated.MaxTimestamp = 100
+	truncated.NonMonotonicTimestamps = 1
+	truncated.HasEof = false
+	truncated.CorruptionOffset = offsets[2]
+	truncated.Corruption = IncompleteIr
+	assertReport(t, irStream[:offsets[3]-1], truncated, false)
+
+	corrupted := truncated
+	corrupted.Corruption = CorruptedIr
+	corruptedStream := append([]byte{}, irStream...)
+	corruptedStream[offsets[2]] = 0xFF
+	assertReport(t, corruptedStream, corrupted, false)
+
+	preambleCorrupted := Report{CorruptionOffset: 0, Corruption: CorruptedIr}
+	assertReport(t, append([]byte{0xFF}, irStream[1:]...), preambleCorrupted, false)
+	preambleTruncated := Report{CorruptionOffset: 0, Corruption: IncompleteIr}
+	assertReport(t, irStream[:offsets[0]-1], preambleTruncated, false)
+	assertReport(t, nil, preambleTruncated, false)
+}
+
+// assertReport asserts that Validate returns expected for irStream, both when
+// reading it at once and one byte at a time.
+func assertReport(t *testing.T, irStream []byte, expected Report, valid bool) {
+	for _, r := range []io.Reader{
+		bytes.NewReader(irStream),
+		iotest.OneByteReader(bytes.NewReader(irStream)),
+	} {
+		report, err := Validate(r)
+		if nil != err {
+			t.Fatalf("Validate failed: %v", err)
+		}
+		if false == reflect.DeepEqual(expected, report) {
+			t.Fatalf("Wrong report:\n%+v\n%+v", report, expected)
+		}
+		if valid != report.Valid() {
+			t.Fatalf("Report.Valid returned %v", report.Valid())
+		}
+	}
+}
@@ -0,0 +1,443 @@
+package ir
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Bit masks and sizes of the fields packed into an encoded float variable.
+// Must match the c++ equivalents in clp/ffi/encoding_methods.hpp.
+const (
+	eightByteFloatDigitsMask uint64 = (1 << 54) - 1
+	fourByteFloatDigitsMask  uint32 = (1 << 25) - 1
+)
+
+// Maximum number of digits in a float variable that can be encoded, for each
+// encoding.
+const (
+	eightByteFloatMaxDigits = 16
+	fourByteFloatMaxDigits  = 8
+)
+
+// encodeMessage encodes message, appending its logtype, encoded variables,
+// dictionary variables, and dictionary variable end offsets to the given
+// buffers, and returns the extended buffers. Mirrors the c++ equivalent
+// clp::ffi::encode_message.
+func encodeMessage[T EightByteEncoding | FourByteEncoding](
+	message string,
+	logtype []byte,
+	vars []T,
+	dictVars []byte,
+	dictVarEndOffsets []int32,
+) ([]byte, []T, []byte, []int32) {
+	constantBegin := 0
+	varBegin := 0
+	varEnd := 0
+	for {
+		var found bool
+		varBegin, varEnd, found = boundsOfNextVar(message, varEnd)
+		if false == found {
+			break
+		}
+		logtype = appendEscapedConstant(logtype, message[constantBegin:varBegin])
+		constantBegin = varEnd
+
+		varStr := message[varBegin:varEnd]
+		if encodedVar, ok := encodeFloatVar[T](varStr); ok {
+			logtype = append(logtype, placeholderFloat)
+			vars = append(vars, encodedVar)
+		} else if encodedVar, ok := encodeIntegerVar[T](varStr); ok {
+			logtype = append(logtype, placeholderInteger)
+			vars = append(vars, encodedVar)
+		} else {
+			logtype = append(logtype, placeholderDictionary)
+			dictVars = append(dictVars, varStr...)
+			dictVarEndOffsets = append(dictVarEndOffsets, int32(len(dictVars)))
+		}
+	}
+	logtype = appendEscapedConstant(logtype, message[constantBegin:])
+	return logtype, vars, dictVars, dictVarEndOffsets
+}
+
+// appendEscapedConstant appends constant (static text) to logtype, escaping
+// any character that could be confused with a placeholder.
+func appendEscapedConstant(logtype []byte, constant string) []byte {
+	for i := 0; i < len(constant); i++ {
+		switch constant[i] {
+		case placeholderInteger, placeholderDictionary, placeholderFloat, placeholderEscape:
+			logtype = append(logtype, placeholderEscape)
+		}
+		logtype = append(logtype, constant[i])
+	}
+	return logtype
+}
+
+// boundsOfNextVar finds the next variable in str, searching from pos. A
+// variable is a token (a run of non-delimiters) that either: contains a
+// decimal digit, is directly preceded by an equals sign and contains a letter,
+// or could be a multi-digit hexadecimal value. Mirrors the c++ equivalent
+// clp::ffi::get_bounds_of_next_var. Returns:
+//   - success: begin and end position of the variable in str, true
+//   - failure: undefined positions, false
+func boundsOfNextVar(str string, pos int) (int, int, bool) {
+	begin := pos
+	end := pos
+	for {
+		begin = end
+		for begin < len(str) && isDelim(str[begin]) {
+			begin++
+		}
+		if len(str) == begin {
+			return begin, begin, false
+		}
+
+		containsDecimalDigit := false
+		containsAlphabet := false
+		for end = begin; end < len(str); end++ {
+			c := str[end]
+			if '0' <= c && c <= '9' {
+				containsDecimalDigit = true
+			} else if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') {
+				containsAlphabet = true
+			} else if isDelim(c) {
+				break
+			}
+		}
+
+		if containsDecimalDigit ||
+			(0 < begin && '=' == str[begin-1] && containsAlphabet) ||
+			couldBeMultiDigitHexValue(str[begin:end]) {
+			return begin, end, true
+		}
+	}
+}
+
+// couldBeMultiDigitHexValue returns whether str is at least two hexadecimal
+// digits.
+func couldBeMultiDigitHexValue(str string) bool {
+	if len(str) < 2 {
+		return false
+	}
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		if false == (('a' <= c && c <= 'f') || ('A' <= c && c <= 'F') || ('0' <= c && c <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeFloatVar attempts to encode str as a float variable. Only floats with a
+// decimal point followed by at least one digit, and with few enough digits to
+// be represented exactly by T, can be encoded. Mirrors the c++ equivalent
+// clp::ffi::encode_float_var. Returns:
+//   - success: encoded variable, true
+//   - failure: 0, false
+func encodeFloatVar[T EightByteEncoding | FourByteEncoding](str string) (T, bool) {
+	var t T
+	maxDigits := eightByteFloatMaxDigits
+	digitsMask := eightByteFloatDigitsMask
+	if _, ok := any(t).(FourByteEncoding); ok {
+		maxDigits = fourByteFloatMaxDigits
+		digitsMask = uint64(fourByteFloatDigitsMask)
+	}
+
+	if 0 == len(str) {
+		return 0, false
+	}
+	pos := 0
+	// +1 for the decimal point
+	maxLen := maxDigits + 1
+	isNegative := false
+	if '-' == str[pos] {
+		isNegative = true
+		pos++
+		maxLen++
+	}
+	if len(str) > maxLen {
+		return 0, false
+	}
+
+	numDigits := 0
+	decimalPointPos := -1
+	var digits uint64
+	for ; pos < len(str); pos++ {
+		c := str[pos]
+		if '0' <= c && c <= '9' {
+			digits = digits*10 + uint64(c-'0')
+			numDigits++
+		} else if -1 == decimalPointPos && '.' == c {
+			decimalPointPos = len(str) - 1 - pos
+		} else {
+			return 0, false
+		}
+	}
+	if -1 == decimalPointPos || 0 == decimalPointPos || 0 == numDigits || digits > digitsMask {
+		return 0, false
+	}
+	return packFloatVar[T](isNegative, digits, numDigits, decimalPointPos)
+}
+
+// packFloatVar packs the fields of a float variable into an encoded float
+// variable of type T. Returns:
+//   - success: encoded variable, true
+//   - failure: 0, false if the float has too many digits to be represented by
+//     T
+func packFloatVar[T EightByteEncoding | FourByteEncoding](
+	isNegative bool,
+	digits uint64,
+	numDigits int,
+	decimalPointPos int,
+) (T, bool) {
+	var t T
+	switch any(t).(type) {
+	case EightByteEncoding:
+		if numDigits > eightByteFloatMaxDigits || digits > eightByteFloatDigitsMask {
+			return 0, false
+		}
+		var encoded uint64
+		if isNegative {
+			encoded = 1
+		}
+		encoded <<= 55
+		encoded |= digits & eightByteFloatDigitsMask
+		encoded <<= 4
+		encoded |= uint64(numDigits-1) & 0x0F
+		encoded <<= 4
+		encoded |= uint64(decimalPointPos-1) & 0x0F
+		return T(int64(encoded)), true
+	default:
+		if numDigits > fourByteFloatMaxDigits || digits > uint64(fourByteFloatDigitsMask) {
+			return 0, false
+		}
+		var encoded uint32
+		if isNegative {
+			encoded = 1
+		}
+		encoded <<= 25
+		encoded |= uint32(digits) & fourByteFloatDigitsMask
+		encoded <<= 3
+		encoded |= uint32(numDigits-1) & 0x07
+		encoded <<= 3
+		encoded |= uint32(decimalPointPos-1) & 0x07
+		return T(int32(encoded)), true
+	}
+}
+
+// unpackFloatVar returns the fields of an encoded float variable: whether it is
+// negative, its digits (as an integer), its number of digits, and the position
+// of its decimal point counted from the end of the float.
+func unpackFloatVar[T EightByteEncoding | FourByteEncoding](
+	encodedVar T,
+) (bool, uint64, int, int) {
+	var isNegative bool
+	var digits uint64
+	var numDigits int
+	var decimalPointPos int
+	switch v := any(encodedVar).(type) {
+	case EightByteEncoding:
+		// 1 bit sign, 1 bit unused, 54 bits digits, 4 bits number of digits,
+		// 4 bits decimal point position
+		u := uint64(v)
+		isNegative = 0 != u>>63
+		digits = (u >> 8) & eightByteFloatDigitsMask
+		numDigits = int((u>>4)&0x0F) + 1
+		decimalPointPos = int(u&0x0F) + 1
+	case FourByteEncoding:
+		// 1 bit sign, 25 bits digits, 3 bits number of digits, 3 bits decimal
+		// point position
+		u := uint32(v)
+		isNegative = 0 != u>>31
+		digits = uint64((u >> 6) & fourByteFloatDigitsMask)
+		numDigits = int((u>>3)&0x07) + 1
+		decimalPointPos = int(u&0x07) + 1
+	}
+	return isNegative, digits, numDigits, decimalPointPos
+}
+
+// encodeIntegerVar attempts to encode str as an integer variable. Only
+// integers without zero-padding or a positive sign, and within the range of T,
+// can be encoded. Mirrors the c++ equivalent clp::ffi::encode_integer_var.
+// Returns:
+//   - success: encoded variable, true
+//   - failure: 0, false
+func encodeIntegerVar[T EightByteEncoding | FourByteEncoding](str string) (T, bool) {
+	if 0 == len(str) {
+		return 0, false
+	}
+	if '-' == str[0] {
+		if len(str) < 2 || str[1] < '1' || '9' < str[1] {
+			return 0, false
+		}
+	} else {
+		if str[0] < '0' || '9' < str[0] {
+			return 0, false
+		}
+		if len(str) > 1 && '0' == str[0] {
+			return 0, false
+		}
+	}
+	var t T
+	bitSize := 64
+	if _, ok := any(t).(FourByteEncoding); ok {
+		bitSize = 32
+	}
+	value, err := strconv.ParseInt(str, 10, bitSize)
+	if nil != err {
+		return 0, false
+	}
+	return T(value), true
+}
+
+// decodeIntegerVar returns the text of the encoded integer variable.
+func decodeIntegerVar[T EightByteEncoding | FourByteEncoding](encodedVar T) string {
+	return strconv.FormatInt(int64(encodedVar), 10)
+}
+
+// decodeFloatVar returns the text of the encoded float variable, exactly as it
+// appeared in the original log message. Mirrors the c++ equivalent
+// clp::ffi::decode_float_var. On error returns:
+//   - "" string
+//   - [DecodeError] error: the encoded float's fields are inconsistent
+func decodeFloatVar[T EightByteEncoding | FourByteEncoding](encodedVar T) (string, error) {
+	isNegative, digits, numDigits, decimalPointPos := unpackFloatVar(encodedVar)
+	if numDigits < decimalPointPos {
+		return "", DecodeError
+	}
+
+	// +1 for the decimal point
+	valueLen := numDigits + 1
+	if isNegative {
+		valueLen++
+	}
+	value := make([]byte, valueLen)
+	numCharsToProcess := valueLen
+	if isNegative {
+		value[0] = '-'
+		numCharsToProcess--
+	}
+
+	// Decode until the decimal point or the non-zero digits are exhausted
+	pos := valueLen - 1
+	decimalIdx := valueLen - 1 - decimalPointPos
+	for ; pos > decimalIdx && digits > 0; pos-- {
+		value[pos] = '0' + byte(digits%10)
+		digits /= 10
+		numCharsToProcess--
+	}
+	// Fill in the zeros between the last non-zero digit and the decimal point
+	for ; pos > decimalIdx; pos-- {
+		value[pos] = '0'
+		numCharsToProcess--
+	}
+	value[pos] = '.'
+	pos--
+	numCharsToProcess--
+	for ; numCharsToProcess > 0; pos, numCharsToProcess = pos-1, numCharsToProcess-1 {
+		value[pos] = '0' + byte(digits%10)
+		digits /= 10
+	}
+	return string(value), nil
+}
+
+// appendDecodedMessage appends the decoded log message of msg to dst and
+// returns the extended buffer. Mirrors the c++ equivalent
+// clp::ffi::ir_stream::generic_decode_message. On error returns:
+//   - dst unchanged
+//   - [DecodeError] error: the logtype's placeholders are inconsistent with
+//     the message's variables
+func appendDecodedMessage[T EightByteEncoding | FourByteEncoding](
+	dst []byte,
+	msg LogMessage[T],
+) ([]byte, error) {
+	origLen := len(dst)
+	varIdx := 0
+	dictVarIdx := 0
+	dictVarBegin := int32(0)
+	for i := 0; i < len(msg.Logtype); i++ {
+		switch c := msg.Logtype[i]; c {
+		case placeholderInteger, placeholderFloat:
+			if varIdx >= len(msg.Vars) {
+				return dst[:origLen], DecodeError
+			}
+			if placeholderInteger == c {
+				dst = strconv.AppendInt(dst, int64(msg.Vars[varIdx]), 10)
+			} else {
+				text, err := decodeFloatVar(msg.Vars[varIdx])
+				if nil != err {
+					return dst[:origLen], err
+				}
+				dst = append(dst, text...)
+			}
+			varIdx++
+		case placeholderDictionary:
+			if dictVarIdx >= len(msg.DictVarEndOffsets) {
+				return dst[:origLen], DecodeError
+			}
+			dictVarEnd := msg.DictVarEndOffsets[dictVarIdx]
+			if dictVarEnd < dictVarBegin || int(dictVarEnd) > len(msg.DictVars) {
+				return dst[:origLen], DecodeError
+			}
+			dst = append(dst, msg.DictVars[dictVarBegin:dictVarEnd]...)
+			dictVarBegin = dictVarEnd
+			dictVarIdx++
+		case placeholderEscape:
+			if i == len(msg.Logtype)-1 {
+				return dst[:origLen], DecodeError
+			}
+			i++
+			dst = append(dst, msg.Logtype[i])
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return dst, nil
+}
+
+// decodeVariables returns the decoded text of each variable of msg, in the
+// order of their placeholders in its logtype. The returned strings are owned
+// by Go. On error returns:
+//   - nil []string
+//   - [DecodeError] error: the logtype's placeholders are inconsistent with
+//     the message's variables
+func decodeVariables[T EightByteEncoding | FourByteEncoding](
+	msg LogMessage[T],
+) ([]string, error) {
+	var vars []string
+	varIdx := 0
+	dictVarIdx := 0
+	dictVarBegin := int32(0)
+	for i := 0; i < len(msg.Logtype); i++ {
+		switch c := msg.Logtype[i]; c {
+		case placeholderInteger, placeholderFloat:
+			if varIdx >= len(msg.Vars) {
+				return nil, DecodeError
+			}
+			if placeholderInteger == c {
+				vars = append(vars, strconv.FormatInt(int64(msg.Vars[varIdx]), 10))
+			} else {
+				text, err := decodeFloatVar(msg.Vars[varIdx])
+				if nil != err {
+					return nil, err
+				}
+				vars = append(vars, text)
+			}
+			varIdx++
+		case placeholderDictionary:
+			if dictVarIdx >= len(msg.DictVarEndOffsets) {
+				return nil, DecodeError
+			}
+			dictVarEnd := msg.DictVarEndOffsets[dictVarIdx]
+			if dictVarEnd < dictVarBegin || int(dictVarEnd) > len(msg.DictVars) {
+				return nil, DecodeError
+			}
+			vars = append(vars, strings.Clone(msg.DictVars[dictVarBegin:dictVarEnd]))
+			dictVarBegin = dictVarEnd
+			dictVarIdx++
+		case placeholderEscape:
+			// The escaped character is static text
+			i++
+		}
+	}
+	return vars, nil
+}
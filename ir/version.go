@@ -0,0 +1,119 @@
+package ir
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// VersionPolicy determines how a [Reader] handles an IR stream whose version
+// is not supported (see [ValidateVersion]). The zero value is
+// [VersionPolicyWarn]: Readers read IR streams of any version, as they did
+// before versions were validated, and the native library does not reject any
+// version itself. Use [VersionPolicyReject] to fail instead.
+type VersionPolicy int
+
+const (
+	// VersionPolicyWarn reports the [*UnsupportedVersionError] to the
+	// Reader's Warn callback (if any, see [ReaderOptions]) and attempts to read
+	// the stream anyway.
+	VersionPolicyWarn VersionPolicy = iota
+	// VersionPolicyReject fails to create the Reader, returning an
+	// [*UnsupportedVersionError].
+	VersionPolicyReject
+	// VersionPolicyIgnore attempts to read the stream without any warning.
+	VersionPolicyIgnore
+)
+
+// Supported IR stream versions. Unstructured IR streams are supported from
+// version 0.0.0 (the version of the first IR streams, written as "v0.0.0") up
+// to the version written by [Serializer]. Key-value pair IR streams are only
+// supported at the version written by [KVSerializer]. The range is defined by
+// this package and not taken from the linked native library, which neither
+// exposes nor checks the versions it supports. The package's tests check that
+// the library writes MaxSupportedVersion and reads IR streams of every
+// supported version.
+const (
+	MinSupportedVersion = "0.0.0"
+	MaxSupportedVersion = metadataVersionValue
+	KVSupportedVersion  = kvMetadataVersionValue
+)
+
+const (
+	legacyVersionPrefix   = "v"
+	supportedVersionsDesc = MinSupportedVersion + " to " + MaxSupportedVersion + ", or " +
+		KVSupportedVersion + " for key-value pair IR"
+)
+
+// UnsupportedVersionError is returned when an IR stream's version is outside
+// the range of supported versions. errors.Is reports it as
+// [UnsupportedVersion].
+type UnsupportedVersionError struct {
+	Version string
+}
+
+func (err *UnsupportedVersionError) Error() string {
+	return fmt.Sprintf(
+		"unsupported IR stream version %q (supported: %v)",
+		err.Version,
+		supportedVersionsDesc,
+	)
+}
+
+// Is reports whether target is [UnsupportedVersion].
+func (err *UnsupportedVersionError) Is(target error) bool {
+	return UnsupportedVersion == target
+}
+
+// ValidateVersion checks whether an IR stream of version (as returned by
+// [Deserializer].Version) is within the supported range (see
+// [MinSupportedVersion]). Returns:
+//   - success: nil
+//   - error: [*UnsupportedVersionError]
+func ValidateVersion(version string) error {
+	semver, ok := parseVersion(strings.TrimPrefix(version, legacyVersionPrefix))
+	if ok {
+		minSemver, _ := parseVersion(MinSupportedVersion)
+		maxSemver, _ := parseVersion(MaxSupportedVersion)
+		kvSemver, _ := parseVersion(KVSupportedVersion)
+		if kvSemver == semver ||
+			(compareVersions(minSemver, semver) <= 0 && compareVersions(semver, maxSemver) <= 0) {
+			return nil
+		}
+	}
+	return &UnsupportedVersionError{version}
+}
+
+// parseVersion parses a MAJOR.MINOR.PATCH version. Pre-release and build
+// suffixes are not supported. Returns:
+//   - success: the version's components, true
+//   - failure: 0 value array, false
+func parseVersion(version string) ([3]int, bool) {
+	var semver [3]int
+	components := strings.Split(version, ".")
+	if len(semver) != len(components) {
+		return semver, false
+	}
+	for i, component := range components {
+		n, err := strconv.Atoi(component)
+		if nil != err || 0 > n || strings.HasPrefix(component, "+") {
+			return [3]int{}, false
+		}
+		semver[i] = n
+	}
+	return semver, true
+}
+
+// compareVersions returns -1, 0, or 1 if a is less than, equal to, or greater
+// than b respectively.
+func compareVersions(a [3]int, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,158 @@
+package ir
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/y-scope/clp-ffi-go/ffi"
+)
+
+func TestValidateVersion(t *testing.T) {
+	for version, supported := range map[string]bool{
+		"v0.0.0":       true,
+		"0.0.0":        true,
+		"0.0.1":        true,
+		"0.0.2":        true,
+		"0.1.0":        true,
+		"":             false,
+		"0.0.3":        false,
+		"0.2.0":        false,
+		"1.0.0":        false,
+		"0.1.0-beta.1": false,
+		"0.0":          false,
+		"0.-1.0":       false,
+	} {
+		err := ValidateVersion(version)
+		if supported != (nil == err) {
+			t.Fatalf("ValidateVersion(%q) returned %v", version, err)
+		}
+		if supported {
+			continue
+		}
+		var versionErr *UnsupportedVersionError
+		if false == errors.As(err, &versionErr) || version != versionErr.Version {
+			t.Fatalf("ValidateVersion(%q) returned the wrong error type: %v", version, err)
+		}
+		if false == errors.Is(err, UnsupportedVersion) {
+			t.Fatalf("ValidateVersion(%q) error is not UnsupportedVersion", version)
+		}
+	}
+}
+
+func TestDeserializerVersionEncoding(t *testing.T) {
+	for _, encoding := range []Encoding{EncodingEightByte, EncodingFourByte} {
+		irStream := serializeEncodedTestStream(t, encoding, TimestampInfo{}, nil)
+		for _, deserializePreamble := range []func([]byte) (Deserializer, int, error){
+			DeserializePreamble,
+			goDeserializePreamble,
+		} {
+			deserializer, _, err := deserializePreamble(irStream)
+			if nil != err {
+				t.Fatalf("DeserializePreamble failed: %v", err)
+			}
+			if metadataVersionValue != deserializer.Version() {
+				t.Fatalf("Wrong version: %v", deserializer.Version())
+			}
+			if encoding != deserializer.Encoding() {
+				t.Fatalf("Wrong encoding: %v != %v", deserializer.Encoding(), encoding)
+			}
+			deserializer.Close()
+		}
+
+		_, preamble := newTestKVSerializer(t, encoding)
+		deserializer, _, err := DeserializePreamble(preamble)
+		if nil != err {
+			t.Fatalf("DeserializePreamble failed: %v", err)
+		}
+		if KVSupportedVersion != deserializer.Version() || encoding != deserializer.Encoding() {
+			t.Fatalf("Wrong version or encoding: %v, %v", deserializer.Version(), encoding)
+		}
+		deserializer.Close()
+	}
+}
+
+func TestReaderVersionPolicy(t *testing.T) {
+	const version = "0.3.0"
+	preamble, err := appendPreambleMetadata(nil, false, map[string]any{
+		metadataVersionKey: version,
+	})
+	if nil != err {
+		t.Fatalf("appendPreambleMetadata failed: %v", err)
+	}
+	irStream := append(preamble, tagEof)
+
+	_, err = NewReaderWithOptions(
+		bytes.NewReader(irStream),
+		ReaderOptions{VersionPolicy: VersionPolicyReject},
+	)
+	var versionErr *UnsupportedVersionError
+	if false == errors.As(err, &versionErr) || version != versionErr.Version {
+		t.Fatalf("NewReader did not reject version %v: %v", version, err)
+	}
+
+	// The zero value VersionPolicy is VersionPolicyWarn
+	for _, policy := range []VersionPolicy{0, VersionPolicyWarn, VersionPolicyIgnore} {
+		var warnings []error
+		irreader, err := NewReaderWithOptions(bytes.NewReader(irStream), ReaderOptions{
+			VersionPolicy: policy,
+			Warn:          func(err error) { warnings = append(warnings, err) },
+		})
+		if nil != err {
+			t.Fatalf("NewReaderWithOptions failed: %v", err)
+		}
+		if version != irreader.Version() {
+			t.Fatalf("Wrong version: %v", irreader.Version())
+		}
+		if (VersionPolicyWarn == policy) != (1 == len(warnings)) {
+			t.Fatalf("Wrong warnings for policy %v: %v", policy, warnings)
+		}
+		assertEndOfIr(t, bytes.NewReader(irStream), irreader)
+		irreader.Close()
+	}
+}
+
+func TestSupportedVersionRange(t *testing.T) {
+	event := ffi.LogEvent{LogMessage: "static text dict=var 123", Timestamp: 1000}
+	serializer, preamble, err := EightByteSerializer("", "", "")
+	if nil != err {
+		t.Fatalf("EightByteSerializer failed: %v", err)
+	}
+	defer serializer.Close()
+	deserializer, _, err := DeserializePreamble(preamble)
+	if nil != err {
+		t.Fatalf("DeserializePreamble failed: %v", err)
+	}
+	deserializer.Close()
+	if MaxSupportedVersion != deserializer.Version() {
+		t.Fatalf("Serializer wrote version %v", deserializer.Version())
+	}
+	irView, err := serializer.SerializeLogEvent(event)
+	if nil != err {
+		t.Fatalf("SerializeLogEvent failed: %v", err)
+	}
+
+	for _, version := range []string{
+		legacyVersionPrefix + MinSupportedVersion,
+		MinSupportedVersion,
+		MaxSupportedVersion,
+	} {
+		irStream, err := appendPreambleMetadata(nil, false, map[string]any{
+			metadataVersionKey: version,
+		})
+		if nil != err {
+			t.Fatalf("appendPreambleMetadata failed: %v", err)
+		}
+		irStream = append(append(irStream, irView...), tagEof)
+		irreader, err := NewReaderWithOptions(
+			bytes.NewReader(irStream),
+			ReaderOptions{VersionPolicy: VersionPolicyReject},
+		)
+		if nil != err {
+			t.Fatalf("NewReaderWithOptions failed for version %v: %v", version, err)
+		}
+		assertIrLogEvent(t, nil, irreader, event)
+		assertEndOfIr(t, nil, irreader)
+		irreader.Close()
+	}
+}
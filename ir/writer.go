@@ -2,8 +2,10 @@ package ir
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"time"
 
 	"github.com/y-scope/clp-ffi-go/ffi"
@@ -13,10 +15,14 @@ import (
 // [NewWriter] will construct a Writer with the appropriate Serializer based on
 // the arguments used. Close must be called to free the underlying memory and
 // failure to do so will result in a memory leak. To write a complete IR stream
-// Close must be called before the final WriteTo call.
+// Close must be called before the final WriteTo call. utcOffset stores the UTC
+// offset of the log events written (0 until the first UTC offset change), and
+// location (if not nil) is the location whose UTC offset is tracked by Write.
 type Writer struct {
 	Serializer
-	buf bytes.Buffer
+	buf       bytes.Buffer
+	utcOffset time.Duration
+	location  *time.Location
 }
 
 // Returns [NewWriterSize] with a FourByteEncoding Serializer using the local
@@ -37,6 +43,22 @@ func NewWriter() (*Writer, error) {
 func NewWriterSize[T EightByteEncoding | FourByteEncoding](
 	size int,
 	timeZoneId string,
+) (*Writer, error) {
+	return NewWriterSizeWithMetadata[T](size, timeZoneId, nil)
+}
+
+// NewWriterSizeWithMetadata is [NewWriterSize], additionally storing
+// userMetadata (e.g. hostname, service, or build version) in the CLP IR
+// preamble so it travels with the IR stream. It is exposed when reading the
+// stream by [Deserializer].Metadata.
+//   - success: valid [*Writer], nil
+//   - error: nil [*Writer], invalid type error or an error propagated from
+//     [FourByteSerializerWithMetadata], [EightByteSerializerWithMetadata], or
+//     [bytes.Buffer.Write]
+func NewWriterSizeWithMetadata[T EightByteEncoding | FourByteEncoding](
+	size int,
+	timeZoneId string,
+	userMetadata map[string]any,
 ) (*Writer, error) {
 	var irw Writer
 	irw.buf.Grow(size)
@@ -46,17 +68,19 @@ func NewWriterSize[T EightByteEncoding | FourByteEncoding](
 	var t T
 	switch any(t).(type) {
 	case EightByteEncoding:
-		irw.Serializer, irView, err = EightByteSerializer(
+		irw.Serializer, irView, err = EightByteSerializerWithMetadata(
 			"",
 			"",
 			timeZoneId,
+			userMetadata,
 		)
 	case FourByteEncoding:
-		irw.Serializer, irView, err = FourByteSerializer(
+		irw.Serializer, irView, err = FourByteSerializerWithMetadata(
 			"",
 			"",
 			timeZoneId,
 			ffi.EpochTimeMs(time.Now().UnixMilli()),
+			userMetadata,
 		)
 	default:
 		err = fmt.Errorf("invalid type: %T", t)
@@ -71,6 +95,89 @@ func NewWriterSize[T EightByteEncoding | FourByteEncoding](
 	return &irw, nil
 }
 
+var errAppendTrailingData = errors.New("data follows the IR stream's EOF tag")
+
+// OpenAppendWriter creates a new [Writer] that continues the IR stream stored
+// in f, so that the log events written are appended to it. The IR stream is
+// read from the start of f to recover its encoding, TimestampInfo, UTC offset,
+// and (for four byte encoded IR) the timestamp of its last log event, so that
+// the timestamp deltas written are consistent. f is then truncated to remove
+// the EOF tag (if the IR stream has one) and seeked to its end. As with any
+// Writer, the Writer's buffer must be written to f with [Writer.WriteTo] and
+// [Writer.CloseTo]. Returns:
+//   - success: valid [*Writer], nil
+//   - error: nil [*Writer], [io.ErrUnexpectedEOF] (f ends with an incomplete
+//     log event), stream type error (a key-value pair IR stream), trailing
+//     data error (f contains more data after the EOF tag), or error
+//     propagated from [NewReader], [Reader.Read], [FourByteSerializer],
+//     [EightByteSerializer], or [os.File]
+func OpenAppendWriter(f *os.File) (*Writer, error) {
+	if _, err := f.Seek(0, io.SeekStart); nil != err {
+		return nil, err
+	}
+	reader, err := NewReader(f)
+	if nil != err {
+		return nil, err
+	}
+	defer reader.Close()
+	if _, ok := reader.Deserializer.(KVDeserializer); ok {
+		return nil, errKVStream
+	}
+	for nil == err {
+		_, err = reader.Read()
+	}
+	end := reader.Offset()
+	switch err {
+	case EndOfIr:
+		info, err := f.Stat()
+		if nil != err {
+			return nil, err
+		}
+		if end+1 != info.Size() {
+			return nil, errAppendTrailingData
+		}
+	case io.ErrUnexpectedEOF:
+		// The IR stream may end without an EOF tag if it was never closed, but
+		// not in the middle of a log event.
+		if reader.start != reader.end {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	var irw Writer
+	tsInfo := reader.TimestampInfo()
+	if EncodingFourByte == reader.Encoding() {
+		prevTimestamp, _ := fourByteTimestamp(reader.Deserializer)
+		irw.Serializer, _, err = FourByteSerializer(
+			tsInfo.Pattern,
+			tsInfo.PatternSyntax,
+			tsInfo.TimeZoneId,
+			prevTimestamp,
+		)
+	} else {
+		irw.Serializer, _, err = EightByteSerializer(
+			tsInfo.Pattern,
+			tsInfo.PatternSyntax,
+			tsInfo.TimeZoneId,
+		)
+	}
+	if nil != err {
+		return nil, err
+	}
+	irw.utcOffset = reader.UtcOffset()
+	if err = f.Truncate(end); nil != err {
+		irw.Serializer.Close()
+		return nil, err
+	}
+	if _, err = f.Seek(end, io.SeekStart); nil != err {
+		irw.Serializer.Close()
+		return nil, err
+	}
+	return &irw, nil
+}
+
 // Close will write a null byte denoting the end of the IR stream and delete the
 // underlying C++ allocated memory used by the serializer. Failure to call Close
 // will result in a memory leak.
@@ -103,15 +210,66 @@ func (writer *Writer) Reset() {
 	writer.buf.Reset()
 }
 
+// TrackUtcOffset makes each following Write call record a UTC offset change
+// (see [Writer.WriteUtcOffset]) if the UTC offset of location at the log
+// event's timestamp differs from the UTC offset of the previous log event. This
+// keeps the UTC offset of each log event correct across changes to location's
+// offset (e.g. daylight saving time transitions). A nil location stops
+// tracking.
+func (writer *Writer) TrackUtcOffset(location *time.Location) {
+	writer.location = location
+}
+
+// WriteUtcOffset uses [UtcOffsetSerializer.SerializeUtcOffsetChange] to record
+// that the log events written after it have a UTC offset of utcOffset
+// (truncated to whole seconds), and then stores it in the internal buffer.
+// Nothing is written if utcOffset is already the UTC offset in effect.
+// Returns:
+//   - success: number of bytes written, nil
+//   - error: number of bytes written (can be 0), error if the Writer's
+//     Serializer is not a [UtcOffsetSerializer], or error propagated from
+//     [UtcOffsetSerializer.SerializeUtcOffsetChange] or [bytes.Buffer.Write]
+func (writer *Writer) WriteUtcOffset(utcOffset time.Duration) (int, error) {
+	utcOffset = utcOffset.Truncate(time.Second)
+	if writer.utcOffset == utcOffset {
+		return 0, nil
+	}
+	serializer, ok := writer.Serializer.(UtcOffsetSerializer)
+	if false == ok {
+		return 0, errNoUtcOffsetChanges
+	}
+	irView, err := serializer.SerializeUtcOffsetChange(utcOffset)
+	if nil != err {
+		return 0, err
+	}
+	n, err := writer.buf.Write(irView)
+	if nil != err {
+		return n, err
+	}
+	writer.utcOffset = utcOffset
+	return n, nil
+}
+
 // Write uses [SerializeLogEvent] to serialize the provided log event to CLP IR
-// and then stores it in the internal buffer. Returns:
+// and then stores it in the internal buffer. If the Writer is tracking a
+// location's UTC offset (see [Writer.TrackUtcOffset]), a UTC offset change is
+// written first if needed. Returns:
 //   - success: number of bytes written, nil
 //   - error: number of bytes written (can be 0), error propagated from
-//     [SerializeLogEvent] or [bytes.Buffer.Write]
+//     [Writer.WriteUtcOffset], [SerializeLogEvent], or [bytes.Buffer.Write]
 func (writer *Writer) Write(event ffi.LogEvent) (int, error) {
+	var offsetN int
+	if nil != writer.location {
+		_, offset := time.UnixMilli(int64(event.Timestamp)).In(writer.location).Zone()
+		var err error
+		offsetN, err = writer.WriteUtcOffset(time.Duration(offset) * time.Second)
+		if nil != err {
+			return offsetN, err
+		}
+	}
 	irView, err := writer.SerializeLogEvent(event)
 	if nil != err {
-		return 0, err
+		return offsetN, err
 	}
 	// bytes.Buffer.Write will always return nil for err (https://pkg.go.dev/bytes#Buffer.Write)
 	// However, err is still propagated to correctly alert the user in case this ever changes. If
@@ -120,9 +278,9 @@ func (writer *Writer) Write(event ffi.LogEvent) (int, error) {
 	//   2. store irView and provide a retry API (allowing the user to fix the issue and retry)
 	n, err := writer.buf.Write(irView)
 	if nil != err {
-		return n, err
+		return offsetN + n, err
 	}
-	return n, nil
+	return offsetN + n, nil
 }
 
 // WriteTo writes data to w until the buffer is drained or an error occurs. If
@@ -0,0 +1,127 @@
+package ir
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenAppendWriter(t *testing.T) {
+	events := utcOffsetTestEvents()
+	for _, encoding := range []Encoding{EncodingEightByte, EncodingFourByte} {
+		for _, closed := range []bool{true, false} {
+			f := createAppendTestFile(t, encoding, events[:2], closed)
+			writer, err := OpenAppendWriter(f)
+			if nil != err {
+				t.Fatalf("OpenAppendWriter failed: %v", err)
+			}
+			for _, e := range events[2:] {
+				if _, err = writer.WriteUtcOffset(e.utcOffset); nil != err {
+					t.Fatalf("Writer.WriteUtcOffset failed: %v", err)
+				}
+				if _, err = writer.Write(e.event); nil != err {
+					t.Fatalf("Writer.Write failed: %v", err)
+				}
+			}
+			if _, err = writer.CloseTo(f); nil != err {
+				t.Fatalf("Writer.CloseTo failed: %v", err)
+			}
+
+			irStream, err := os.ReadFile(f.Name())
+			if nil != err {
+				t.Fatalf("os.ReadFile failed: %v", err)
+			}
+			f.Close()
+			// Only the UTC offset change at the daylight saving time transition
+			// is written, as the previous UTC offset is recovered.
+			if n := bytes.Count(irStream, []byte{tagUtcOffsetChange, 0xFF, 0xFF}); 2 != n {
+				t.Fatalf("Wrong number of UTC offset changes: %v", n)
+			}
+			irreader, err := NewReader(bytes.NewReader(irStream))
+			if nil != err {
+				t.Fatalf("NewReader failed: %v", err)
+			}
+			tsInfo := irreader.TimestampInfo()
+			if encoding != irreader.Encoding() || "America/New_York" != tsInfo.TimeZoneId {
+				t.Fatalf("Wrong IR stream: %v, %v", irreader.Encoding(), tsInfo)
+			}
+			for _, e := range events {
+				assertIrLogEvent(t, nil, irreader, e.event)
+				if e.utcOffset != irreader.UtcOffset() {
+					t.Fatalf("Wrong UTC offset: %v != %v", irreader.UtcOffset(), e.utcOffset)
+				}
+			}
+			assertEndOfIr(t, nil, irreader)
+			irreader.Close()
+		}
+	}
+}
+
+func TestOpenAppendWriterInvalid(t *testing.T) {
+	events := utcOffsetTestEvents()[:1]
+
+	f := createAppendTestFile(t, EncodingFourByte, events, false)
+	defer f.Close()
+	info, err := f.Stat()
+	if nil != err {
+		t.Fatalf("File.Stat failed: %v", err)
+	}
+	if err = f.Truncate(info.Size() - 1); nil != err {
+		t.Fatalf("File.Truncate failed: %v", err)
+	}
+	if _, err = OpenAppendWriter(f); io.ErrUnexpectedEOF != err {
+		t.Fatalf("OpenAppendWriter of an incomplete log event returned: %v", err)
+	}
+
+	f = createAppendTestFile(t, EncodingEightByte, events, true)
+	defer f.Close()
+	if _, err = f.Write([]byte{0x00}); nil != err {
+		t.Fatalf("File.Write failed: %v", err)
+	}
+	if _, err = OpenAppendWriter(f); errAppendTrailingData != err {
+		t.Fatalf("OpenAppendWriter of trailing data returned: %v", err)
+	}
+}
+
+// createAppendTestFile creates a file containing an IR stream of events, with
+// each event's UTC offset. The IR stream only ends with an EOF tag if closed.
+func createAppendTestFile(
+	t *testing.T,
+	encoding Encoding,
+	events []utcOffsetTestEvent,
+	closed bool,
+) *os.File {
+	var writer *Writer
+	var err error
+	if EncodingFourByte == encoding {
+		writer, err = NewWriterSize[FourByteEncoding](1024, "America/New_York")
+	} else {
+		writer, err = NewWriterSize[EightByteEncoding](1024, "America/New_York")
+	}
+	if nil != err {
+		t.Fatalf("NewWriterSize failed: %v", err)
+	}
+	defer writer.Serializer.Close()
+	for _, e := range events {
+		if _, err = writer.WriteUtcOffset(e.utcOffset); nil != err {
+			t.Fatalf("Writer.WriteUtcOffset failed: %v", err)
+		}
+		if _, err = writer.Write(e.event); nil != err {
+			t.Fatalf("Writer.Write failed: %v", err)
+		}
+	}
+	if closed {
+		writer.buf.WriteByte(tagEof)
+	}
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "append.clp"))
+	if nil != err {
+		t.Fatalf("os.Create failed: %v", err)
+	}
+	if _, err = writer.WriteTo(f); nil != err {
+		t.Fatalf("Writer.WriteTo failed: %v", err)
+	}
+	return f
+}
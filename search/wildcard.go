@@ -0,0 +1,128 @@
+package search
+
+import (
+	"strings"
+)
+
+// Match returns whether target matches the wildcard query in its entirety.
+func (wcq WildcardQuery) Match(target string) bool {
+	return wildcardMatch(target, wcq.query, wcq.caseSensitive)
+}
+
+// Match returns whether target matches any of the merged queries. If there are
+// no queries every target is considered a match. Returns:
+//   - match: index of the first query matched, true
+//   - no match: -1, false
+func (mwcq MergedWildcardQuery) Match(target string) (int, bool) {
+	if 0 == len(mwcq.endOffsets) {
+		return 0, true
+	}
+	pos := 0
+	for i, length := range mwcq.endOffsets {
+		if wildcardMatch(target, mwcq.queries[pos:pos+length], mwcq.caseSensitivity[i]) {
+			return i, true
+		}
+		pos += length
+	}
+	return -1, false
+}
+
+// cleanUpWildcardQuery returns query with consecutive '*' collapsed, escapes
+// removed from characters that do not need them, and any trailing escape
+// character removed. Mirrors the c++ equivalent
+// clp::string_utils::clean_up_wildcard_search_string.
+func cleanUpWildcardQuery(query string) string {
+	var sb strings.Builder
+	sb.Grow(len(query))
+	isEscaped := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		if isEscaped {
+			isEscaped = false
+			if '*' == c || '?' == c || '\\' == c {
+				sb.WriteByte('\\')
+			}
+			sb.WriteByte(c)
+		} else if '*' == c {
+			sb.WriteByte(c)
+			for i+1 < len(query) && '*' == query[i+1] {
+				i++
+			}
+		} else if '\\' == c {
+			isEscaped = true
+		} else {
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// wildcardMatch returns whether target matches the (clean) wildcard query in
+// its entirety. Case insensitive matching only folds ASCII letters. Mirrors
+// the c++ equivalent clp::string_utils::wildcard_match_unsafe.
+func wildcardMatch(target string, query string, caseSensitive bool) bool {
+	// Position in target and query to resume from after the last '*' if the
+	// characters following it fail to match
+	targetBookmark := -1
+	queryBookmark := -1
+	t := 0
+	q := 0
+	for t < len(target) {
+		if q < len(query) {
+			switch c := query[q]; c {
+			case '*':
+				q++
+				if len(query) == q {
+					return true
+				}
+				queryBookmark = q
+				targetBookmark = t
+				continue
+			case '?':
+				q++
+				t++
+				continue
+			case '\\':
+				if q+1 < len(query) {
+					c = query[q+1]
+				}
+				if equalChars(c, target[t], caseSensitive) {
+					q += 2
+					t++
+					continue
+				}
+			default:
+				if equalChars(c, target[t], caseSensitive) {
+					q++
+					t++
+					continue
+				}
+			}
+		}
+		if -1 == queryBookmark {
+			return false
+		}
+		// Let the last '*' consume one more character and try again
+		targetBookmark++
+		t = targetBookmark
+		q = queryBookmark
+	}
+	for q < len(query) && '*' == query[q] {
+		q++
+	}
+	return len(query) == q
+}
+
+func equalChars(a byte, b byte, caseSensitive bool) bool {
+	if caseSensitive {
+		return a == b
+	}
+	return toLower(a) == toLower(b)
+}
+
+func toLower(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + ('a' - 'A')
+	}
+	return c
+}
@@ -1,14 +1,7 @@
 package search
 
-/*
-#include <ffi_go/defs.h>
-#include <ffi_go/search/wildcard_query.h>
-*/
-import "C"
-
 import (
 	"strings"
-	"unsafe"
 
 	"github.com/y-scope/clp-ffi-go/ffi"
 )
@@ -25,32 +18,6 @@ type WildcardQuery struct {
 	caseSensitive bool
 }
 
-// Create a new WildcardQuery that is cleaned to contain a safe wildcard query
-// string. A wildcard query string must follow 2 rules:
-//  1. The wildcard string should not contain consecutive '*'.
-//  2. The wildcard string should not contain an escape character without a
-//     character following it.
-//
-// NewWildcardQuery will sanitize the provided query and store the safe version.
-func NewWildcardQuery(query string, caseSensitive bool) WildcardQuery {
-	var cptr unsafe.Pointer
-	cleanQuery := C.wildcard_query_new(
-		C.StringView{
-			(*C.char)(unsafe.Pointer(unsafe.StringData(query))),
-			C.size_t(len(query)),
-		},
-		&cptr,
-	)
-	defer C.wildcard_query_delete(cptr)
-	return WildcardQuery{
-		strings.Clone(unsafe.String(
-			(*byte)((unsafe.Pointer)(cleanQuery.m_data)),
-			cleanQuery.m_size,
-		)),
-		caseSensitive,
-	}
-}
-
 func (wcq WildcardQuery) Query() string       { return wcq.query }
 func (wcq WildcardQuery) CaseSensitive() bool { return wcq.caseSensitive }
 
@@ -0,0 +1,40 @@
+//go:build cgo && !purego
+
+package search
+
+/*
+#include <ffi_go/defs.h>
+#include <ffi_go/search/wildcard_query.h>
+*/
+import "C"
+
+import (
+	"strings"
+	"unsafe"
+)
+
+// Create a new WildcardQuery that is cleaned to contain a safe wildcard query
+// string. A wildcard query string must follow 2 rules:
+//  1. The wildcard string should not contain consecutive '*'.
+//  2. The wildcard string should not contain an escape character without a
+//     character following it.
+//
+// NewWildcardQuery will sanitize the provided query and store the safe version.
+func NewWildcardQuery(query string, caseSensitive bool) WildcardQuery {
+	var cptr unsafe.Pointer
+	cleanQuery := C.wildcard_query_new(
+		C.StringView{
+			(*C.char)(unsafe.Pointer(unsafe.StringData(query))),
+			C.size_t(len(query)),
+		},
+		&cptr,
+	)
+	defer C.wildcard_query_delete(cptr)
+	return WildcardQuery{
+		strings.Clone(unsafe.String(
+			(*byte)((unsafe.Pointer)(cleanQuery.m_data)),
+			cleanQuery.m_size,
+		)),
+		caseSensitive,
+	}
+}
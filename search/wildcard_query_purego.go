@@ -0,0 +1,14 @@
+//go:build !cgo || purego
+
+package search
+
+// Create a new WildcardQuery that is cleaned to contain a safe wildcard query
+// string. A wildcard query string must follow 2 rules:
+//  1. The wildcard string should not contain consecutive '*'.
+//  2. The wildcard string should not contain an escape character without a
+//     character following it.
+//
+// NewWildcardQuery will sanitize the provided query and store the safe version.
+func NewWildcardQuery(query string, caseSensitive bool) WildcardQuery {
+	return WildcardQuery{cleanUpWildcardQuery(query), caseSensitive}
+}
@@ -0,0 +1,96 @@
+package search
+
+import (
+	"testing"
+)
+
+func TestNewWildcardQuery(t *testing.T) {
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{"", ""},
+		{"abc", "abc"},
+		{"a**b***", "a*b*"},
+		{"\\*\\?\\\\", "\\*\\?\\\\"},
+		{"\\a\\b", "ab"},
+		{"ab\\", "ab"},
+		{"\\**", "\\**"},
+	}
+	for _, testCase := range testCases {
+		actual := NewWildcardQuery(testCase.query, true).Query()
+		if testCase.expected != actual {
+			t.Errorf("NewWildcardQuery(%q) = %q != %q", testCase.query, actual, testCase.expected)
+		}
+	}
+}
+
+func TestWildcardQueryMatch(t *testing.T) {
+	testCases := []struct {
+		target        string
+		query         string
+		caseSensitive bool
+		expected      bool
+	}{
+		{"", "", true, true},
+		{"", "*", true, true},
+		{"", "?", true, false},
+		{"a", "", true, false},
+		{"abc", "abc", true, true},
+		{"abc", "ABC", true, false},
+		{"abc", "ABC", false, true},
+		{"abc", "a?c", true, true},
+		{"abc", "a*", true, true},
+		{"abc", "*c", true, true},
+		{"abc", "*b*", true, true},
+		{"abc", "*d*", true, false},
+		{"aXbXc", "a*b*c", true, true},
+		{"abcbd", "*b?", true, true},
+		{"abcbde", "*b?", true, false},
+		{"a*c", "a\\*c", true, true},
+		{"abc", "a\\*c", true, false},
+		{"a?c", "a\\?c", true, true},
+		{"abc", "a\\?c", true, false},
+		{"a\\c", "a\\\\c", true, true},
+		{"mississippi", "*sip*", true, true},
+		{"mississippi", "m*iss*pi", true, true},
+	}
+	for _, testCase := range testCases {
+		query := NewWildcardQuery(testCase.query, testCase.caseSensitive)
+		if actual := query.Match(testCase.target); testCase.expected != actual {
+			t.Errorf(
+				"NewWildcardQuery(%q, %v).Match(%q) = %v",
+				testCase.query,
+				testCase.caseSensitive,
+				testCase.target,
+				actual,
+			)
+		}
+	}
+}
+
+func TestMergedWildcardQueryMatch(t *testing.T) {
+	if idx, ok := MergeWildcardQueries(nil).Match("abc"); 0 != idx || false == ok {
+		t.Errorf("empty MergedWildcardQuery.Match = %v, %v", idx, ok)
+	}
+	mergedQuery := MergeWildcardQueries([]WildcardQuery{
+		NewWildcardQuery("*x*", true),
+		NewWildcardQuery("*B*", false),
+		NewWildcardQuery("*b*", true),
+	})
+	testCases := []struct {
+		target string
+		idx    int
+		ok     bool
+	}{
+		{"abc", 1, true},
+		{"axc", 0, true},
+		{"ac", -1, false},
+	}
+	for _, testCase := range testCases {
+		idx, ok := mergedQuery.Match(testCase.target)
+		if testCase.idx != idx || testCase.ok != ok {
+			t.Errorf("MergedWildcardQuery.Match(%q) = %v, %v", testCase.target, idx, ok)
+		}
+	}
+}